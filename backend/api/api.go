@@ -0,0 +1,36 @@
+// Package api contains the OpenAPI specification of the WB backend HTTP API.
+package api
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Spec is the OpenAPI 3 document in YAML.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// DocsPage is an HTML page rendering the specification served at /openapi.yaml.
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// Load parses and validates the specification.
+func Load() (*openapi3.T, error) {
+	const op = "api.Load"
+
+	doc, err := openapi3.NewLoader().LoadFromData(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("%s: validate: %w", op, err)
+	}
+
+	return doc, nil
+}
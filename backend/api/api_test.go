@@ -0,0 +1,157 @@
+package api_test
+
+import (
+	"WB/api"
+	"WB/internal/delivery/dto"
+	"WB/internal/delivery/handlers"
+	resp "WB/internal/lib/api/response"
+	"WB/internal/models"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := api.Load()
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, doc.Paths.Map())
+}
+
+// TestSchemas_MatchModels fails when a schema of the specification drifts from the Go type
+// that is encoded on the wire.
+func TestSchemas_MatchModels(t *testing.T) {
+	doc, err := api.Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		schema string
+		model  any
+		// rules is the type whose validate tags make properties required, model by default
+		rules any
+	}{
+		{schema: "Order", model: dto.Order{}, rules: models.Order{}},
+		{schema: "OrderCreated", model: dto.OrderCreated{}},
+		{schema: "OrderPatch", model: dto.OrderPatch{}},
+		{schema: "CancelRequest", model: dto.CancelRequest{}},
+		{schema: "Refund", model: dto.Refund{}},
+		{schema: "CustomerExport", model: models.CustomerExport{}},
+		{schema: "ErasureResult", model: models.ErasureResult{}},
+		{schema: "AuditRecord", model: models.AuditRecord{}},
+		{schema: "Response", model: resp.Response{}},
+		{schema: "AuditVerification", model: handlers.AuditVerification{}},
+		{schema: "ConsumerStatus", model: models.ConsumerStatus{}},
+		{schema: "PartitionState", model: models.PartitionState{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			ref, ok := doc.Components.Schemas[tt.schema]
+			require.True(t, ok, "schema %s is missing", tt.schema)
+
+			rules := tt.rules
+			if rules == nil {
+				rules = tt.model
+			}
+			matchSchema(t, tt.schema, ref.Value, reflect.TypeOf(tt.model), reflect.TypeOf(rules))
+		})
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func matchSchema(t *testing.T, path string, s *openapi3.Schema, typ, rules reflect.Type) {
+	t.Helper()
+
+	switch {
+	case typ == timeType:
+		assert.True(t, s.Type.Is(openapi3.TypeString), "%s: want string", path)
+		assert.Equal(t, "date-time", s.Format, "%s: want date-time format", path)
+	case typ.Kind() == reflect.Pointer:
+		matchSchema(t, path, s, typ.Elem(), rules.Elem())
+	case typ.Kind() == reflect.String:
+		assert.True(t, s.Type.Is(openapi3.TypeString), "%s: want string", path)
+	case typ.Kind() == reflect.Bool:
+		assert.True(t, s.Type.Is(openapi3.TypeBoolean), "%s: want boolean", path)
+	case typ.Kind() == reflect.Int || typ.Kind() == reflect.Int64:
+		assert.True(t, s.Type.Is(openapi3.TypeInteger), "%s: want integer", path)
+	case typ.Kind() == reflect.Slice:
+		require.True(t, s.Type.Is(openapi3.TypeArray), "%s: want array", path)
+		matchSchema(t, path+"[]", s.Items.Value, typ.Elem(), rules.Elem())
+	case typ.Kind() == reflect.Struct:
+		require.True(t, s.Type.Is(openapi3.TypeObject), "%s: want object", path)
+		matchObject(t, path, s, typ, rules)
+	default:
+		t.Errorf("%s: unsupported type %s", path, typ)
+	}
+}
+
+func matchObject(t *testing.T, path string, s *openapi3.Schema, typ, rules reflect.Type) {
+	t.Helper()
+
+	var fields, required []string
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		name := jsonName(f)
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		fields = append(fields, name)
+
+		rule, ok := fieldByJSONName(rules, name)
+		if !assert.True(t, ok, "%s: property %s is missing in %s", path, name, rules) {
+			continue
+		}
+		prop, ok := s.Properties[name]
+		if !assert.True(t, ok, "%s: property %s is missing", path, name) {
+			continue
+		}
+		matchSchema(t, path+"."+name, prop.Value, f.Type, rule.Type)
+
+		_, opts, _ := strings.Cut(rule.Tag.Get("json"), ",")
+		if isRequired(rule.Tag, opts) {
+			required = append(required, name)
+		}
+	}
+
+	for name := range s.Properties {
+		assert.Contains(t, fields, name, "%s: property %s is not encoded by %s", path, name, typ)
+	}
+
+	want := slices.Clone(s.Required)
+	slices.Sort(want)
+	slices.Sort(required)
+	assert.Equal(t, required, want, "%s: required properties", path)
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+func fieldByJSONName(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range typ.NumField() {
+		if f := typ.Field(i); jsonName(f) == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// isRequired reports whether the field must be present: required by the
+// validator, or always encoded when the type is not validated.
+func isRequired(tag reflect.StructTag, jsonOpts string) bool {
+	if v, ok := tag.Lookup("validate"); ok {
+		return slices.Contains(strings.Split(v, ","), "required")
+	}
+	opts := strings.Split(jsonOpts, ",")
+	return !slices.Contains(opts, "omitempty") && !slices.Contains(opts, "omitzero")
+}
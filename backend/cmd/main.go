@@ -4,31 +4,46 @@
 package main
 
 import (
+	"WB/api"
 	"WB/internal/config"
-	"WB/internal/delivery/handlers"
-	mwLogger "WB/internal/delivery/middleware/logger"
+	mwAuth "WB/internal/delivery/middleware/auth"
+	"WB/internal/delivery/middleware/deprecation"
+	mwRateLimit "WB/internal/delivery/middleware/ratelimit"
+	"WB/internal/delivery/middleware/validate"
+	"WB/internal/delivery/router"
+	"WB/internal/delivery/rpc"
+	"WB/internal/lib/codec"
 	kafka "WB/internal/lib/kafka"
 	"WB/internal/lib/logger/sl"
 	"WB/internal/lib/logger/slogpretty"
+	"WB/internal/lib/pii"
+	"WB/internal/lib/policy"
+	"WB/internal/lib/ratelimit"
+	"WB/internal/lib/signature"
+	"WB/internal/lib/tlsconfig"
+	"WB/internal/models"
+	"WB/internal/repository/memory"
 	"WB/internal/repository/postgres"
 	"WB/internal/repository/redis"
 	usecase "WB/internal/usecase"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	chiprom "github.com/766b/chi-prometheus" // или "github.com/yarlson/chiprom"
-	"github.com/go-chi/chi/middleware"
-	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -45,13 +60,74 @@ func main() {
 
 	orderRepo := postgres.MustLoad(log, db, cfg.MigrationsPath)
 
-	redisConn := redis.MustLoad(log, cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.DB)
+	redisConn := redis.MustLoad(log, cfg.Redis, cfg.Cache)
 
-	kafkaProducer := kafka.MustProducer(log, cfg.Brokers, cfg.Topic)
+	kafkaSecurity, err := kafka.NewSecurity(cfg.Kafka)
+	if err != nil {
+		log.Error("invalid kafka security config", sl.Err(err))
+		os.Exit(1)
+	}
+
+	kafkaProducer := kafka.MustProducer(log, cfg.Brokers, cfg.Topic, kafkaSecurity, cfg.Kafka.Producer,
+		kafka.WithDeliveryCallback(logDeliveryFailure(log, cfg.Topic)))
+	eventProducer := kafka.MustProducer(log, cfg.Brokers, cfg.EventsTopic, kafkaSecurity, cfg.Kafka.Producer,
+		kafka.WithDeliveryCallback(logDeliveryFailure(log, cfg.EventsTopic)))
+
+	ucOpts := []usecase.Option{
+		usecase.WithCachePolicy(cfg.Cache.TTL, cfg.Cache.TTLJitter, cfg.Cache.MissingTTL),
+		usecase.WithAuditLog(orderRepo),
+		usecase.WithEventBroker(eventProducer),
+		usecase.WithProducerID(cfg.ProducerID),
+	}
+	sendCodec, acceptedCodecs, err := messageCodecs(cfg.Kafka)
+	if err != nil {
+		log.Error("invalid kafka codec config", sl.Err(err))
+		os.Exit(1)
+	}
+	ucOpts = append(ucOpts, usecase.WithAcceptedCodecs(acceptedCodecs...), usecase.WithMessageCodec(sendCodec))
+	if cfg.PIIReads {
+		ucOpts = append(ucOpts, usecase.WithPIIReadAudit())
+	}
+	if cfg.PseudonymSecret != "" {
+		ucOpts = append(ucOpts, usecase.WithPseudonymSecret([]byte(cfg.PseudonymSecret)))
+	}
+	if cfg.LocalSize > 0 {
+		ucOpts = append(ucOpts, usecase.WithLocalCache(memory.New(cfg.LocalSize, cfg.LocalTTL)))
+	}
+	if cfg.Authz.Enabled {
+		accessPolicy, err := policy.New(cfg.Authz)
+		if err != nil {
+			log.Error("failed to init access policy", sl.Err(err))
+			os.Exit(1)
+		}
+		ucOpts = append(ucOpts, usecase.WithAccessPolicy(accessPolicy))
+	}
+	verifier, err := signature.New(log, cfg.Signing)
+	if err != nil {
+		log.Error("failed to init signature verifier", sl.Err(err))
+		os.Exit(1)
+	}
+	ucOpts = append(ucOpts, usecase.WithSignatureVerifier(verifier))
+	if cfg.Masking.Enabled {
+		ucOpts = append(ucOpts, usecase.WithPIIMasking(cfg.RevealRoles))
+	}
+	if cfg.PII.Enabled {
+		keyring, err := pii.LoadKeyring(cfg.KeyringFile)
+		if err != nil {
+			log.Error("failed to load PII keyring", sl.Err(err))
+			os.Exit(1)
+		}
+		ucOpts = append(ucOpts, usecase.WithPIICipher(keyring))
+	}
 
-	orderUseCase := usecase.NewOrderUseCase(orderRepo, redisConn, kafkaProducer)
+	orderUseCase := usecase.NewOrderUseCase(orderRepo, redisConn, kafkaProducer, ucOpts...)
 
-	kafkaConsumer := kafka.NewConsumer(cfg.Brokers, cfg.ConsumerGroup, cfg.Topic, cfg.DLQTopic)
+	kafkaConsumer := kafka.NewConsumer(cfg.Brokers, cfg.ConsumerGroup, cfg.Topic, cfg.DLQTopic, kafkaSecurity,
+		kafka.WithDLQErrors(models.ErrUnsignedOrder, models.ErrInvalidSignature, models.ErrUnknownSchema),
+		kafka.WithStopErrors(models.ErrAuditUnavailable),
+		kafka.WithMetrics(kafka.NewMetrics(prometheus.DefaultRegisterer)),
+		kafka.WithDrainTimeout(cfg.DrainTimeout),
+	)
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -63,50 +139,145 @@ func main() {
 		return kafkaConsumer.Start(ctx, orderUseCase.HandleMessage)
 	})
 
-	router := chi.NewRouter()
-
-	router.Use(middleware.RequestID)
-	router.Use(middleware.Logger)
-	router.Use(mwLogger.New(log))
-	router.Use(middleware.Recoverer)
-
-	// позже нужно добавить метрики
-	router.Use(chiprom.NewMiddleware("my-service"))
-	// router.Use(middleware.URLFormat)
-
-	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173", "http://0.0.0.0:*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	router.Handle("/metrics", promhttp.Handler())
-	router.Post("/api/create_order", handlers.NewOrder(log, orderUseCase))
-	router.Get("/api/orders/{id}", handlers.GetOrder(log, orderUseCase))
-	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "web/static/index.html")
+	if cfg.LocalSize > 0 {
+		g.Go(func() error {
+			log.Info("subscribing to cache invalidations")
+			return redisConn.SubscribeInvalidations(ctx, orderUseCase.EvictLocal)
+		})
+	}
+
+	g.Go(func() error {
+		log.Info("starting Kafka consumer lag recording", slog.Duration("interval", cfg.LagInterval))
+		recordConsumerLag(ctx, log, kafkaConsumer, cfg.LagInterval)
+		return nil
 	})
 
+	g.Go(func() error {
+		log.Info("starting order event publishing", slog.Duration("period", cfg.OutboxPeriod))
+		publishEvents(ctx, log, orderUseCase, cfg.OutboxPeriod)
+		return nil
+	})
+
+	if cfg.PII.Enabled && cfg.RotationInterval > 0 {
+		g.Go(func() error {
+			log.Info("starting PII key rotation", slog.Duration("interval", cfg.RotationInterval))
+			rotatePIIKeys(ctx, log, orderUseCase, cfg.PII)
+			return nil
+		})
+	}
+
+	authMiddleware, err := mwAuth.NewMiddleware(log, cfg.Auth)
+	if err != nil {
+		log.Error("failed to init auth middleware", sl.Err(err))
+		os.Exit(1)
+	}
+
+	limiter := ratelimit.NewFallback(log, redisConn, ratelimit.NewLocal())
+	rateLimit := mwRateLimit.NewMiddleware(log, limiter, cfg.RateLimit)
+
+	routerOpts := []router.Option{
+		router.WithAuth(authMiddleware),
+		router.WithRateLimit(rateLimit),
+		router.WithDeprecation(deprecation.Policy{Since: cfg.LegacyDeprecated, Sunset: cfg.LegacySunset}),
+		router.WithCompression(cfg.CompressionLevel),
+		router.WithConsumerAdmin(kafkaConsumer),
+		router.WithMiddleware(
+			// позже нужно добавить метрики
+			chiprom.NewMiddleware("my-service"),
+			cors.Handler(cors.Options{
+				AllowedOrigins:   []string{"http://localhost:5173", "http://0.0.0.0:*"},
+				AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+				AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match", "If-Modified-Since", "X-CSRF-Token", "X-API-Key"},
+				ExposedHeaders:   []string{"ETag", "Last-Modified", "Link", "Location", "Deprecation", "Sunset", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+				AllowCredentials: true,
+				MaxAge:           300,
+			}),
+		),
+	}
+	if cfg.OpenAPI.Validate {
+		spec, err := api.Load()
+		if err != nil {
+			log.Error("failed to load OpenAPI specification", sl.Err(err))
+			os.Exit(1)
+		}
+		var validateOpts []validate.Option
+		if cfg.ValidateResponses {
+			validateOpts = append(validateOpts, validate.WithResponses())
+		}
+		validation, err := validate.New(log, spec, validateOpts...)
+		if err != nil {
+			log.Error("failed to init OpenAPI validation", sl.Err(err))
+			os.Exit(1)
+		}
+		routerOpts = append(routerOpts, router.WithValidation(validation.Handler))
+	}
+
+	handler := router.New(log, orderUseCase, routerOpts...)
+
+	serverTLS, err := tlsconfig.Server(cfg.HTTPServer.TLS)
+	if err != nil {
+		log.Error("invalid HTTP server TLS config", sl.Err(err))
+		os.Exit(1)
+	}
+
 	srv := &http.Server{
-		Addr:         cfg.Address,
-		Handler:      router,
+		Addr:         cfg.HTTPServer.Address,
+		Handler:      handler,
 		ReadTimeout:  cfg.Timeout,
 		WriteTimeout: cfg.Timeout,
 		IdleTimeout:  cfg.IdleTimeout,
+		TLSConfig:    serverTLS,
 	}
 
 	g.Go(func() error {
-		log.Info("starting HTTP server", slog.String("address", cfg.Address))
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Info("starting HTTP server", slog.String("address", cfg.HTTPServer.Address), slog.Bool("tls", serverTLS != nil))
+
+		var err error
+		if serverTLS != nil {
+			// certificates are already loaded into TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Error("HTTP server error", sl.Err(err))
 			return err
 		}
 		return nil
 	})
 
+	var grpcServer *grpc.Server
+	if cfg.GRPCServer.Enabled {
+		grpcTLS, err := tlsconfig.Server(cfg.GRPCServer.TLS)
+		if err != nil {
+			log.Error("invalid gRPC server TLS config", sl.Err(err))
+			os.Exit(1)
+		}
+
+		metrics := rpc.NewMetrics(prometheus.DefaultRegisterer)
+		grpcAuth := rpc.NewAuth(log, authMiddleware, cfg.AuthGroup)
+		grpcServer = rpc.NewServer(log, orderUseCase,
+			rpc.WithTLS(grpcTLS),
+			rpc.WithInterceptors(metrics.Unary(), metrics.Stream()),
+			rpc.WithInterceptors(grpcAuth.Unary(), grpcAuth.Stream()),
+		)
+
+		lis, err := net.Listen("tcp", cfg.GRPCServer.Address)
+		if err != nil {
+			log.Error("failed to listen for gRPC", sl.Err(err))
+			os.Exit(1)
+		}
+
+		g.Go(func() error {
+			log.Info("starting gRPC server", slog.String("address", cfg.GRPCServer.Address), slog.Bool("tls", grpcTLS != nil))
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Error("gRPC server error", sl.Err(err))
+				return err
+			}
+			return nil
+		})
+	}
+
 	<-ctx.Done()
 	log.Info("shutting down gracefully...")
 
@@ -116,12 +287,22 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Error("HTTP server forced shutdown", sl.Err(err))
 	}
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
 
+	// a failed component, such as a consumer stopped by an unaudited order,
+	// exits with an error, so the service is restarted
+	failed := false
 	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
 		log.Error("error during shutdown", sl.Err(err))
+		failed = true
 	}
 
 	log.Info("closing resources...")
+	if err := kafkaConsumer.Close(); err != nil {
+		log.Error("error closing kafka consumer", sl.Err(err))
+	}
 	if err := db.Close(); err != nil {
 		log.Error("error closing database", sl.Err(err))
 	}
@@ -131,6 +312,146 @@ func main() {
 	if err := kafkaProducer.Close(); err != nil {
 		log.Error("error closing kafka producer", sl.Err(err))
 	}
+	if err := eventProducer.Close(); err != nil {
+		log.Error("error closing kafka event producer", sl.Err(err))
+	}
 
+	if failed {
+		os.Exit(1)
+	}
 	log.Info("server stopped gracefully")
 }
+
+// stopGRPC waits for pending gRPC calls to finish and stops the server
+// forcibly when ctx is done first.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
+
+// messageCodecs returns the codec of the orders sent to Kafka, selected by
+// its content type, and the codecs of the orders accepted from Kafka besides
+// JSON: the sent one and those in cfg.Codec.Accept. The Avro schema registry
+// is opened only if Avro is sent or accepted.
+func messageCodecs(cfg config.Kafka) (codec.Codec, []codec.Codec, error) {
+	send, err := messageCodec(cfg, cfg.Codec.ContentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accepted := []codec.Codec{send}
+	for _, contentType := range cfg.Codec.Accept {
+		if slices.ContainsFunc(accepted, func(c codec.Codec) bool { return c.ContentType() == contentType }) {
+			continue
+		}
+		c, err := messageCodec(cfg, contentType)
+		if err != nil {
+			return nil, nil, err
+		}
+		accepted = append(accepted, c)
+	}
+	return send, accepted, nil
+}
+
+// messageCodec returns the codec of the content type.
+func messageCodec(cfg config.Kafka, contentType string) (codec.Codec, error) {
+	switch contentType {
+	case codec.ContentTypeJSON:
+		return codec.JSON{}, nil
+	case codec.ContentTypeProtobuf:
+		return codec.Protobuf{}, nil
+	case codec.ContentTypeAvro:
+		registry, err := codec.NewFileRegistry(cfg.Codec.SchemaRegistryDir)
+		if err != nil {
+			return nil, err
+		}
+		// the subject of the topic values in the Confluent naming strategy
+		return codec.NewAvro(registry, cfg.Topic+"-value")
+	default:
+		return nil, fmt.Errorf("unknown content type %q", contentType)
+	}
+}
+
+// logDeliveryFailure returns a delivery callback that logs messages the
+// producer of topic failed to write.
+func logDeliveryFailure(log *slog.Logger, topic string) kafka.DeliveryCallback {
+	return func(key string, err error) {
+		if err != nil {
+			log.Error("failed to deliver kafka message",
+				slog.String("topic", topic), slog.String("key", key), sl.Err(err))
+		}
+	}
+}
+
+// recordConsumerLag periodically records the consumer lag reported by the
+// brokers until the context is canceled.
+func recordConsumerLag(ctx context.Context, log *slog.Logger, consumer *kafka.Consumer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := consumer.RecordLag(ctx); err != nil && ctx.Err() == nil {
+			log.Error("failed to record kafka consumer lag", sl.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// outboxBatch is how many stored order events are read at a time.
+const outboxBatch = 100
+
+// publishEvents periodically publishes the order events stored in the outbox
+// until the context is canceled. Failed runs are retried on the next tick.
+func publishEvents(ctx context.Context, log *slog.Logger, uc *usecase.OrderUseCase, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		n, err := uc.PublishEvents(ctx, outboxBatch)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to publish order events", slog.Int("published", n), sl.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rotatePIIKeys periodically re-encrypts stored personal data with the active key
+// until the context is canceled. Failed runs are retried on the next tick.
+func rotatePIIKeys(ctx context.Context, log *slog.Logger, uc *usecase.OrderUseCase, cfg config.PII) {
+	ticker := time.NewTicker(cfg.RotationInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := uc.RotatePIIKeys(ctx, cfg.RotationBatch)
+		if err != nil && ctx.Err() == nil {
+			log.Error("PII key rotation failed", slog.Int("rotated", n), sl.Err(err))
+		} else if n > 0 {
+			log.Info("PII key rotation finished", slog.Int("rotated", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
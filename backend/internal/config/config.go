@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -17,9 +19,21 @@ type Config struct {
 	StoragePath    string `yaml:"storage_path" env-required:"true"`
 	MigrationsPath string `yaml:"migrations_path"`
 	HTTPServer     `yaml:"http_server"`
+	GRPCServer     `yaml:"grpc_server"`
 	Postgresql     `yaml:"postgresql"`
 	Redis          `yaml:"redis"`
+	Cache          `yaml:"cache"`
 	Kafka          `yaml:"kafka"`
+	Auth           `yaml:"auth"`
+	Authz          `yaml:"authz"`
+	RateLimit      `yaml:"rate_limit"`
+	PII            `yaml:"pii"`
+	Masking        `yaml:"masking"`
+	Privacy        `yaml:"privacy"`
+	Signing        `yaml:"signing"`
+	Audit          `yaml:"audit"`
+	OpenAPI        `yaml:"openapi"`
+	API            `yaml:"api"`
 }
 
 // HTTPServer holds HTTP server configuration.
@@ -27,6 +41,18 @@ type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"10s"`
 	IdleTimeout time.Duration `yaml:"env" env-default:"60s"`
+	TLS         TLS           `yaml:"tls" env-prefix:"HTTP_TLS_"`
+	// CompressionLevel is the gzip and brotli level of the responses, 0 disables compression.
+	CompressionLevel int `yaml:"compression_level" env-default:"5"`
+}
+
+// GRPCServer holds gRPC server configuration.
+// AuthGroup names the auth route group (see Auth.Groups) that protects the order service.
+type GRPCServer struct {
+	Enabled   bool   `yaml:"enabled" env:"GRPC_ENABLED"`
+	Address   string `yaml:"address" env-default:"localhost:9090"`
+	AuthGroup string `yaml:"auth_group" env-default:"orders"`
+	TLS       TLS    `yaml:"tls" env-prefix:"GRPC_TLS_"`
 }
 
 // Postgresql contains PostgreSQL connection settings.
@@ -37,22 +63,234 @@ type Postgresql struct {
 	SSLmode  string `yaml:"sslmode" env-default:"disable"`
 	Host     string `yaml:"host" env-default:"localhost"`
 	Port     int    `yaml:"port" env-default:"5432"`
+	TLS      TLS    `yaml:"tls" env-prefix:"POSTGRES_TLS_"`
 }
 
 // Redis contains Redis connection settings.
+// Mode selects the topology: single connects to Host:Port, sentinel discovers
+// the MasterName master through the Addrs sentinels, cluster uses Addrs as seed nodes.
 type Redis struct {
-	Host     string `yaml:"host" env:"REDIS_HOST" env-default:"localhost"`
-	Port     string `yaml:"port" env:"REDIS_PORT" env-default:"6379"`
-	Password string `yaml:"password" env:"REDIS_PASSWORD"`
-	DB       int    `yaml:"db" env:"REDIS_DB" env-default:"0"`
+	Mode             string   `yaml:"mode" env:"REDIS_MODE" env-default:"single"` // single, sentinel, cluster
+	Host             string   `yaml:"host" env:"REDIS_HOST" env-default:"localhost"`
+	Port             string   `yaml:"port" env:"REDIS_PORT" env-default:"6379"`
+	Addrs            []string `yaml:"addrs" env:"REDIS_ADDRS" env-separator:","`
+	MasterName       string   `yaml:"master_name" env:"REDIS_MASTER_NAME"`
+	Username         string   `yaml:"username" env:"REDIS_USERNAME"`
+	Password         string   `yaml:"password" env:"REDIS_PASSWORD"`
+	SentinelUsername string   `yaml:"sentinel_username" env:"REDIS_SENTINEL_USERNAME"`
+	SentinelPassword string   `yaml:"sentinel_password" env:"REDIS_SENTINEL_PASSWORD"`
+	DB               int      `yaml:"db" env:"REDIS_DB" env-default:"0"`
+	TLS              TLS      `yaml:"tls" env-prefix:"REDIS_TLS_"`
+}
+
+// TLS contains TLS settings shared by the HTTP server and the Kafka, Redis and
+// PostgreSQL clients. Cert and key identify this side, CAFile verifies the peer.
+type TLS struct {
+	Enabled            bool   `yaml:"enabled" env:"ENABLED"`
+	CertFile           string `yaml:"cert_file" env:"CERT_FILE"`
+	KeyFile            string `yaml:"key_file" env:"KEY_FILE"`
+	CAFile             string `yaml:"ca_file" env:"CA_FILE"`
+	ServerName         string `yaml:"server_name" env:"SERVER_NAME"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env:"INSECURE_SKIP_VERIFY"`
+	// ClientAuth is the client certificate policy of a server: none, request,
+	// require, verify_if_given or require_and_verify. Clients ignore it.
+	ClientAuth string `yaml:"client_auth" env:"CLIENT_AUTH"`
+}
+
+// Cache contains the order cache policy.
+// Bumping KeyVersion moves all entries to a new namespace, which is how
+// a changed model format is rolled out without reading stale payloads.
+type Cache struct {
+	TTL         time.Duration `yaml:"ttl" env:"CACHE_TTL" env-default:"24h"`
+	TTLJitter   time.Duration `yaml:"ttl_jitter" env:"CACHE_TTL_JITTER" env-default:"0s"`
+	StaleRatio  float64       `yaml:"stale_ratio" env:"CACHE_STALE_RATIO" env-default:"0.8"`
+	MissingTTL  time.Duration `yaml:"missing_ttl" env:"CACHE_MISSING_TTL" env-default:"1m"`
+	KeyPrefix   string        `yaml:"key_prefix" env:"CACHE_KEY_PREFIX" env-default:"wb:order"`
+	KeyVersion  int           `yaml:"key_version" env:"CACHE_KEY_VERSION" env-default:"2"`
+	Compression string        `yaml:"compression" env:"CACHE_COMPRESSION" env-default:"none"` // none, zstd, snappy
+	LocalSize   int           `yaml:"local_size" env:"CACHE_LOCAL_SIZE" env-default:"0"`      // 0 disables the in-process cache
+	LocalTTL    time.Duration `yaml:"local_ttl" env:"CACHE_LOCAL_TTL" env-default:"30s"`
 }
 
 // Kafka contains Kafka broker and topic configuration.
 type Kafka struct {
-	Brokers       []string `yaml:"brokers"`
-	ConsumerGroup string   `yaml:"consumer_group"`
-	Topic         string   `yaml:"topic"`
-	DLQTopic      string   `yaml:"dlq_topic"`
+	Brokers       []string      `yaml:"brokers"`
+	ConsumerGroup string        `yaml:"consumer_group"`
+	Topic         string        `yaml:"topic"`
+	DLQTopic      string        `yaml:"dlq_topic"`
+	DrainTimeout  time.Duration `yaml:"drain_timeout" env:"KAFKA_DRAIN_TIMEOUT" env-default:"10s"`    // how long shutdown waits for the message being handled
+	EventsTopic   string        `yaml:"events_topic" env-default:"order-events"`                      // cancellation and refund events
+	OutboxPeriod  time.Duration `yaml:"outbox_period" env:"KAFKA_OUTBOX_PERIOD" env-default:"1s"`     // how often stored events are published
+	LagInterval   time.Duration `yaml:"lag_interval" env:"KAFKA_LAG_INTERVAL" env-default:"15s"`      // how often the consumer lag is queried from the brokers
+	ProducerID    string        `yaml:"producer_id" env:"KAFKA_PRODUCER_ID" env-default:"wb-backend"` // written to message envelopes
+	Codec         Codec         `yaml:"codec"`
+	Producer      KafkaProducer `yaml:"producer"`
+	TLS           TLS           `yaml:"tls" env-prefix:"KAFKA_TLS_"`
+	SASL          SASL          `yaml:"sasl"`
+}
+
+// KafkaProducer contains the delivery settings of the order and event producers.
+// Sends are synchronous, the callers rely on the broker acknowledgement.
+// Async enables the separate batched SendAsync, which reports the outcome to
+// the delivery callback only. kafka-go has no idempotent producer protocol,
+// so a retried batch may still be written twice and consumers drop duplicates by order_uid.
+type KafkaProducer struct {
+	Acks         string        `yaml:"acks" env:"KAFKA_PRODUCER_ACKS" env-default:"one"` // none, one, all
+	MaxAttempts  int           `yaml:"max_attempts" env:"KAFKA_PRODUCER_MAX_ATTEMPTS" env-default:"10"`
+	BatchSize    int           `yaml:"batch_size" env:"KAFKA_PRODUCER_BATCH_SIZE" env-default:"100"`
+	BatchBytes   int64         `yaml:"batch_bytes" env:"KAFKA_PRODUCER_BATCH_BYTES" env-default:"1048576"`
+	BatchTimeout time.Duration `yaml:"batch_timeout" env:"KAFKA_PRODUCER_BATCH_TIMEOUT" env-default:"10ms"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"KAFKA_PRODUCER_WRITE_TIMEOUT" env-default:"5s"`
+	Compression  string        `yaml:"compression" env:"KAFKA_PRODUCER_COMPRESSION" env-default:"none"` // none, gzip, snappy, lz4, zstd
+	Async        bool          `yaml:"async" env:"KAFKA_PRODUCER_ASYNC"`
+}
+
+// Codec selects the wire format of the orders sent to Kafka: application/json,
+// application/x-protobuf or application/avro. The consumer accepts JSON, the
+// sent format and the formats in Accept. Avro schemas are kept in a file-based
+// registry in SchemaRegistryDir, used only if Avro is sent or accepted.
+type Codec struct {
+	ContentType       string   `yaml:"content_type" env:"KAFKA_CONTENT_TYPE" env-default:"application/json"`
+	Accept            []string `yaml:"accept" env:"KAFKA_ACCEPT_CONTENT_TYPES" env-separator:","`
+	SchemaRegistryDir string   `yaml:"schema_registry_dir" env:"KAFKA_SCHEMA_REGISTRY_DIR" env-default:"./schemas"`
+}
+
+// SASL contains Kafka SASL credentials.
+// Mechanism is none, plain, scram-sha-256 or scram-sha-512.
+type SASL struct {
+	Mechanism string `yaml:"mechanism" env:"KAFKA_SASL_MECHANISM" env-default:"none"`
+	Username  string `yaml:"username" env:"KAFKA_SASL_USERNAME"`
+	Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD"`
+}
+
+// Auth contains authentication settings of the HTTP API.
+// Groups maps a route group name to the accepted methods (api_key, jwt);
+// an empty list makes the group public.
+type Auth struct {
+	APIKeys []APIKey             `yaml:"api_keys"`
+	JWT     JWT                  `yaml:"jwt"`
+	Groups  map[string]AuthGroup `yaml:"groups"`
+}
+
+// APIKey is a static key of a service-to-service client.
+// The key itself is never read from the file: it is taken from the KeyEnv environment variable.
+type APIKey struct {
+	Name   string   `yaml:"name"`
+	KeyEnv string   `yaml:"key_env"`
+	Key    string   `yaml:"-"`
+	Roles  []string `yaml:"roles"`
+	Tenant string   `yaml:"tenant"`
+}
+
+// JWT contains bearer token validation settings.
+// Tokens are verified with the HMAC secret, the keys of the local JWKS file, or both.
+type JWT struct {
+	HMACSecret  string        `yaml:"-" env:"AUTH_JWT_HMAC_SECRET"`
+	JWKSFile    string        `yaml:"jwks_file" env:"AUTH_JWT_JWKS_FILE"`
+	Issuer      string        `yaml:"issuer"`
+	Audience    string        `yaml:"audience"`
+	RolesClaim  string        `yaml:"roles_claim" env-default:"roles"`
+	TenantClaim string        `yaml:"tenant_claim" env-default:"tenant"`
+	Leeway      time.Duration `yaml:"leeway" env-default:"30s"`
+}
+
+// AuthGroup lists the authentication methods accepted by a route group.
+// If Roles is set, the caller must also have one of them.
+type AuthGroup struct {
+	Modes []string `yaml:"modes"`
+	Roles []string `yaml:"roles"`
+}
+
+// Authz contains order access rules. It is enabled by default.
+// Access is denied by default: a caller is granted the first rule that
+// matches one of its roles and the order.
+type Authz struct {
+	Enabled bool        `yaml:"enabled" env:"AUTHZ_ENABLED"`
+	Rules   []AuthzRule `yaml:"rules"`
+}
+
+// AuthzRule grants a role access to orders.
+// Scope "all" grants every order, scope "tenant" grants orders whose
+// TenantFields (entry, sm_id) equal the caller's tenant.
+type AuthzRule struct {
+	Role         string   `yaml:"role"`
+	Scope        string   `yaml:"scope"`
+	TenantFields []string `yaml:"tenant_fields"`
+	MaskPII      bool     `yaml:"mask_pii"`
+}
+
+// RateLimit contains per-route request limits.
+// Routes maps a route name to its token bucket; routes without an entry are not limited.
+type RateLimit struct {
+	Enabled bool                  `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	Routes  map[string]RouteLimit `yaml:"routes"`
+}
+
+// RouteLimit allows Burst requests at once, refilled at Rate requests per second.
+// Both must be positive.
+type RouteLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// PII contains encryption settings of customer personal data.
+// Stored deliveries are re-encrypted with the active key every RotationInterval;
+// a zero interval disables the background rotation.
+type PII struct {
+	Enabled          bool          `yaml:"enabled" env:"PII_ENABLED"`
+	KeyringFile      string        `yaml:"keyring_file" env:"PII_KEYRING_FILE"`
+	RotationInterval time.Duration `yaml:"rotation_interval" env-default:"1h"`
+	RotationBatch    int           `yaml:"rotation_batch" env-default:"500"`
+}
+
+// Masking hides personal data in order responses from callers that have none of RevealRoles.
+type Masking struct {
+	Enabled     bool     `yaml:"enabled" env:"MASKING_ENABLED"`
+	RevealRoles []string `yaml:"reveal_roles"`
+}
+
+// Privacy contains settings of data subject requests.
+// PseudonymSecret keys the pseudonyms that replace erased personal data.
+type Privacy struct {
+	PseudonymSecret string `yaml:"pseudonym_secret" env:"PRIVACY_PSEUDONYM_SECRET"`
+}
+
+// Audit contains settings of the audit log.
+// PIIReads also records every read that returns unmasked personal data.
+type Audit struct {
+	PIIReads bool `yaml:"pii_reads" env:"AUDIT_PII_READS"`
+}
+
+// API contains settings of the REST API versions. The unversioned legacy
+// routes, aliases of /api/v1, announce in response headers when they were
+// deprecated and when they are removed.
+type API struct {
+	LegacyDeprecated time.Time `yaml:"legacy_deprecated" env:"API_LEGACY_DEPRECATED" env-layout:"2006-01-02"`
+	LegacySunset     time.Time `yaml:"legacy_sunset" env:"API_LEGACY_SUNSET" env-layout:"2006-01-02"`
+}
+
+// OpenAPI contains settings of the validation against the API specification (api/openapi.yaml).
+// Validate rejects requests that do not match it; ValidateResponses also logs mismatching responses.
+// Meant for dev and test environments.
+type OpenAPI struct {
+	Validate          bool `yaml:"validate" env:"OPENAPI_VALIDATE"`
+	ValidateResponses bool `yaml:"validate_responses" env:"OPENAPI_VALIDATE_RESPONSES"`
+}
+
+// Signing contains the keys verifying internal_signature of orders by order entry.
+// Unsigned selects how orders without a signature are handled: allow, warn or reject.
+type Signing struct {
+	Unsigned string                  `yaml:"unsigned" env:"SIGNING_UNSIGNED" env-default:"allow"`
+	Keys     map[string][]SigningKey `yaml:"keys"`
+}
+
+// SigningKey is a base64 encoded HMAC-SHA256 secret or Ed25519 public key.
+// Ed25519 public keys are set in Key. HMAC secrets are never read from the file:
+// they are taken from the KeyEnv environment variable.
+type SigningKey struct {
+	Algorithm string `yaml:"algorithm"`
+	Key       string `yaml:"key"`
+	KeyEnv    string `yaml:"key_env"`
 }
 
 // MustLoad loads configuration from YAML file and environment variables.
@@ -67,17 +305,138 @@ func MustLoad() *Config {
 		log.Fatalf("config file does not exist: %s", configPath)
 	}
 
-	var cfg Config
+	// authz is enforced unless the file or AUTHZ_ENABLED turns it off;
+	// env-default can't express that, it overrides an explicit false
+	cfg := Config{Authz: Authz{Enabled: true}}
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
 		log.Fatalf("cannot read config: %s", err)
 	}
+	for i, k := range cfg.APIKeys {
+		cfg.APIKeys[i].Key = os.Getenv(k.KeyEnv)
+	}
+	for _, keys := range cfg.Signing.Keys {
+		for i, k := range keys {
+			if k.KeyEnv != "" {
+				keys[i].Key = os.Getenv(k.KeyEnv)
+			}
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid config: %s", err)
+	}
 
 	return &cfg
 }
 
-// DSN returns PostgreSQL connection string in the format required by pgx/driver.
+// validate rejects settings the service cannot run with.
+func (c Config) validate() error {
+	if err := c.Auth.validate(); err != nil {
+		return err
+	}
+	if err := c.Signing.validate(); err != nil {
+		return err
+	}
+	return c.RateLimit.validate()
+}
+
+// validate requires a key for every API key when a route group accepts them.
+func (a Auth) validate() error {
+	for name, group := range a.Groups {
+		if !slices.Contains(group.Modes, "api_key") {
+			continue
+		}
+		if len(a.APIKeys) == 0 {
+			return fmt.Errorf("auth: group %s: no api keys configured", name)
+		}
+		for _, k := range a.APIKeys {
+			if k.Key == "" {
+				return fmt.Errorf("auth: api key %s: %s is not set", k.Name, k.KeyEnv)
+			}
+		}
+	}
+	return nil
+}
+
+// validate requires every HMAC signing secret to be taken from a set environment variable.
+func (s Signing) validate() error {
+	for entry, keys := range s.Keys {
+		for i, k := range keys {
+			if k.Algorithm != "hmac-sha256" {
+				continue
+			}
+			if k.KeyEnv == "" {
+				return fmt.Errorf("signing: entry %s key %d: hmac secret must be set with key_env", entry, i)
+			}
+			if k.Key == "" {
+				return fmt.Errorf("signing: entry %s key %d: %s is not set", entry, i, k.KeyEnv)
+			}
+		}
+	}
+	return nil
+}
+
+// validate requires a positive rate and burst of every limited route.
+func (r RateLimit) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	for name, route := range r.Routes {
+		if route.Rate <= 0 || route.Burst <= 0 {
+			return fmt.Errorf("rate_limit: route %s: rate and burst must be positive", name)
+		}
+	}
+	return nil
+}
+
+// Namespace returns the prefix prepended to every cache key, e.g. "wb:order:v1:".
+func (c Cache) Namespace() string {
+	return fmt.Sprintf("%s:v%d:", c.KeyPrefix, c.KeyVersion)
+}
+
+// InvalidationChannel returns the pub/sub channel used to evict orders on every replica.
+// It does not depend on KeyVersion, so replicas keep invalidating each other during a rollout.
+func (c Cache) InvalidationChannel() string {
+	return c.KeyPrefix + ":invalidate"
+}
+
+// DSN returns PostgreSQL connection string in the key='value' format required by pgx/driver.
+// With TLS enabled, the certificates are passed as sslrootcert, sslcert and sslkey,
+// and sslmode "disable" is raised to verify-full (require if verification is skipped).
 func (p Postgresql) DSN() string {
-	return fmt.Sprintf("user=%s password=%s dbname=%s sslmode=%s host=%s port=%d",
-        p.User, p.Password, p.DBname, p.SSLmode, p.Host, p.Port)
-}
\ No newline at end of file
+	dsn := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=%s host=%s port=%d",
+		dsnValue(p.User), dsnValue(p.Password), dsnValue(p.DBname), dsnValue(p.sslMode()), dsnValue(p.Host), p.Port)
+
+	if !p.TLS.Enabled {
+		return dsn
+	}
+	if p.TLS.CAFile != "" {
+		dsn += " sslrootcert=" + dsnValue(p.TLS.CAFile)
+	}
+	if p.TLS.CertFile != "" {
+		dsn += " sslcert=" + dsnValue(p.TLS.CertFile)
+	}
+	if p.TLS.KeyFile != "" {
+		dsn += " sslkey=" + dsnValue(p.TLS.KeyFile)
+	}
+
+	return dsn
+}
+
+// dsnValue quotes a connection string value, escaping backslashes and quotes,
+// so values with spaces or quotes can't break the string.
+func dsnValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// sslMode returns the sslmode matching the TLS settings.
+func (p Postgresql) sslMode() string {
+	if !p.TLS.Enabled || (p.SSLmode != "" && p.SSLmode != "disable") {
+		return p.SSLmode
+	}
+	if p.TLS.InsecureSkipVerify {
+		return "require"
+	}
+	return "verify-full"
+}
@@ -15,6 +15,19 @@ func TestPostgresql_DSN(t *testing.T) {
 		Host:     "localhost",
 		Port:     5432,
 	}
+	mutual := postgresql
+	mutual.SSLmode = "disable"
+	mutual.TLS = TLS{Enabled: true, CAFile: "/certs/ca.pem", CertFile: "/certs/client.pem", KeyFile: "/certs/client-key.pem"}
+	insecure := postgresql
+	insecure.SSLmode = "disable"
+	insecure.TLS = TLS{Enabled: true, InsecureSkipVerify: true}
+	explicit := postgresql
+	explicit.SSLmode = "verify-ca"
+	explicit.TLS = TLS{Enabled: true, CAFile: "/certs/ca.pem"}
+	special := postgresql
+	special.Password = `p@ss word'\`
+	special.TLS = TLS{Enabled: true, CAFile: "/etc/My Certs/ca.pem", KeyFile: `/certs/o'brien.key`}
+
 	tests := []struct {
 		name string
 		p    Postgresql
@@ -23,7 +36,29 @@ func TestPostgresql_DSN(t *testing.T) {
 		{
 			name: "basic",
 			p:    postgresql,
-			want: "user=user password=password dbname=database sslmode=enable host=localhost port=5432",
+			want: "user='user' password='password' dbname='database' sslmode='enable' host='localhost' port=5432",
+		},
+		{
+			name: "mutual tls",
+			p:    mutual,
+			want: "user='user' password='password' dbname='database' sslmode='verify-full' host='localhost' port=5432 " +
+				"sslrootcert='/certs/ca.pem' sslcert='/certs/client.pem' sslkey='/certs/client-key.pem'",
+		},
+		{
+			name: "tls without verification",
+			p:    insecure,
+			want: "user='user' password='password' dbname='database' sslmode='require' host='localhost' port=5432",
+		},
+		{
+			name: "explicit sslmode",
+			p:    explicit,
+			want: "user='user' password='password' dbname='database' sslmode='verify-ca' host='localhost' port=5432 sslrootcert='/certs/ca.pem'",
+		},
+		{
+			name: "quoted values",
+			p:    special,
+			want: `user='user' password='p@ss word\'\\' dbname='database' sslmode='enable' host='localhost' port=5432 ` +
+				`sslrootcert='/etc/My Certs/ca.pem' sslkey='/certs/o\'brien.key'`,
 		},
 	}
 	for _, tt := range tests {
@@ -34,3 +69,132 @@ func TestPostgresql_DSN(t *testing.T) {
 		})
 	}
 }
+
+func TestCache_Namespace(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Cache
+		want string
+	}{
+		{
+			name: "basic",
+			c:    Cache{KeyPrefix: "wb:order", KeyVersion: 1},
+			want: "wb:order:v1:",
+		},
+		{
+			name: "bumped version",
+			c:    Cache{KeyPrefix: "wb:order", KeyVersion: 2},
+			want: "wb:order:v2:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.Namespace(); got != tt.want {
+				t.Errorf("Cache.Namespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       RateLimit
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			r:    RateLimit{Enabled: true, Routes: map[string]RouteLimit{"get_order": {Rate: 1, Burst: 1}}},
+		},
+		{
+			name:    "zero rate",
+			r:       RateLimit{Enabled: true, Routes: map[string]RouteLimit{"get_order": {Rate: 0, Burst: 1}}},
+			wantErr: true,
+		},
+		{
+			name:    "negative burst",
+			r:       RateLimit{Enabled: true, Routes: map[string]RouteLimit{"get_order": {Rate: 1, Burst: -1}}},
+			wantErr: true,
+		},
+		{
+			name: "disabled",
+			r:    RateLimit{Routes: map[string]RouteLimit{"get_order": {}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.r.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("RateLimit.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuth_Validate(t *testing.T) {
+	groups := map[string]AuthGroup{"orders": {Modes: []string{"api_key"}}}
+	tests := []struct {
+		name    string
+		a       Auth
+		wantErr bool
+	}{
+		{
+			name: "key set",
+			a:    Auth{APIKeys: []APIKey{{Name: "svc", KeyEnv: "SVC_KEY", Key: "secret"}}, Groups: groups},
+		},
+		{
+			name:    "key not set",
+			a:       Auth{APIKeys: []APIKey{{Name: "svc", KeyEnv: "SVC_KEY"}}, Groups: groups},
+			wantErr: true,
+		},
+		{
+			name:    "no keys",
+			a:       Auth{Groups: groups},
+			wantErr: true,
+		},
+		{
+			name: "public group",
+			a:    Auth{APIKeys: []APIKey{{Name: "svc", KeyEnv: "SVC_KEY"}}, Groups: map[string]AuthGroup{"orders": {}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.a.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Auth.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSigning_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Signing
+		wantErr bool
+	}{
+		{
+			name: "hmac secret from env",
+			s:    Signing{Keys: map[string][]SigningKey{"WBIL": {{Algorithm: "hmac-sha256", KeyEnv: "SIGNING_KEY", Key: "secret"}}}},
+		},
+		{
+			name:    "hmac secret not set",
+			s:       Signing{Keys: map[string][]SigningKey{"WBIL": {{Algorithm: "hmac-sha256", KeyEnv: "SIGNING_KEY"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "inline hmac secret",
+			s:       Signing{Keys: map[string][]SigningKey{"WBIL": {{Algorithm: "hmac-sha256", Key: "secret"}}}},
+			wantErr: true,
+		},
+		{
+			name: "inline ed25519 public key",
+			s:    Signing{Keys: map[string][]SigningKey{"WBIL": {{Algorithm: "ed25519", Key: "public"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.s.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Signing.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
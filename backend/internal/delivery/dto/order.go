@@ -0,0 +1,280 @@
+// Package dto defines the wire format of the REST API. It is kept separate
+// from the domain models, so changes of models.Order don't leak to clients.
+package dto
+
+import (
+	"WB/internal/models"
+	"time"
+)
+
+// Order is an order as sent and received by API clients.
+type Order struct {
+	OrderUID          string    `json:"order_uid"`
+	TrackNumber       string    `json:"track_number"`
+	Entry             string    `json:"entry"`
+	Delivery          Delivery  `json:"delivery"`
+	Payment           Payment   `json:"payment"`
+	Items             []Item    `json:"items"`
+	Locale            string    `json:"locale"`
+	InternalSignature string    `json:"internal_signature"`
+	CustomerID        string    `json:"customer_id"`
+	DeliveryService   string    `json:"delivery_service"`
+	Shardkey          string    `json:"shardkey"`
+	SmID              int       `json:"sm_id"`
+	DateCreated       time.Time `json:"date_created"`
+	OofShard          string    `json:"oof_shard"`
+	// Version is set by the server and ignored in requests.
+	Version int `json:"version,omitempty"`
+	// UpdatedAt is set by the server and ignored in requests.
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Delivery holds the recipient details. Personal data fields keep the mask
+// tags of models.Delivery, so they are hidden when logged.
+type Delivery struct {
+	Name    string `json:"name" mask:"partial"`
+	Phone   string `json:"phone" mask:"partial"`
+	Zip     string `json:"zip"`
+	City    string `json:"city"`
+	Address string `json:"address" mask:"partial"`
+	Region  string `json:"region"`
+	Email   string `json:"email" mask:"email"`
+}
+
+// Payment holds the payment details of an order.
+type Payment struct {
+	Transaction  string `json:"transaction" mask:"partial"`
+	RequestID    string `json:"request_id"`
+	Currency     string `json:"currency"`
+	Provider     string `json:"provider"`
+	Amount       int    `json:"amount"`
+	PaymentDt    int64  `json:"payment_dt"`
+	Bank         string `json:"bank"`
+	DeliveryCost int    `json:"delivery_cost"`
+	GoodsTotal   int    `json:"goods_total"`
+	CustomFee    int    `json:"custom_fee"`
+}
+
+// Item is a line of an order.
+type Item struct {
+	ChrtID      int    `json:"chrt_id"`
+	TrackNumber string `json:"track_number"`
+	Price       int    `json:"price"`
+	Rid         string `json:"rid"`
+	Name        string `json:"name"`
+	Sale        int    `json:"sale"`
+	Size        string `json:"size"`
+	TotalPrice  int    `json:"total_price"`
+	NmID        int    `json:"nm_id"`
+	Brand       string `json:"brand"`
+	Status      int    `json:"status"`
+}
+
+// OrderCreated is the response to an accepted order.
+type OrderCreated struct {
+	OrderUID string `json:"order_uid"`
+}
+
+// FromOrder converts the domain model into the wire format.
+func FromOrder(order models.Order) Order {
+	o := Order{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: Delivery{
+			Name:    order.Delivery.Name,
+			Phone:   order.Delivery.Phone,
+			Zip:     order.Delivery.Zip,
+			City:    order.Delivery.City,
+			Address: order.Delivery.Address,
+			Region:  order.Delivery.Region,
+			Email:   order.Delivery.Email,
+		},
+		Payment: Payment{
+			Transaction:  order.Payment.Transaction,
+			RequestID:    order.Payment.RequestID,
+			Currency:     order.Payment.Currency,
+			Provider:     order.Payment.Provider,
+			Amount:       order.Payment.Amount,
+			PaymentDt:    order.Payment.PaymentDt,
+			Bank:         order.Payment.Bank,
+			DeliveryCost: order.Payment.DeliveryCost,
+			GoodsTotal:   order.Payment.GoodsTotal,
+			CustomFee:    order.Payment.CustomFee,
+		},
+		Items:             make([]Item, 0, len(order.Items)),
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerID:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmID:              order.SmID,
+		DateCreated:       order.DateCreated,
+		OofShard:          order.OofShard,
+		Version:           order.Version,
+		UpdatedAt:         order.UpdatedAt,
+	}
+
+	for _, i := range order.Items {
+		o.Items = append(o.Items, Item{
+			ChrtID:      i.ChrtID,
+			TrackNumber: i.TrackNumber,
+			Price:       i.Price,
+			Rid:         i.Rid,
+			Name:        i.Name,
+			Sale:        i.Sale,
+			Size:        i.Size,
+			TotalPrice:  i.TotalPrice,
+			NmID:        i.NmID,
+			Brand:       i.Brand,
+			Status:      i.Status,
+		})
+	}
+
+	return o
+}
+
+// Model converts the wire format into the domain model.
+func (o Order) Model() models.Order {
+	order := models.Order{
+		OrderUID:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: models.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: models.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestID:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       o.Payment.Amount,
+			PaymentDt:    o.Payment.PaymentDt,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: o.Payment.DeliveryCost,
+			GoodsTotal:   o.Payment.GoodsTotal,
+			CustomFee:    o.Payment.CustomFee,
+		},
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.Shardkey,
+		SmID:              o.SmID,
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+	}
+
+	// keep a missing item list nil, so validation reports it as missing
+	if o.Items != nil {
+		order.Items = make([]models.Item, 0, len(o.Items))
+	}
+	for _, i := range o.Items {
+		order.Items = append(order.Items, models.Item{
+			ChrtID:      i.ChrtID,
+			TrackNumber: i.TrackNumber,
+			Price:       i.Price,
+			Rid:         i.Rid,
+			Name:        i.Name,
+			Sale:        i.Sale,
+			Size:        i.Size,
+			TotalPrice:  i.TotalPrice,
+			NmID:        i.NmID,
+			Brand:       i.Brand,
+			Status:      i.Status,
+		})
+	}
+
+	return order
+}
+
+// OrderPatch is a partial update of an order. Omitted fields are left unchanged.
+type OrderPatch struct {
+	Delivery *DeliveryPatch `json:"delivery,omitempty"`
+	Items    []ItemStatus   `json:"items,omitempty"`
+}
+
+// DeliveryPatch holds the changed recipient details.
+type DeliveryPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Phone   *string `json:"phone,omitempty"`
+	Zip     *string `json:"zip,omitempty"`
+	City    *string `json:"city,omitempty"`
+	Address *string `json:"address,omitempty"`
+	Region  *string `json:"region,omitempty"`
+	Email   *string `json:"email,omitempty"`
+}
+
+// ItemStatus sets the status of the order items with ChrtID.
+type ItemStatus struct {
+	ChrtID int `json:"chrt_id"`
+	Status int `json:"status"`
+}
+
+// Model converts the wire format into the domain model.
+func (p OrderPatch) Model() models.OrderPatch {
+	var patch models.OrderPatch
+
+	if d := p.Delivery; d != nil {
+		patch.Delivery = &models.DeliveryPatch{
+			Name:    d.Name,
+			Phone:   d.Phone,
+			Zip:     d.Zip,
+			City:    d.City,
+			Address: d.Address,
+			Region:  d.Region,
+			Email:   d.Email,
+		}
+	}
+
+	for _, s := range p.Items {
+		patch.Items = append(patch.Items, models.ItemStatus{ChrtID: s.ChrtID, Status: s.Status})
+	}
+
+	return patch
+}
+
+// CancelRequest selects the items to cancel or refund. No chrt_ids select the whole order.
+type CancelRequest struct {
+	ChrtIDs []int  `json:"chrt_ids,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Refund is money returned for canceled or refunded items of an order.
+type Refund struct {
+	ID          int64     `json:"id"`
+	OrderUID    string    `json:"order_uid"`
+	Transaction string    `json:"transaction" mask:"partial"`
+	Kind        string    `json:"kind"`
+	ChrtIDs     []int     `json:"chrt_ids"`
+	Amount      int       `json:"amount"`
+	Currency    string    `json:"currency"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Model converts the wire format into the domain model.
+func (c CancelRequest) Model() models.CancelRequest {
+	return models.CancelRequest{ChrtIDs: c.ChrtIDs, Reason: c.Reason}
+}
+
+// FromRefund converts the domain model into the wire format.
+func FromRefund(r models.Refund) Refund {
+	return Refund{
+		ID:          r.ID,
+		OrderUID:    r.OrderUID,
+		Transaction: r.Transaction,
+		Kind:        r.Kind,
+		ChrtIDs:     r.ChrtIDs,
+		Amount:      r.Amount,
+		Currency:    r.Currency,
+		Reason:      r.Reason,
+		CreatedAt:   r.CreatedAt,
+	}
+}
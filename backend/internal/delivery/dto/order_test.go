@@ -0,0 +1,117 @@
+package dto
+
+import (
+	"WB/internal/models"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery:        models.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Email: "test@gmail.com"},
+		Payment:         models.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Amount: 1817, PaymentDt: 1637907727},
+		Items:           []models.Item{{ChrtID: 9934930, Name: "Mascaras", Sale: 30, Status: 202}},
+	}
+}
+
+func TestOrder_RoundTrip(t *testing.T) {
+	order := testOrder()
+
+	assert.Equal(t, order, FromOrder(order).Model())
+}
+
+// wireOrder is testOrder in the v1 wire format the API has always served.
+const wireOrder = `{
+	"order_uid": "b563feb7b2b84b6test",
+	"track_number": "WBILMTESTTRACK",
+	"entry": "WBIL",
+	"delivery": {
+		"name": "Test Testov",
+		"phone": "+9720000000",
+		"zip": "",
+		"city": "Kiryat Mozkin",
+		"address": "",
+		"region": "",
+		"email": "test@gmail.com"
+	},
+	"payment": {
+		"transaction": "b563feb7b2b84b6test",
+		"request_id": "",
+		"currency": "USD",
+		"provider": "",
+		"amount": 1817,
+		"payment_dt": 1637907727,
+		"bank": "",
+		"delivery_cost": 0,
+		"goods_total": 0,
+		"custom_fee": 0
+	},
+	"items": [
+		{
+			"chrt_id": 9934930,
+			"track_number": "",
+			"price": 0,
+			"rid": "",
+			"name": "Mascaras",
+			"sale": 30,
+			"size": "",
+			"total_price": 0,
+			"nm_id": 0,
+			"brand": "",
+			"status": 202
+		}
+	],
+	"locale": "en",
+	"internal_signature": "",
+	"customer_id": "test",
+	"delivery_service": "meest",
+	"shardkey": "9",
+	"sm_id": 99,
+	"date_created": "2021-11-26T06:22:19Z",
+	"oof_shard": "1"
+}`
+
+// TestOrder_WireFormat pins the v1 wire format, so a change of the models
+// can't change the API unnoticed.
+func TestOrder_WireFormat(t *testing.T) {
+	got, err := json.Marshal(FromOrder(testOrder()))
+	require.NoError(t, err)
+	assert.JSONEq(t, wireOrder, string(got))
+
+	var order Order
+	require.NoError(t, json.Unmarshal([]byte(wireOrder), &order))
+	assert.Equal(t, testOrder(), order.Model())
+}
+
+func TestOrder_ModelKeepsMissingItems(t *testing.T) {
+	assert.Nil(t, Order{}.Model().Items)
+	assert.Empty(t, FromOrder(models.Order{}).Items)
+	assert.NotNil(t, FromOrder(models.Order{}).Items)
+}
+
+func TestOrderPatch_Model(t *testing.T) {
+	var p OrderPatch
+	require.NoError(t, json.Unmarshal([]byte(`{"delivery":{"city":"Moscow"},"items":[{"chrt_id":1,"status":0}]}`), &p))
+
+	patch := p.Model()
+
+	require.NotNil(t, patch.Delivery)
+	assert.Equal(t, "Moscow", *patch.Delivery.City)
+	assert.Nil(t, patch.Delivery.Name)
+	assert.Equal(t, []models.ItemStatus{{ChrtID: 1, Status: 0}}, patch.Items)
+	assert.Nil(t, OrderPatch{}.Model().Delivery)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	resp "WB/internal/lib/api/response"
+	"WB/internal/lib/audit"
+	"WB/internal/models"
+	usecase "WB/internal/usecase"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// AuditVerification is the result of an audit log chain check.
+type AuditVerification struct {
+	Verified int  `json:"verified"`
+	Intact   bool `json:"intact"`
+}
+
+// ListAudit returns HTTP handler that lists audit records.
+// The order_uid and actor query parameters filter the records,
+// after_id and limit page through them in ascending id order.
+func ListAudit(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.audit.ListAudit"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		query := r.URL.Query()
+		filter := models.AuditFilter{
+			OrderUID: query.Get("order_uid"),
+			Actor:    query.Get("actor"),
+		}
+
+		var err error
+		if v := query.Get("after_id"); v != "" {
+			if filter.AfterID, err = strconv.ParseInt(v, 10, 64); err != nil || filter.AfterID < 0 {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid after_id parameter"))
+				return
+			}
+		}
+		if v := query.Get("limit"); v != "" {
+			if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid limit parameter"))
+				return
+			}
+		}
+
+		records, err := orderUseCase.ListAuditRecords(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to list audit records", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to list audit records"))
+			return
+		}
+
+		render.JSON(w, r, records)
+	}
+}
+
+// VerifyAudit returns HTTP handler that checks the hash chain of the audit log.
+// A broken chain is reported with 409 Conflict.
+func VerifyAudit(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.audit.VerifyAudit"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		n, err := orderUseCase.VerifyAuditLog(r.Context())
+		if errors.Is(err, audit.ErrChainBroken) {
+			log.Error("audit log chain is broken", slog.Int("verified", n), slog.String("error", err.Error()))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, AuditVerification{Verified: n})
+			return
+		}
+		if err != nil {
+			log.Error("failed to verify audit log", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to verify audit log"))
+			return
+		}
+
+		render.JSON(w, r, AuditVerification{Verified: n, Intact: true})
+	}
+}
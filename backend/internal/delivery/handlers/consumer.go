@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	resp "WB/internal/lib/api/response"
+	"WB/internal/models"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// ConsumerAdmin controls the Kafka consumer of the orders, see kafka.Consumer.
+type ConsumerAdmin interface {
+	State() models.ConsumerStatus
+	Status(ctx context.Context) (models.ConsumerStatus, error)
+	Pause(ctx context.Context) error
+	Resume()
+	ResetOffsets(ctx context.Context, t time.Time) (models.ConsumerStatus, error)
+}
+
+// ConsumerStatus returns HTTP handler that reports the consumer state with
+// the assignment, committed offset and lag of every partition.
+func ConsumerStatus(log *slog.Logger, consumer ConsumerAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consumer.ConsumerStatus"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		status, err := consumer.Status(r.Context())
+		if err != nil {
+			log.Error("failed to get consumer status", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to get consumer status"))
+			return
+		}
+
+		render.JSON(w, r, status)
+	}
+}
+
+// PauseConsumer returns HTTP handler that stops consuming orders on this
+// instance only; the other instances of the group keep consuming.
+// It responds once the order being handled is committed, or with 503 if the
+// request is canceled first, leaving the consumer paused.
+func PauseConsumer(log *slog.Logger, consumer ConsumerAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consumer.PauseConsumer"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		if err := consumer.Pause(r.Context()); err != nil {
+			log.Error("consumer paused, the order being handled is not committed", slog.String("error", err.Error()))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, resp.Error("the order being handled is not committed yet"))
+			return
+		}
+
+		log.Info("consumer paused")
+		render.JSON(w, r, consumer.State())
+	}
+}
+
+// ResumeConsumer returns HTTP handler that continues consuming orders after PauseConsumer.
+func ResumeConsumer(log *slog.Logger, consumer ConsumerAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		consumer.Resume()
+
+		log.Info("consumer resumed",
+			slog.String("op", "handlers.consumer.ResumeConsumer"),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		render.JSON(w, r, consumer.State())
+	}
+}
+
+// ResetConsumerOffsets returns HTTP handler that moves the offsets of the
+// consumer group to the RFC 3339 time query parameter, so the orders written
+// since then are consumed again. Every consumer of the group must be paused,
+// otherwise it responds with 409.
+func ResetConsumerOffsets(log *slog.Logger, consumer ConsumerAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.consumer.ResetConsumerOffsets"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		t, err := time.Parse(time.RFC3339, r.URL.Query().Get("time"))
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid time parameter"))
+			return
+		}
+
+		status, err := consumer.ResetOffsets(r.Context(), t)
+		if errors.Is(err, models.ErrConsumerRunning) {
+			log.Info("offset reset rejected", slog.String("error", err.Error()))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("pause every consumer of the group first"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to reset offsets", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to reset offsets"))
+			return
+		}
+
+		log.Info("consumer offsets reset", slog.Time("time", t))
+		render.JSON(w, r, status)
+	}
+}
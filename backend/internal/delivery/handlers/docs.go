@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"WB/api"
+	"net/http"
+)
+
+// OpenAPISpec returns HTTP handler that serves the OpenAPI specification of the API.
+func OpenAPISpec() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(api.Spec)
+	}
+}
+
+// APIDocs returns HTTP handler that serves the documentation page rendering the specification.
+func APIDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(api.DocsPage)
+	}
+}
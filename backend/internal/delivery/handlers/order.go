@@ -3,10 +3,12 @@
 package handlers
 
 import (
+	"WB/internal/delivery/dto"
+	"WB/internal/delivery/middleware/httpcache"
 	resp "WB/internal/lib/api/response"
 	"WB/internal/models"
 	usecase "WB/internal/usecase"
-	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -17,26 +19,35 @@ import (
 
 // NewOrder returns HTTP handler for creating a new order.
 // It decodes JSON request body, validates it via use case and returns appropriate response.
+// It serves the deprecated /api/create_order route, superseded by v1.CreateOrder.
 func NewOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.order.NewOrder"
 
 		ctx := r.Context()
 
-		var order models.Order
+		var req dto.Order
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		if err := render.DecodeJSON(r.Body, &order); err != nil {
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
 			log.Error("failed to unmarshal order", "op", op, "error", err)
 			render.JSON(w, r, resp.Error(err.Error()))
 			return
 		}
 
-		if err := orderUseCase.CreateOrder(ctx, order); err != nil {
+		order := req.Model()
+		err := orderUseCase.CreateOrder(ctx, order)
+		if errors.Is(err, models.ErrUnsignedOrder) || errors.Is(err, models.ErrInvalidSignature) {
+			log.Warn("order signature rejected", slog.String("order_uid", order.OrderUID), slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+		if err != nil {
 			log.Error("failed create order", "op", op, "error", err)
 			render.JSON(w, r, resp.Error(err.Error()))
 			return
@@ -49,12 +60,11 @@ func NewOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.Handler
 
 // GetOrder returns HTTP handler for retrieving an order by ID.
 // It extracts order ID from URL parameters and returns the order or error.
+// It serves the deprecated /api/orders/{id} route, superseded by v1.GetOrder.
 func GetOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.order.GetOrder"
 
-		var order models.Order
-
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
@@ -67,14 +77,28 @@ func GetOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.Handler
 			return
 		}
 
-		order, err := orderUseCase.GetOrder(context.Background(), OrderID)
+		order, err := orderUseCase.GetOrder(r.Context(), OrderID)
+		if errors.Is(err, models.ErrForbidden) {
+			log.Info("order access denied", slog.String("order_uid", OrderID))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if errors.Is(err, models.ErrOrderNotFound) {
+			log.Info("order not found", slog.String("order_uid", OrderID))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("order not found"))
+			return
+		}
 		if err != nil {
 			log.Error("failed to unmarshal order", "op", op, "error", err)
+			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, resp.Error(err.Error()))
 			return
 		}
 
 		log.Info("order getting success")
-		render.JSON(w, r, order)
+		httpcache.SetLastModified(w.Header(), order.LastModified())
+		render.JSON(w, r, dto.FromOrder(order))
 	}
 }
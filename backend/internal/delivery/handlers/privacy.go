@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	resp "WB/internal/lib/api/response"
+	"WB/internal/lib/audit"
+	usecase "WB/internal/usecase"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// ExportCustomer returns HTTP handler that exports everything stored about a customer.
+func ExportCustomer(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.privacy.ExportCustomer"
+
+		requestID := middleware.GetReqID(r.Context())
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestID),
+		)
+
+		customerID := chi.URLParam(r, "customer_id")
+		if customerID == "" {
+			http.Error(w, "customer_id parameter missing", http.StatusBadRequest)
+			return
+		}
+
+		export, err := orderUseCase.ExportCustomerData(audit.WithRequestID(r.Context(), requestID), customerID)
+		if err != nil {
+			log.Error("failed to export customer data", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to export customer data"))
+			return
+		}
+
+		log.Info("customer data exported", slog.Int("orders", len(export.Orders)))
+		render.JSON(w, r, export)
+	}
+}
+
+// EraseCustomer returns HTTP handler that pseudonymizes or erases the personal data of a customer.
+// The mode query parameter selects the erasure mode, pseudonymize by default.
+func EraseCustomer(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.privacy.EraseCustomer"
+
+		requestID := middleware.GetReqID(r.Context())
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", requestID),
+		)
+
+		customerID := chi.URLParam(r, "customer_id")
+		if customerID == "" {
+			http.Error(w, "customer_id parameter missing", http.StatusBadRequest)
+			return
+		}
+
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = usecase.ErasureModePseudonymize
+		}
+
+		result, err := orderUseCase.EraseCustomerData(audit.WithRequestID(r.Context(), requestID), customerID, mode)
+		if errors.Is(err, usecase.ErrUnknownErasureMode) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+		if err != nil {
+			log.Error("failed to erase customer data", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to erase customer data"))
+			return
+		}
+
+		log.Info("customer data erased", slog.String("mode", mode), slog.Int("orders", len(result.OrderUIDs)))
+		render.JSON(w, r, result)
+	}
+}
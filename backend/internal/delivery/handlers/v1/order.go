@@ -0,0 +1,226 @@
+// Package v1 contains HTTP handlers of the /api/v1 routes. Orders are sent
+// and received in the wire format of package dto.
+package v1
+
+import (
+	"WB/internal/delivery/dto"
+	"WB/internal/delivery/middleware/httpcache"
+	resp "WB/internal/lib/api/response"
+	"WB/internal/models"
+	usecase "WB/internal/usecase"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// CreateOrder returns HTTP handler that accepts an order for processing.
+// Accepted orders get 202 with the order location; invalid ones get 400.
+func CreateOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v1.CreateOrder"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req dto.Order
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Info("failed to decode order", slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid JSON body"))
+			return
+		}
+
+		order := req.Model()
+		err := orderUseCase.CreateOrder(r.Context(), order)
+		if errors.Is(err, models.ErrInvalidOrder) || errors.Is(err, models.ErrUnsignedOrder) || errors.Is(err, models.ErrInvalidSignature) {
+			log.Info("order rejected", slog.String("order_uid", order.OrderUID), slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+		if err != nil {
+			log.Error("failed to create order", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to create order"))
+			return
+		}
+
+		log.Info("order accepted", slog.String("order_uid", order.OrderUID))
+		w.Header().Set("Location", "/api/v1/orders/"+order.OrderUID)
+		render.Status(r, http.StatusAccepted)
+		render.JSON(w, r, dto.OrderCreated{OrderUID: order.OrderUID})
+	}
+}
+
+// GetOrder returns HTTP handler that returns an order by UID.
+// The response carries the ETag and the Last-Modified time of the order,
+// so clients can revalidate it with If-None-Match or If-Modified-Since.
+func GetOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v1.GetOrder"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		orderUID := chi.URLParam(r, "id")
+
+		order, err := orderUseCase.GetOrder(r.Context(), orderUID)
+		if errors.Is(err, models.ErrForbidden) {
+			log.Info("order access denied", slog.String("order_uid", orderUID))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if errors.Is(err, models.ErrOrderNotFound) {
+			log.Info("order not found", slog.String("order_uid", orderUID))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("order not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get order", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to get order"))
+			return
+		}
+
+		body := dto.FromOrder(order)
+		if order.Version > 0 {
+			w.Header().Set("ETag", etag(body))
+		}
+		httpcache.SetLastModified(w.Header(), order.LastModified())
+		render.JSON(w, r, body)
+	}
+}
+
+// UpdateOrder returns HTTP handler that changes the delivery details and the
+// item statuses of an order. The If-Match header must carry the ETag of the
+// order version the change is based on; a stale one gets 412.
+func UpdateOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.v1.UpdateOrder"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		orderUID := chi.URLParam(r, "id")
+
+		version, ok := precondition(w, r)
+		if !ok {
+			return
+		}
+
+		var req dto.OrderPatch
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Info("failed to decode order patch", slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid JSON body"))
+			return
+		}
+
+		order, err := orderUseCase.UpdateOrder(r.Context(), orderUID, version, req.Model())
+		if errors.Is(err, models.ErrInvalidOrder) {
+			log.Info("order update rejected", slog.String("order_uid", orderUID), slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+		if errors.Is(err, models.ErrForbidden) {
+			log.Info("order update denied", slog.String("order_uid", orderUID))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if errors.Is(err, models.ErrOrderNotFound) {
+			log.Info("order not found", slog.String("order_uid", orderUID))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("order not found"))
+			return
+		}
+		if errors.Is(err, models.ErrVersionConflict) {
+			log.Info("order version conflict", slog.String("order_uid", orderUID), slog.Int("version", version))
+			render.Status(r, http.StatusPreconditionFailed)
+			render.JSON(w, r, resp.Error("order was changed, fetch it again"))
+			return
+		}
+		if errors.Is(err, models.ErrStaleCache) {
+			log.Warn("order updated, cache not evicted", slog.String("order_uid", orderUID), slog.String("error", err.Error()))
+		} else if err != nil {
+			log.Error("failed to update order", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to update order"))
+			return
+		}
+
+		log.Info("order updated", slog.String("order_uid", orderUID), slog.Int("version", order.Version))
+		body := dto.FromOrder(order)
+		w.Header().Set("ETag", etag(body))
+		httpcache.SetLastModified(w.Header(), order.LastModified())
+		render.JSON(w, r, body)
+	}
+}
+
+// precondition reads the order version from the required If-Match header.
+// On failure it writes the error response and returns false.
+func precondition(w http.ResponseWriter, r *http.Request) (int, bool) {
+	h := r.Header.Get("If-Match")
+	if h == "" {
+		render.Status(r, http.StatusPreconditionRequired)
+		render.JSON(w, r, resp.Error("If-Match header is required"))
+		return 0, false
+	}
+
+	version, err := ifMatch(h)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error(err.Error()))
+		return 0, false
+	}
+
+	return version, true
+}
+
+// etag returns the entity tag of the order: its version and a hash of its
+// content, which also changes when the personal data is masked or erased.
+func etag(order dto.Order) string {
+	data, _ := json.Marshal(order)
+	sum := sha256.Sum256(data)
+	return `"` + strconv.Itoa(order.Version) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ifMatch parses the If-Match header into the expected order version.
+// Only the version part of the ETag is checked, a bare "<version>" is accepted too.
+// "*" matches any version and yields 0.
+func ifMatch(h string) (int, error) {
+	h = strings.TrimSpace(h)
+	if h == "*" {
+		return 0, nil
+	}
+
+	tag, ok := strings.CutPrefix(h, `"`)
+	if ok {
+		tag, ok = strings.CutSuffix(tag, `"`)
+	}
+	tag, _, _ = strings.Cut(tag, "-")
+	version, err := strconv.Atoi(tag)
+	if !ok || err != nil || version <= 0 {
+		return 0, errors.New("If-Match must be a single strong ETag of the order or *")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"WB/internal/delivery/dto"
+	resp "WB/internal/lib/api/response"
+	"WB/internal/models"
+	usecase "WB/internal/usecase"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// closeFunc cancels or refunds items of an order.
+type closeFunc func(ctx context.Context, orderUID string, version int, req models.CancelRequest) (models.Order, models.Refund, error)
+
+// CancelOrder returns HTTP handler that cancels items of an order, or the
+// whole order, before delivery. Like UpdateOrder it requires If-Match;
+// items that can't be canceled, e.g. delivered ones, get 409.
+func CancelOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return closeItems(log, "handlers.v1.CancelOrder", orderUseCase.CancelOrder)
+}
+
+// RefundOrder returns HTTP handler that refunds delivered items of an order.
+// It follows the rules of CancelOrder.
+func RefundOrder(log *slog.Logger, orderUseCase *usecase.OrderUseCase) http.HandlerFunc {
+	return closeItems(log, "handlers.v1.RefundOrder", orderUseCase.RefundOrder)
+}
+
+func closeItems(log *slog.Logger, op string, fn closeFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		orderUID := chi.URLParam(r, "id")
+
+		version, ok := precondition(w, r)
+		if !ok {
+			return
+		}
+
+		var req dto.CancelRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Info("failed to decode request", slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid JSON body"))
+			return
+		}
+
+		order, refund, err := fn(r.Context(), orderUID, version, req.Model())
+		if errors.Is(err, models.ErrInvalidOrder) {
+			log.Info("request rejected", slog.String("order_uid", orderUID), slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+		if errors.Is(err, models.ErrForbidden) {
+			log.Info("order access denied", slog.String("order_uid", orderUID))
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if errors.Is(err, models.ErrOrderNotFound) {
+			log.Info("order not found", slog.String("order_uid", orderUID))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("order not found"))
+			return
+		}
+		if errors.Is(err, models.ErrOrderState) {
+			log.Info("request rejected", slog.String("order_uid", orderUID), slog.String("error", err.Error()))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+		if errors.Is(err, models.ErrVersionConflict) {
+			log.Info("order version conflict", slog.String("order_uid", orderUID), slog.Int("version", version))
+			render.Status(r, http.StatusPreconditionFailed)
+			render.JSON(w, r, resp.Error("order was changed, fetch it again"))
+			return
+		}
+		if errors.Is(err, models.ErrStaleCache) {
+			log.Warn("order items closed, cache not evicted", slog.String("order_uid", orderUID), slog.String("error", err.Error()))
+		} else if err != nil {
+			log.Error("failed to close order items", slog.String("error", err.Error()))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to process request"))
+			return
+		}
+
+		log.Info("order items closed", slog.String("order_uid", orderUID),
+			slog.String("kind", refund.Kind), slog.Int64("refund_id", refund.ID), slog.Int("amount", refund.Amount))
+		w.Header().Set("ETag", etag(dto.FromOrder(order)))
+		render.JSON(w, r, dto.FromRefund(refund))
+	}
+}
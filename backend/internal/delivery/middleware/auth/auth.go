@@ -0,0 +1,149 @@
+// Package auth provides authentication middleware for chi route groups.
+package auth
+
+import (
+	"WB/internal/config"
+	resp "WB/internal/lib/api/response"
+	"WB/internal/lib/auth"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// Authenticator checks the credentials of a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (auth.Identity, error)
+}
+
+// Middleware builds authentication middleware for the configured route groups.
+type Middleware struct {
+	log     *slog.Logger
+	methods map[string]Authenticator
+	groups  map[string]config.AuthGroup
+}
+
+// NewMiddleware creates authenticators for every method used by the route groups.
+func NewMiddleware(log *slog.Logger, cfg config.Auth) (*Middleware, error) {
+	const op = "middleware.auth.NewMiddleware"
+
+	m := &Middleware{
+		log:     log.With(slog.String("component", "middleware/auth")),
+		methods: make(map[string]Authenticator),
+		groups:  cfg.Groups,
+	}
+
+	for name, group := range cfg.Groups {
+		if len(group.Roles) > 0 && len(group.Modes) == 0 {
+			return nil, fmt.Errorf("%s: group %q: roles require at least one mode", op, name)
+		}
+
+		for _, mode := range group.Modes {
+			if _, ok := m.methods[mode]; ok {
+				continue
+			}
+
+			switch mode {
+			case auth.MethodAPIKey:
+				m.methods[mode] = auth.NewAPIKeys(cfg.APIKeys)
+			case auth.MethodJWT:
+				a, err := auth.NewJWT(cfg.JWT)
+				if err != nil {
+					return nil, fmt.Errorf("%s: group %q: %w", op, name, err)
+				}
+				m.methods[mode] = a
+			default:
+				return nil, fmt.Errorf("%s: group %q: unknown mode %q", op, name, mode)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// ErrForbidden is returned by Authenticate when the caller has none of the group roles.
+var ErrForbidden = errors.New("access denied")
+
+// Public reports whether the route group has no authentication methods.
+func (m *Middleware) Public(name string) bool {
+	return len(m.groups[name].Modes) == 0
+}
+
+// Authenticate checks the credentials of the request with the methods of the
+// route group. Callers without one of the group roles get ErrForbidden.
+func (m *Middleware) Authenticate(name string, r *http.Request) (auth.Identity, error) {
+	group := m.groups[name]
+
+	var lastErr error = auth.ErrNoCredentials
+	for _, mode := range group.Modes {
+		id, err := m.methods[mode].Authenticate(r)
+		if err == nil && len(group.Roles) > 0 && !slices.ContainsFunc(group.Roles, id.HasRole) {
+			return id, ErrForbidden
+		}
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			lastErr = err
+		}
+	}
+
+	return auth.Identity{}, lastErr
+}
+
+// Group returns middleware that lets through requests authenticated by any
+// method configured for the route group and stores the caller identity in
+// the request context. Callers without one of the group roles get 403.
+// Groups without methods are public.
+func (m *Middleware) Group(name string) func(next http.Handler) http.Handler {
+	if m.Public(name) {
+		m.log.Warn("route group is public", slog.String("group", name))
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	challenge := "ApiKey"
+	for _, mode := range m.groups[name].Modes {
+		if mode == auth.MethodJWT {
+			challenge = "Bearer"
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id, err := m.Authenticate(name, r)
+			if errors.Is(err, ErrForbidden) {
+				m.log.Info("request forbidden",
+					slog.String("group", name),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("subject", id.Subject),
+				)
+
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, resp.Error("access denied"))
+				return
+			}
+			if err != nil {
+				m.log.Info("request unauthenticated",
+					slog.String("group", name),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("error", err.Error()),
+				)
+
+				w.Header().Set("WWW-Authenticate", challenge)
+				render.Status(r, http.StatusUnauthorized)
+				render.JSON(w, r, resp.Error("unauthorized"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,48 @@
+// Package deprecation provides middleware announcing deprecated routes to clients.
+package deprecation
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy describes when deprecated routes stop being supported.
+// Zero times are not announced.
+type Policy struct {
+	// Since is when the routes were deprecated, sent in the Deprecation header (RFC 9745).
+	Since time.Time
+	// Sunset is when the routes are removed, sent in the Sunset header (RFC 8594).
+	Sunset time.Time
+}
+
+// New returns middleware that marks responses of deprecated routes. successor
+// returns the replacement of the requested resource, linked with rel="successor-version".
+func New(p Policy, successor func(r *http.Request) string) func(next http.Handler) http.Handler {
+	deprecation := "true"
+	if !p.Since.IsZero() {
+		deprecation = "@" + strconv.FormatInt(p.Since.Unix(), 10)
+	}
+
+	var sunset string
+	if !p.Sunset.IsZero() {
+		sunset = p.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", deprecation)
+			if sunset != "" {
+				h.Set("Sunset", sunset)
+			}
+			if successor != nil {
+				h.Add("Link", "<"+successor(r)+`>; rel="successor-version"`)
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,44 @@
+package deprecation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	successor := func(r *http.Request) string { return "/api/v1/orders" }
+
+	tests := []struct {
+		name          string
+		policy        Policy
+		wantDeprecate string
+		wantSunset    string
+	}{
+		{
+			name:          "dates",
+			policy:        Policy{Since: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Sunset: time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC)},
+			wantDeprecate: "@1792368000",
+			wantSunset:    "Thu, 01 Apr 2027 00:00:00 GMT",
+		},
+		{
+			name:          "no dates",
+			wantDeprecate: "true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			New(tt.policy, successor)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/create_order", nil))
+
+			assert.Equal(t, tt.wantDeprecate, rec.Header().Get("Deprecation"))
+			assert.Equal(t, tt.wantSunset, rec.Header().Get("Sunset"))
+			assert.Equal(t, `</api/v1/orders>; rel="successor-version"`, rec.Header().Get("Link"))
+		})
+	}
+}
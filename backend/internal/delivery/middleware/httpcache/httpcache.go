@@ -0,0 +1,137 @@
+// Package httpcache implements HTTP caching of API responses: Cache-Control
+// policies and conditional GET requests answered with 304 Not Modified.
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Control sets the Cache-Control header of the responses to policy.
+// Inner middleware and handlers may override it.
+func Control(policy string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", policy)
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// SetLastModified sets the Last-Modified header to t, unless t is zero.
+func SetLastModified(h http.Header, t time.Time) {
+	if !t.IsZero() {
+		h.Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Conditional answers GET requests with 304 Not Modified when the response is
+// unchanged since the version the client has, per If-None-Match or, without
+// it, If-Modified-Since. Successful responses without an ETag get one computed
+// from the body, so every route can be revalidated. It must wrap the response
+// compression: the ETag of an encoded response gets the encoding appended,
+// since every encoding is a representation of its own.
+func Conditional(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		h := w.Header()
+		if rec.status != http.StatusOK {
+			w.WriteHeader(rec.status)
+			_, _ = w.Write(rec.body.Bytes())
+			return
+		}
+
+		if h.Get("ETag") == "" {
+			sum := sha256.Sum256(rec.body.Bytes())
+			h.Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+		}
+		if enc := h.Get("Content-Encoding"); enc != "" {
+			h.Set("ETag", strings.TrimSuffix(h.Get("ETag"), `"`)+"-"+enc+`"`)
+		}
+
+		if notModified(r, h) {
+			// a 304 keeps the validators and the caching headers, but describes no content
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// notModified evaluates the preconditions of a GET request against the
+// validators of the response (RFC 9110, section 13.2.2).
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return MatchETag(inm, h.Get("ETag"))
+	}
+
+	ims, lm := r.Header.Get("If-Modified-Since"), h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// MatchETag reports whether the list of entity tags of an If-None-Match
+// header matches etag using the weak comparison.
+func MatchETag(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for tag := range strings.SplitSeq(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder buffers the response body and status; headers go to the wrapped writer.
+type recorder struct {
+	http.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
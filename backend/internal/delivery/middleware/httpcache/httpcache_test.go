@@ -0,0 +1,98 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		list string
+		etag string
+		want bool
+	}{
+		{list: `"1-ab"`, etag: `"1-ab"`, want: true},
+		{list: `"0", "1-ab"`, etag: `"1-ab"`, want: true},
+		{list: `W/"1-ab"`, etag: `"1-ab"`, want: true},
+		{list: `"1-ab"`, etag: `W/"1-ab"`, want: true},
+		{list: `*`, etag: `"1-ab"`, want: true},
+		{list: `"1"`, etag: `"1-ab"`, want: false},
+		{list: `*`, etag: ``, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			assert.Equal(t, tt.want, MatchETag(tt.list, tt.etag))
+		})
+	}
+}
+
+func TestConditional(t *testing.T) {
+	status := http.StatusOK
+	handler := Conditional(Control("no-store")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("hello"))
+	})))
+	serve := func(method, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello", rec.Body.String())
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	etag := rec.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{16}"$`, etag, "computed from the body")
+
+	rec = serve(http.MethodGet, etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Content-Type"))
+
+	rec = serve(http.MethodPost, etag)
+	assert.Equal(t, http.StatusOK, rec.Code, "only GET requests are conditional")
+	assert.Empty(t, rec.Header().Get("ETag"))
+
+	rec = serve(http.MethodGet, "*")
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	status = http.StatusNotFound
+	rec = serve(http.MethodGet, "*")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "hello", rec.Body.String())
+}
+
+func TestConditional_Encoding(t *testing.T) {
+	handler := Conditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"1-ab"`)
+		if enc := r.Header.Get("Accept-Encoding"); enc != "" {
+			w.Header().Set("Content-Encoding", enc)
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	serve := func(encoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("gzip", `"1-ab"`)
+	assert.Equal(t, http.StatusOK, rec.Code, "the identity ETag doesn't match the gzip representation")
+	assert.Equal(t, `"1-ab-gzip"`, rec.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusNotModified, serve("gzip", `"1-ab-gzip"`).Code)
+	assert.Equal(t, http.StatusOK, serve("br", `"1-ab-gzip"`).Code)
+	assert.Equal(t, http.StatusNotModified, serve("", `"1-ab"`).Code)
+}
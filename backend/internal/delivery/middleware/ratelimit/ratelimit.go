@@ -0,0 +1,100 @@
+// Package ratelimit provides per-route rate limiting middleware.
+// Clients are identified by their authenticated identity or, for
+// anonymous requests, by IP address.
+package ratelimit
+
+import (
+	"WB/internal/config"
+	resp "WB/internal/lib/api/response"
+	"WB/internal/lib/auth"
+	"WB/internal/lib/ratelimit"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// Middleware builds rate limiting middleware for named routes.
+type Middleware struct {
+	log     *slog.Logger
+	limiter ratelimit.Limiter
+	routes  map[string]config.RouteLimit
+}
+
+// NewMiddleware creates rate limiting middleware using the limiter for the configured routes.
+// If rate limiting is disabled, no route is limited.
+func NewMiddleware(log *slog.Logger, limiter ratelimit.Limiter, cfg config.RateLimit) *Middleware {
+	m := &Middleware{
+		log:     log.With(slog.String("component", "middleware/ratelimit")),
+		limiter: limiter,
+	}
+	if cfg.Enabled {
+		m.routes = cfg.Routes
+	}
+	return m
+}
+
+// Route returns middleware enforcing the limit configured for the route.
+// It sets X-RateLimit-* headers and answers 429 with Retry-After once the
+// client runs out of tokens. If the limiter fails, the request is let through.
+func (m *Middleware) Route(name string) func(next http.Handler) http.Handler {
+	rl, ok := m.routes[name]
+	if !ok {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limit := ratelimit.Limit{Rate: rl.Rate, Burst: rl.Burst}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			res, err := m.limiter.Allow(r.Context(), name+":"+clientKey(r), limit)
+			if err != nil {
+				m.log.Error("rate limiter failed",
+					slog.String("route", name),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("error", err.Error()),
+				)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				render.Status(r, http.StatusTooManyRequests)
+				render.JSON(w, r, resp.Error("rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// clientKey identifies the caller: by identity when authenticated, by IP otherwise.
+func clientKey(r *http.Request) string {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		return id.Method + ":" + id.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ceilSeconds rounds the duration up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
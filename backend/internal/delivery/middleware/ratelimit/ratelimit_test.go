@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"WB/internal/config"
+	"WB/internal/lib/ratelimit"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_Route(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := NewMiddleware(log, ratelimit.NewLocal(), config.RateLimit{
+		Enabled: true,
+		Routes:  map[string]config.RouteLimit{"get_order": {Rate: 0.5, Burst: 2}},
+	})
+	handler := m.Route("get_order")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("10.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Reset"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+
+	rec = serve("10.0.0.1:1235")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+
+	rec = serve("10.0.0.1:1236")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "4", rec.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":"Error","error":"rate limit exceeded"}`, rec.Body.String())
+
+	rec = serve("10.0.0.2:1234")
+	assert.Equal(t, http.StatusOK, rec.Code, "clients are limited by IP")
+}
+
+func TestMiddleware_RouteNotLimited(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := NewMiddleware(log, ratelimit.NewLocal(), config.RateLimit{
+		Routes: map[string]config.RouteLimit{"get_order": {Rate: 1, Burst: 1}},
+	})
+	handler := m.Route("get_order")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,162 @@
+// Package validate provides middleware that checks requests and responses
+// against the OpenAPI specification of the API. It is meant for dev and test environments.
+package validate
+
+import (
+	resp "WB/internal/lib/api/response"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// Middleware validates the documented routes against the specification.
+type Middleware struct {
+	log       *slog.Logger
+	router    routers.Router
+	responses bool
+	strict    bool
+}
+
+// Option configures Middleware.
+type Option func(*Middleware)
+
+// WithResponses also validates responses. Responses that do not match are logged.
+func WithResponses() Option {
+	return func(m *Middleware) {
+		m.responses = true
+	}
+}
+
+// WithStrictResponses validates responses and replaces those that do not match
+// with 500 Internal Server Error, so tests notice handlers drifting from the specification.
+func WithStrictResponses() Option {
+	return func(m *Middleware) {
+		m.responses = true
+		m.strict = true
+	}
+}
+
+// New creates validation middleware for the specification.
+func New(log *slog.Logger, doc *openapi3.T, opts ...Option) (*Middleware, error) {
+	const op = "middleware.validate.New"
+
+	// servers list public URLs, requests are matched by path only
+	spec := *doc
+	spec.Servers = nil
+
+	router, err := legacy.NewRouter(&spec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m := &Middleware{
+		log:    log.With(slog.String("component", "middleware/validate")),
+		router: router,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
+}
+
+// Handler rejects requests that do not match the specification with 400 Bad Request.
+// Undocumented routes are passed through as is.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		route, params, err := m.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := m.log.With(
+			slog.String("operation", route.Operation.OperationID),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				// credentials are checked by the auth middleware
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			log.Info("request does not match the API specification", slog.String("error", err.Error()))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		if !m.responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		output := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rec.status,
+			Header:                 rec.header,
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		}
+		output.SetBodyBytes(rec.body.Bytes())
+		if err := openapi3filter.ValidateResponse(r.Context(), output); err != nil {
+			log.Error("response does not match the API specification",
+				slog.Int("status", rec.status),
+				slog.String("error", err.Error()),
+			)
+			if m.strict {
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("response does not match the API specification"))
+				return
+			}
+		}
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// recorder buffers a response until it is validated.
+type recorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
@@ -0,0 +1,203 @@
+// Package router assembles the HTTP routes of the WB backend API.
+package router
+
+import (
+	"WB/internal/delivery/handlers"
+	v1 "WB/internal/delivery/handlers/v1"
+	mwAuth "WB/internal/delivery/middleware/auth"
+	"WB/internal/delivery/middleware/deprecation"
+	"WB/internal/delivery/middleware/httpcache"
+	mwLogger "WB/internal/delivery/middleware/logger"
+	mwRateLimit "WB/internal/delivery/middleware/ratelimit"
+	usecase "WB/internal/usecase"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Cache-Control policies of the routes.
+const (
+	// order responses are cached by clients only and revalidated on every use
+	cacheOrder  = "private, no-cache"
+	cacheNone   = "no-store"
+	cacheStatic = "public, max-age=300"
+)
+
+type options struct {
+	compression int
+	deprecation deprecation.Policy
+	auth        *mwAuth.Middleware
+	rateLimit   *mwRateLimit.Middleware
+	validation  func(next http.Handler) http.Handler
+	middleware  []func(next http.Handler) http.Handler
+	consumer    handlers.ConsumerAdmin
+}
+
+// Option configures the router.
+type Option func(*options)
+
+// WithAuth authenticates the route groups. Without it every route is public.
+func WithAuth(m *mwAuth.Middleware) Option {
+	return func(o *options) {
+		o.auth = m
+	}
+}
+
+// WithRateLimit limits the request rate of the order routes.
+func WithRateLimit(m *mwRateLimit.Middleware) Option {
+	return func(o *options) {
+		o.rateLimit = m
+	}
+}
+
+// WithCompression compresses text responses with brotli or gzip at level,
+// as the client accepts. Level 0, the default, disables compression.
+func WithCompression(level int) Option {
+	return func(o *options) {
+		o.compression = level
+	}
+}
+
+// WithDeprecation sets when the unversioned legacy routes were deprecated and are removed.
+func WithDeprecation(p deprecation.Policy) Option {
+	return func(o *options) {
+		o.deprecation = p
+	}
+}
+
+// WithValidation validates authenticated API requests, e.g. against the OpenAPI specification.
+func WithValidation(mw func(next http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.validation = mw
+	}
+}
+
+// WithConsumerAdmin adds the admin routes of the order consumer.
+func WithConsumerAdmin(c handlers.ConsumerAdmin) Option {
+	return func(o *options) {
+		o.consumer = c
+	}
+}
+
+// WithMiddleware adds middleware applied to every route, such as metrics or CORS.
+func WithMiddleware(mw ...func(next http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+// New creates the router with every API route, the OpenAPI specification at
+// /openapi.yaml, its documentation page at /docs, metrics and the web interface.
+func New(log *slog.Logger, orderUseCase *usecase.OrderUseCase, opts ...Option) chi.Router {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	router := chi.NewRouter()
+
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Logger)
+	router.Use(mwLogger.New(log))
+	router.Use(middleware.Recoverer)
+	router.Use(o.middleware...)
+	// outside the validation, which doesn't know the 304 responses, and
+	// outside the compression, so every encoding gets an ETag of its own
+	router.Use(httpcache.Conditional)
+	if o.compression > 0 {
+		router.Use(compressor(o.compression).Handler)
+	}
+
+	router.With(httpcache.Control(cacheNone)).Handle("/metrics", promhttp.Handler())
+	router.With(httpcache.Control(cacheStatic)).Get("/openapi.yaml", handlers.OpenAPISpec())
+	router.With(httpcache.Control(cacheStatic)).Get("/docs", handlers.APIDocs())
+
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Use(httpcache.Control(cacheNone))
+		r.Use(o.group("orders")...)
+		r.With(o.route("create_order")...).Post("/orders", v1.CreateOrder(log, orderUseCase))
+		r.With(o.route("get_order")...).With(httpcache.Control(cacheOrder)).
+			Get("/orders/{id}", v1.GetOrder(log, orderUseCase))
+		r.With(o.route("update_order")...).Patch("/orders/{id}", v1.UpdateOrder(log, orderUseCase))
+		r.With(o.route("cancel_order")...).Post("/orders/{id}/cancel", v1.CancelOrder(log, orderUseCase))
+		r.With(o.route("cancel_order")...).Post("/orders/{id}/refund", v1.RefundOrder(log, orderUseCase))
+	})
+	// legacy routes, kept as aliases of /api/v1 until the sunset
+	router.Group(func(r chi.Router) {
+		r.Use(httpcache.Control(cacheNone))
+		r.Use(o.group("orders")...)
+		r.With(o.legacy("/api/v1/orders")...).With(o.route("create_order")...).
+			Post("/api/create_order", handlers.NewOrder(log, orderUseCase))
+		r.With(o.legacy("/api/v1/orders/{id}")...).With(o.route("get_order")...).With(httpcache.Control(cacheOrder)).
+			Get("/api/orders/{id}", handlers.GetOrder(log, orderUseCase))
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(httpcache.Control(cacheNone))
+		r.Use(o.group("admin")...)
+		r.Get("/api/admin/customers/{customer_id}/export", handlers.ExportCustomer(log, orderUseCase))
+		r.Post("/api/admin/customers/{customer_id}/erase", handlers.EraseCustomer(log, orderUseCase))
+		r.Get("/api/admin/audit", handlers.ListAudit(log, orderUseCase))
+		r.Get("/api/admin/audit/verify", handlers.VerifyAudit(log, orderUseCase))
+		if o.consumer != nil {
+			r.Get("/api/admin/consumer", handlers.ConsumerStatus(log, o.consumer))
+			r.Post("/api/admin/consumer/pause", handlers.PauseConsumer(log, o.consumer))
+			r.Post("/api/admin/consumer/resume", handlers.ResumeConsumer(log, o.consumer))
+			r.Post("/api/admin/consumer/reset", handlers.ResetConsumerOffsets(log, o.consumer))
+		}
+	})
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "web/static/index.html")
+	})
+
+	return router
+}
+
+// compressor returns the compression middleware of the text responses.
+func compressor(level int) *middleware.Compressor {
+	c := middleware.NewCompressor(level, "application/json", "application/yaml", "text/html", "text/plain")
+	c.SetEncoder("br", func(w io.Writer, level int) io.Writer {
+		return brotli.NewWriterLevel(w, level)
+	})
+	return c
+}
+
+// group returns the middleware of an authenticated route group.
+func (o *options) group(name string) []func(next http.Handler) http.Handler {
+	var mw []func(next http.Handler) http.Handler
+	if o.auth != nil {
+		mw = append(mw, o.auth.Group(name))
+	}
+	if o.validation != nil {
+		mw = append(mw, o.validation)
+	}
+	return mw
+}
+
+// legacy returns the middleware of a deprecated route superseded by the
+// successor route pattern; its URL parameters are filled in from the request.
+func (o *options) legacy(successor string) []func(next http.Handler) http.Handler {
+	return []func(next http.Handler) http.Handler{deprecation.New(o.deprecation, func(r *http.Request) string {
+		link := successor
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			for i, key := range rctx.URLParams.Keys {
+				link = strings.ReplaceAll(link, "{"+key+"}", url.PathEscape(rctx.URLParams.Values[i]))
+			}
+		}
+		return link
+	})}
+}
+
+// route returns the middleware of a rate limited route.
+func (o *options) route(name string) []func(next http.Handler) http.Handler {
+	if o.rateLimit == nil {
+		return nil
+	}
+	return []func(next http.Handler) http.Handler{o.rateLimit.Route(name)}
+}
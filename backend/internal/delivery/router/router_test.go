@@ -0,0 +1,691 @@
+package router
+
+import (
+	"WB/api"
+	"WB/internal/config"
+	mwAuth "WB/internal/delivery/middleware/auth"
+	"WB/internal/delivery/middleware/deprecation"
+	"WB/internal/delivery/middleware/validate"
+	"WB/internal/lib/audit"
+	"WB/internal/models"
+	usecase "WB/internal/usecase"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// store is an in-memory order repository and cache.
+type store struct {
+	mu     sync.Mutex
+	orders map[string]models.Order
+	cached map[string][]byte
+	sent   map[string][]byte
+	events []models.OrderEvent // outbox
+	audit  []models.AuditRecord
+	paused bool
+	// cacheDown fails the evictions from the cache
+	cacheDown bool
+}
+
+func newStore() *store {
+	return &store{
+		orders: make(map[string]models.Order),
+		cached: make(map[string][]byte),
+		sent:   make(map[string][]byte),
+	}
+}
+
+func (s *store) NewOrder(order models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order.Version = max(order.Version, models.InitialVersion)
+	s.orders[order.OrderUID] = order
+	return nil
+}
+
+func (s *store) UpdateOrder(order models.Order, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.orders[order.OrderUID]
+	if !ok {
+		return models.ErrOrderNotFound
+	}
+	if stored.Version != version {
+		return models.ErrVersionConflict
+	}
+	stored.Delivery = order.Delivery
+	stored.Items = order.Items
+	stored.UpdatedAt = order.UpdatedAt
+	stored.Version++
+	s.orders[order.OrderUID] = stored
+	return nil
+}
+
+func (s *store) GetOrder(orderID string) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, models.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (s *store) SaveRefund(order models.Order, version int, refund models.Refund, event models.OrderEvent) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.orders[order.OrderUID]
+	if !ok {
+		return 0, models.ErrOrderNotFound
+	}
+	if stored.Version != version {
+		return 0, models.ErrVersionConflict
+	}
+	stored.Items = order.Items
+	stored.Payment = order.Payment
+	stored.UpdatedAt = order.UpdatedAt
+	stored.Version++
+	s.orders[order.OrderUID] = stored
+	event.Refund.ID = int64(len(s.events) + 1)
+	s.events = append(s.events, event)
+	return event.Refund.ID, nil
+}
+
+func (s *store) ListCustomerOrders(customerID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uids []string
+	for uid, order := range s.orders {
+		if order.CustomerID == customerID {
+			uids = append(uids, uid)
+		}
+	}
+	slices.Sort(uids)
+	return uids, nil
+}
+
+func (s *store) ListCustomerRefunds(customerID string) ([]models.Refund, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refunds := []models.Refund{}
+	for _, event := range s.events {
+		if s.orders[event.Refund.OrderUID].CustomerID == customerID {
+			refunds = append(refunds, event.Refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (s *store) ListDeliveries(afterUID string, limit int) ([]models.DeliveryRecord, error) {
+	return nil, nil
+}
+
+func (s *store) UpdateDeliveryPII(order models.Order, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.orders[order.OrderUID]
+	if stored.Version != version {
+		return models.ErrVersionConflict
+	}
+	stored.Delivery = order.Delivery
+	stored.UpdatedAt = order.UpdatedAt
+	stored.Version++
+	s.orders[order.OrderUID] = stored
+	return nil
+}
+
+type cache store
+
+func (c *cache) GetOrder(ctx context.Context, orderUID string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cached[orderUID], nil
+}
+
+func (c *cache) SetOrder(ctx context.Context, orderUID string, data []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cached[orderUID] = data
+	return nil
+}
+
+func (c *cache) DeleteOrder(ctx context.Context, orderUID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cacheDown {
+		return errors.New("cache down")
+	}
+	delete(c.cached, orderUID)
+	return nil
+}
+
+type broker store
+
+func (b *broker) Send(ctx context.Context, key string, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent[key] = value
+	return nil
+}
+
+func (b *broker) Close() error { return nil }
+
+type auditLog store
+
+func (l *auditLog) AppendAudit(ctx context.Context, recs ...models.AuditRecord) ([]models.AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rec := range recs {
+		prev := ""
+		if len(l.audit) > 0 {
+			prev = l.audit[len(l.audit)-1].Hash
+		}
+		rec.ID = int64(len(l.audit) + 1)
+		audit.Seal(prev, &rec)
+		l.audit = append(l.audit, rec)
+	}
+	return l.audit[len(l.audit)-len(recs):], nil
+}
+
+func (l *auditLog) ListAudit(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := []models.AuditRecord{}
+	for _, rec := range l.audit {
+		if rec.ID > filter.AfterID && (filter.OrderUID == "" || rec.OrderUID == filter.OrderUID) && len(records) < filter.Limit {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+type consumer store
+
+func (c *consumer) State() models.ConsumerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return models.ConsumerStatus{Group: "orders-group", Topic: "orders", Paused: c.paused}
+}
+
+func (c *consumer) Status(ctx context.Context) (models.ConsumerStatus, error) {
+	status := c.State()
+	status.Partitions = []models.PartitionState{
+		{Partition: 0, Member: "kafka-go-1", Host: "/127.0.0.1", Committed: 5, HighWatermark: 7, Lag: 2},
+		{Partition: 1, Committed: -1, HighWatermark: 3, Lag: 3},
+	}
+	return status, nil
+}
+
+func (c *consumer) Pause(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+	return nil
+}
+
+func (c *consumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+}
+
+func (c *consumer) ResetOffsets(ctx context.Context, t time.Time) (models.ConsumerStatus, error) {
+	if !c.State().Paused {
+		return c.State(), models.ErrConsumerRunning
+	}
+	return c.Status(ctx)
+}
+
+func newTestRouter(t *testing.T) (chi.Router, *store) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	doc, err := api.Load()
+	require.NoError(t, err)
+	validation, err := validate.New(log, doc, validate.WithStrictResponses())
+	require.NoError(t, err)
+	authMiddleware, err := mwAuth.NewMiddleware(log, config.Auth{})
+	require.NoError(t, err)
+
+	s := newStore()
+	uc := usecase.NewOrderUseCase(s, (*cache)(s), (*broker)(s), usecase.WithAuditLog((*auditLog)(s)))
+
+	return New(log, uc,
+		WithAuth(authMiddleware),
+		WithValidation(validation.Handler),
+		WithCompression(5),
+		WithConsumerAdmin((*consumer)(s)),
+		WithDeprecation(deprecation.Policy{
+			Since:  time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+			Sunset: time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC),
+		}),
+	), s
+}
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				Rid:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{[^}]+\}`)
+
+// TestRoutes_MatchSpec fails when an API route is added to the router but not
+// to the specification, or the other way round.
+func TestRoutes_MatchSpec(t *testing.T) {
+	router, _ := newTestRouter(t)
+	doc, err := api.Load()
+	require.NoError(t, err)
+
+	var routes []string
+	err = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") {
+			routes = append(routes, method+" "+pathParam.ReplaceAllString(route, "{}"))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var documented []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+pathParam.ReplaceAllString(path, "{}"))
+		}
+	}
+
+	assert.ElementsMatch(t, documented, routes)
+}
+
+// TestHandlers_MatchSpec runs requests through the strict validation middleware:
+// a handler response that does not match the specification turns into 500.
+func TestHandlers_MatchSpec(t *testing.T) {
+	router, s := newTestRouter(t)
+
+	order := testOrder()
+	body, err := json.Marshal(order)
+	require.NoError(t, err)
+	require.NoError(t, s.NewOrder(order))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		ifMatch    string
+		wantStatus int
+	}{
+		{name: "create order v1", method: http.MethodPost, target: "/api/v1/orders", body: string(body), wantStatus: http.StatusAccepted},
+		{name: "create invalid order v1", method: http.MethodPost, target: "/api/v1/orders", body: `{"order_uid": "x", "items": []}`, wantStatus: http.StatusBadRequest},
+		{name: "get order v1", method: http.MethodGet, target: "/api/v1/orders/" + order.OrderUID, wantStatus: http.StatusOK},
+		{name: "get missing order v1", method: http.MethodGet, target: "/api/v1/orders/missing", wantStatus: http.StatusNotFound},
+		{name: "update order v1", method: http.MethodPatch, target: "/api/v1/orders/" + order.OrderUID, body: `{"delivery": {"city": "Moscow"}}`, ifMatch: `"1"`, wantStatus: http.StatusOK},
+		{name: "update stale order v1", method: http.MethodPatch, target: "/api/v1/orders/" + order.OrderUID, body: `{"delivery": {"city": "Moscow"}}`, ifMatch: `"1"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "update order without If-Match v1", method: http.MethodPatch, target: "/api/v1/orders/" + order.OrderUID, body: `{"delivery": {"city": "Moscow"}}`, wantStatus: http.StatusPreconditionRequired},
+		{name: "update unknown item v1", method: http.MethodPatch, target: "/api/v1/orders/" + order.OrderUID, body: `{"items": [{"chrt_id": 1, "status": 0}]}`, ifMatch: "*", wantStatus: http.StatusBadRequest},
+		{name: "refund undelivered item v1", method: http.MethodPost, target: "/api/v1/orders/" + order.OrderUID + "/refund", body: `{"chrt_ids": [9934930]}`, ifMatch: "*", wantStatus: http.StatusConflict},
+		{name: "cancel unknown item v1", method: http.MethodPost, target: "/api/v1/orders/" + order.OrderUID + "/cancel", body: `{"chrt_ids": [1]}`, ifMatch: "*", wantStatus: http.StatusBadRequest},
+		{name: "cancel stale order v1", method: http.MethodPost, target: "/api/v1/orders/" + order.OrderUID + "/cancel", body: `{}`, ifMatch: `"1"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "cancel order v1", method: http.MethodPost, target: "/api/v1/orders/" + order.OrderUID + "/cancel", body: `{"reason": "test"}`, ifMatch: `"2"`, wantStatus: http.StatusOK},
+		{name: "cancel canceled order v1", method: http.MethodPost, target: "/api/v1/orders/" + order.OrderUID + "/cancel", body: `{}`, ifMatch: "*", wantStatus: http.StatusConflict},
+		{name: "update missing order v1", method: http.MethodPatch, target: "/api/v1/orders/missing", body: `{"delivery": {"city": "Moscow"}}`, ifMatch: "*", wantStatus: http.StatusNotFound},
+		{name: "create order", method: http.MethodPost, target: "/api/create_order", body: string(body), wantStatus: http.StatusOK},
+		{name: "create invalid order", method: http.MethodPost, target: "/api/create_order", body: `{"order_uid": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "get order", method: http.MethodGet, target: "/api/orders/" + order.OrderUID, wantStatus: http.StatusOK},
+		{name: "get missing order", method: http.MethodGet, target: "/api/orders/missing", wantStatus: http.StatusNotFound},
+		{name: "export customer", method: http.MethodGet, target: "/api/admin/customers/test/export", wantStatus: http.StatusOK},
+		{name: "erase customer", method: http.MethodPost, target: "/api/admin/customers/test/erase?mode=erase", wantStatus: http.StatusOK},
+		{name: "erase unknown mode", method: http.MethodPost, target: "/api/admin/customers/test/erase?mode=shred", wantStatus: http.StatusBadRequest},
+		{name: "list audit", method: http.MethodGet, target: "/api/admin/audit?order_uid=" + order.OrderUID + "&limit=10", wantStatus: http.StatusOK},
+		{name: "list audit invalid limit", method: http.MethodGet, target: "/api/admin/audit?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "verify audit", method: http.MethodGet, target: "/api/admin/audit/verify", wantStatus: http.StatusOK},
+		{name: "consumer status", method: http.MethodGet, target: "/api/admin/consumer", wantStatus: http.StatusOK},
+		{name: "reset running consumer", method: http.MethodPost, target: "/api/admin/consumer/reset?time=2026-10-19T00:00:00Z", wantStatus: http.StatusConflict},
+		{name: "pause consumer", method: http.MethodPost, target: "/api/admin/consumer/pause", wantStatus: http.StatusOK},
+		{name: "reset consumer without time", method: http.MethodPost, target: "/api/admin/consumer/reset", wantStatus: http.StatusBadRequest},
+		{name: "reset consumer", method: http.MethodPost, target: "/api/admin/consumer/reset?time=2026-10-19T00:00:00Z", wantStatus: http.StatusOK},
+		{name: "resume consumer", method: http.MethodPost, target: "/api/admin/consumer/resume", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestUpdateOrder_OptimisticConcurrency(t *testing.T) {
+	router, s := newTestRouter(t)
+	order := testOrder()
+	require.NoError(t, s.NewOrder(order))
+	target := "/api/v1/orders/" + order.OrderUID
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+	patch := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the first read caches the order
+	etag := get().Header().Get("ETag")
+	require.Regexp(t, `^"1-[0-9a-f]{16}"$`, etag)
+
+	rec := patch(etag, `{"delivery": {"city": "Moscow"}, "items": [{"chrt_id": 9934930, "status": 300}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	updated := rec.Header().Get("ETag")
+	assert.Regexp(t, `^"2-[0-9a-f]{16}"$`, updated)
+
+	rec = get()
+	assert.Equal(t, updated, rec.Header().Get("ETag"), "the update returns the ETag of the stored order")
+	var got models.Order
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "Moscow", got.Delivery.City)
+	assert.Equal(t, 300, got.Items[0].Status)
+
+	rec = patch(etag, `{"delivery": {"city": "Kazan"}}`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = patch(`"2"`, `{"delivery": {"email": "not an email"}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Moscow", s.orders[order.OrderUID].Delivery.City)
+
+	actions := make([]string, 0, len(s.audit))
+	for _, rec := range s.audit {
+		actions = append(actions, rec.Action)
+	}
+	assert.Equal(t, []string{usecase.ActionOrderUpdate}, actions)
+}
+
+func TestUpdateOrder_StaleCache(t *testing.T) {
+	router, s := newTestRouter(t)
+	order := testOrder()
+	require.NoError(t, s.NewOrder(order))
+	s.cacheDown = true
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/orders/"+order.OrderUID, strings.NewReader(`{"delivery": {"city": "Moscow"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Regexp(t, `^"2-`, rec.Header().Get("ETag"))
+	require.Len(t, s.audit, 1)
+	assert.Equal(t, usecase.ActionOrderUpdate, s.audit[0].Action)
+}
+
+func TestCancelOrder_Items(t *testing.T) {
+	router, s := newTestRouter(t)
+	order := testOrder()
+	order.Items = append(order.Items, models.Item{
+		ChrtID: 42, TrackNumber: "WBILMTESTTRACK", Price: 100, Rid: "r2", Name: "Brush",
+		Size: "0", TotalPrice: 100, NmID: 1, Brand: "Brand", Status: models.ItemStatusDelivered,
+	})
+	order.Payment.GoodsTotal += 100
+	order.Payment.Amount += 100
+	require.NoError(t, s.NewOrder(order))
+	target := "/api/v1/orders/" + order.OrderUID
+
+	post := func(action, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target+"/"+action, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", "*")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the delivered item blocks cancellation of the whole order
+	rec := post("cancel", `{}`)
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+
+	rec = post("cancel", `{"chrt_ids": [9934930], "reason": "out of stock"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Regexp(t, `^"2-`, rec.Header().Get("ETag"))
+	var refund models.Refund
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &refund))
+	assert.Equal(t, models.RefundKindCancel, refund.Kind)
+	assert.Equal(t, 317, refund.Amount)
+	assert.Equal(t, order.Payment.Transaction, refund.Transaction)
+
+	rec = post("refund", `{}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &refund))
+	assert.Equal(t, models.RefundKindReturn, refund.Kind)
+	assert.Equal(t, []int{42}, refund.ChrtIDs)
+
+	stored := s.orders[order.OrderUID]
+	assert.Equal(t, 0, stored.Payment.GoodsTotal)
+	assert.Equal(t, 1500, stored.Payment.Amount, "the delivery of the refunded item is kept")
+	assert.Equal(t, 3, stored.Version)
+
+	require.Len(t, s.events, 2)
+	assert.Equal(t, models.EventItemsCanceled, s.events[0].Type)
+	assert.Equal(t, models.EventOrderRefunded, s.events[1].Type)
+}
+
+func TestCancelOrder_StaleCache(t *testing.T) {
+	router, s := newTestRouter(t)
+	order := testOrder()
+	require.NoError(t, s.NewOrder(order))
+	s.cacheDown = true
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders/"+order.OrderUID+"/cancel", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Regexp(t, `^"2-`, rec.Header().Get("ETag"))
+	require.Len(t, s.audit, 1)
+	assert.Equal(t, usecase.ActionOrderCancel, s.audit[0].Action)
+}
+
+func TestGetOrder_Conditional(t *testing.T) {
+	router, s := newTestRouter(t)
+	order := testOrder()
+	require.NoError(t, s.NewOrder(order))
+
+	get := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, target := range []string{"/api/v1/orders/" + order.OrderUID, "/api/orders/" + order.OrderUID} {
+		t.Run(target, func(t *testing.T) {
+			rec := get(target, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			etag, lastModified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+			require.NotEmpty(t, etag)
+			assert.Equal(t, "Fri, 26 Nov 2021 06:22:19 GMT", lastModified, "never updated orders were last modified on creation")
+			assert.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"))
+
+			rec = get(target, map[string]string{"If-None-Match": `"stale", W/` + etag})
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+			assert.Empty(t, rec.Body.Bytes())
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			assert.Equal(t, "private, no-cache", rec.Header().Get("Cache-Control"))
+
+			rec = get(target, map[string]string{"If-Modified-Since": lastModified})
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+
+			rec = get(target, map[string]string{"If-None-Match": `"stale"`, "If-Modified-Since": lastModified})
+			assert.Equal(t, http.StatusOK, rec.Code, "If-None-Match takes precedence")
+		})
+	}
+
+	target := "/api/v1/orders/" + order.OrderUID
+	etag := get(target, nil).Header().Get("ETag")
+	req := httptest.NewRequest(http.MethodPatch, target, strings.NewReader(`{"delivery": {"city": "Moscow"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = get(target, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, rec.Code, "a changed order is sent again")
+	modified, err := http.ParseTime(rec.Header().Get("Last-Modified"))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), modified, time.Minute)
+}
+
+func TestCompression(t *testing.T) {
+	router, s := newTestRouter(t)
+	order := testOrder()
+	require.NoError(t, s.NewOrder(order))
+
+	for _, encoding := range []string{"br", "gzip"} {
+		t.Run(encoding, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+order.OrderUID, nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, encoding, rec.Header().Get("Content-Encoding"))
+			var r io.Reader = brotli.NewReader(rec.Body)
+			if encoding == "gzip" {
+				zr, err := gzip.NewReader(rec.Body)
+				require.NoError(t, err)
+				r = zr
+			}
+			var got models.Order
+			require.NoError(t, json.NewDecoder(r).Decode(&got))
+			assert.Equal(t, order.OrderUID, got.OrderUID)
+
+			etag := rec.Header().Get("ETag")
+			assert.Regexp(t, `^"1-[0-9a-f]{16}-`+encoding+`"$`, etag, "every encoding has an ETag of its own")
+			req.Header.Set("If-None-Match", etag)
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+		})
+	}
+}
+
+func TestLegacyRoutes_Deprecated(t *testing.T) {
+	router, s := newTestRouter(t)
+	require.NoError(t, s.NewOrder(testOrder()))
+
+	tests := []struct {
+		target   string
+		wantLink string
+	}{
+		{target: "/api/orders/b563feb7b2b84b6test", wantLink: `</api/v1/orders/b563feb7b2b84b6test>; rel="successor-version"`},
+		{target: "/api/v1/orders/b563feb7b2b84b6test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.wantLink, rec.Header().Get("Link"))
+			if tt.wantLink == "" {
+				assert.Empty(t, rec.Header().Get("Deprecation"))
+				return
+			}
+			assert.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+			assert.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+		})
+	}
+}
+
+func TestDocs(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, target := range []string{"/openapi.yaml", "/docs"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code, target)
+		assert.NotEmpty(t, rec.Body.Bytes(), target)
+	}
+}
@@ -0,0 +1,278 @@
+package rpc
+
+import (
+	orderv1 "WB/api/gen/order/v1"
+	mwAuth "WB/internal/delivery/middleware/auth"
+	"WB/internal/lib/auth"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDHeader carries the request ID in call metadata, like the HTTP API does.
+const requestIDHeader = "x-request-id"
+
+// RequestID returns the request ID of the call.
+func RequestID(ctx context.Context) string {
+	return middleware.GetReqID(ctx)
+}
+
+// withRequestID stores the request ID of the caller, or a new one, in the
+// context under the key the HTTP middleware uses and sends it back in the header.
+func withRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestIDHeader); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		b := make([]byte, 8)
+		_, _ = rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+	return context.WithValue(ctx, middleware.RequestIDKey, id)
+}
+
+// UnaryRequestID assigns a request ID to unary calls.
+func UnaryRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withRequestID(ctx), req)
+	}
+}
+
+// StreamRequestID assigns a request ID to streaming calls.
+func StreamRequestID() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+	}
+}
+
+// UnaryRecoverer turns a panic of a unary call into an Internal error, so it
+// does not crash the server.
+func UnaryRecoverer(log *slog.Logger) grpc.UnaryServerInterceptor {
+	log = log.With(slog.String("component", "rpc/recoverer"))
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, log, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoverer turns a panic of a streaming call into an Internal error.
+func StreamRecoverer(log *slog.Logger) grpc.StreamServerInterceptor {
+	log = log.With(slog.String("component", "rpc/recoverer"))
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ss.Context(), log, info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, log *slog.Logger, method string, p any) error {
+	log.Error("call panicked",
+		slog.String("method", method),
+		slog.String("request_id", RequestID(ctx)),
+		slog.Any("panic", p),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// UnaryLogger logs every unary call with its status code and duration.
+func UnaryLogger(log *slog.Logger) grpc.UnaryServerInterceptor {
+	log = log.With(slog.String("component", "rpc/logger"))
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, log, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamLogger logs every streaming call with its status code and duration.
+func StreamLogger(log *slog.Logger) grpc.StreamServerInterceptor {
+	log = log.With(slog.String("component", "rpc/logger"))
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), log, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, log *slog.Logger, method string, start time.Time, err error) {
+	log.Info("call completed",
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.String("request_id", RequestID(ctx)),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+// Metrics counts the handled calls and measures their duration.
+type Metrics struct {
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics creates the call metrics and registers them with reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of gRPC calls completed on the server.",
+		}, []string{"method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Duration of gRPC calls handled by the server.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	reg.MustRegister(m.handled, m.duration)
+	return m
+}
+
+func (m *Metrics) observe(method string, start time.Time, err error) {
+	m.handled.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// Unary returns the interceptor measuring unary calls.
+func (m *Metrics) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// Stream returns the interceptor measuring streaming calls.
+func (m *Metrics) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+// Auth authenticates calls to the order service with the methods of an auth
+// route group. Credentials are read from the call metadata: x-api-key or
+// authorization, the same headers the HTTP API uses. The health and reflection
+// services are not authenticated.
+type Auth struct {
+	log    *slog.Logger
+	authn  *mwAuth.Middleware
+	group  string
+	public bool
+}
+
+// NewAuth creates the auth interceptors for the route group.
+func NewAuth(log *slog.Logger, authn *mwAuth.Middleware, group string) *Auth {
+	a := &Auth{
+		log:    log.With(slog.String("component", "rpc/auth")),
+		authn:  authn,
+		group:  group,
+		public: authn.Public(group),
+	}
+	if a.public {
+		a.log.Warn("order service is public", slog.String("group", group))
+	}
+	return a
+}
+
+// authenticate stores the caller identity in the context of calls to the order service.
+func (a *Auth) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if a.public || !strings.HasPrefix(method, "/"+orderv1.OrderService_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+
+	r := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: method}, Header: make(http.Header)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, vs := range md {
+			for _, v := range vs {
+				r.Header.Add(k, v)
+			}
+		}
+	}
+
+	id, err := a.authn.Authenticate(a.group, r.WithContext(ctx))
+	if errors.Is(err, mwAuth.ErrForbidden) {
+		a.log.Info("call forbidden",
+			slog.String("method", method),
+			slog.String("request_id", RequestID(ctx)),
+			slog.String("subject", id.Subject),
+		)
+		return nil, status.Error(codes.PermissionDenied, "access denied")
+	}
+	if err != nil {
+		a.log.Info("call unauthenticated",
+			slog.String("method", method),
+			slog.String("request_id", RequestID(ctx)),
+			slog.String("error", err.Error()),
+		)
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	return auth.WithIdentity(ctx, id), nil
+}
+
+// Unary returns the interceptor authenticating unary calls.
+func (a *Auth) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the interceptor authenticating streaming calls.
+func (a *Auth) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream overrides the context of a server stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
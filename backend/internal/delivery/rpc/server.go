@@ -0,0 +1,176 @@
+// Package rpc implements the gRPC API of the WB backend service.
+// It shares usecase.OrderUseCase with the REST handlers.
+package rpc
+
+import (
+	orderv1 "WB/api/gen/order/v1"
+	"WB/internal/lib/codec"
+	"WB/internal/models"
+	usecase "WB/internal/usecase"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// OrderService implements orderv1.OrderServiceServer.
+type OrderService struct {
+	orderv1.UnimplementedOrderServiceServer
+
+	log          *slog.Logger
+	orderUseCase *usecase.OrderUseCase
+}
+
+// NewOrderService creates the order service.
+func NewOrderService(log *slog.Logger, orderUseCase *usecase.OrderUseCase) *OrderService {
+	return &OrderService{
+		log:          log,
+		orderUseCase: orderUseCase,
+	}
+}
+
+// CreateOrder validates the order and sends it to Kafka for processing.
+func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrderRequest) (*orderv1.CreateOrderResponse, error) {
+	const op = "rpc.OrderService.CreateOrder"
+
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+
+	order := codec.OrderFromProto(req.GetOrder())
+	if err := s.orderUseCase.CreateOrder(ctx, order); err != nil {
+		return nil, s.status(ctx, op, err)
+	}
+
+	return &orderv1.CreateOrderResponse{OrderUid: order.OrderUID}, nil
+}
+
+// GetOrder returns the order. Personal data may be masked.
+func (s *OrderService) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	const op = "rpc.OrderService.GetOrder"
+
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+
+	order, err := s.orderUseCase.GetOrder(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, s.status(ctx, op, err)
+	}
+
+	return codec.OrderToProto(order), nil
+}
+
+// ListOrders streams the orders of the customer readable by the caller.
+func (s *OrderService) ListOrders(req *orderv1.ListOrdersRequest, stream grpc.ServerStreamingServer[orderv1.Order]) error {
+	const op = "rpc.OrderService.ListOrders"
+
+	if req.GetCustomerId() == "" {
+		return status.Error(codes.InvalidArgument, "customer_id is required")
+	}
+
+	ctx := stream.Context()
+	err := s.orderUseCase.ListOrders(ctx, req.GetCustomerId(), func(order models.Order) error {
+		return stream.Send(codec.OrderToProto(order))
+	})
+	if err != nil {
+		return s.status(ctx, op, err)
+	}
+
+	return nil
+}
+
+// status maps a use case error to a gRPC status. Internal errors are logged
+// and hidden from the caller.
+func (s *OrderService) status(ctx context.Context, op string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	switch {
+	case errors.Is(err, models.ErrInvalidOrder),
+		errors.Is(err, models.ErrUnsignedOrder),
+		errors.Is(err, models.ErrInvalidSignature):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrOrderNotFound):
+		return status.Error(codes.NotFound, "order not found")
+	case errors.Is(err, models.ErrForbidden):
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+
+	s.log.Error("request failed",
+		slog.String("op", op),
+		slog.String("request_id", RequestID(ctx)),
+		slog.String("error", err.Error()),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
+
+type options struct {
+	tls                *tls.Config
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+}
+
+// Option configures the gRPC server.
+type Option func(*options)
+
+// WithTLS serves the API over TLS.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tls = cfg
+	}
+}
+
+// WithInterceptors adds interceptors, run in the order they are added.
+func WithInterceptors(unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return func(o *options) {
+		o.unaryInterceptors = append(o.unaryInterceptors, unary)
+		o.streamInterceptors = append(o.streamInterceptors, stream)
+	}
+}
+
+// NewServer creates the gRPC server with the order service, the health service
+// and server reflection. A panic of any call is recovered as an Internal error.
+// Every call gets a request ID and is logged before the interceptors added by
+// options run.
+func NewServer(log *slog.Logger, orderUseCase *usecase.OrderUseCase, opts ...Option) *grpc.Server {
+	o := options{
+		unaryInterceptors:  []grpc.UnaryServerInterceptor{UnaryRecoverer(log), UnaryRequestID(), UnaryLogger(log)},
+		streamInterceptors: []grpc.StreamServerInterceptor{StreamRecoverer(log), StreamRequestID(), StreamLogger(log)},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(o.unaryInterceptors...),
+		grpc.ChainStreamInterceptor(o.streamInterceptors...),
+	}
+	if o.tls != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(o.tls)))
+	}
+
+	srv := grpc.NewServer(serverOpts...)
+
+	orderv1.RegisterOrderServiceServer(srv, NewOrderService(log, orderUseCase))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(orderv1.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	reflection.Register(srv)
+
+	return srv
+}
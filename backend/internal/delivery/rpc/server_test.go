@@ -0,0 +1,301 @@
+package rpc
+
+import (
+	orderv1 "WB/api/gen/order/v1"
+	"WB/internal/config"
+	mwAuth "WB/internal/delivery/middleware/auth"
+	"WB/internal/lib/codec"
+	"WB/internal/models"
+	usecase "WB/internal/usecase"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// panicOrderUID makes store.GetOrder panic.
+const panicOrderUID = "panic-order"
+
+// store is an in-memory order repository, cache and message broker.
+type store struct {
+	mu     sync.Mutex
+	orders map[string]models.Order
+	sent   map[string][]byte
+}
+
+func (s *store) NewOrder(order models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.OrderUID] = order
+	return nil
+}
+
+func (s *store) GetOrder(orderID string) (models.Order, error) {
+	if orderID == panicOrderUID {
+		panic("store failure")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, models.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (s *store) ListCustomerOrders(customerID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uids []string
+	for uid, order := range s.orders {
+		if order.CustomerID == customerID {
+			uids = append(uids, uid)
+		}
+	}
+	return uids, nil
+}
+
+func (s *store) Send(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent[key] = value
+	return nil
+}
+
+func (s *store) Close() error { return nil }
+
+// noCache misses every lookup.
+type noCache struct{}
+
+func (noCache) GetOrder(context.Context, string) ([]byte, error)              { return nil, nil }
+func (noCache) SetOrder(context.Context, string, []byte, time.Duration) error { return nil }
+func (noCache) DeleteOrder(context.Context, string) error                     { return nil }
+
+type testServer struct {
+	client  orderv1.OrderServiceClient
+	health  healthpb.HealthClient
+	store   *store
+	metrics *Metrics
+}
+
+func newTestServer(t *testing.T, authCfg config.Auth) testServer {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := &store{orders: make(map[string]models.Order), sent: make(map[string][]byte)}
+	uc := usecase.NewOrderUseCase(s, noCache{}, s)
+
+	authn, err := mwAuth.NewMiddleware(log, authCfg)
+	require.NoError(t, err)
+	grpcAuth := NewAuth(log, authn, "orders")
+	metrics := NewMetrics(prometheus.NewRegistry())
+
+	srv := NewServer(log, uc,
+		WithInterceptors(metrics.Unary(), metrics.Stream()),
+		WithInterceptors(grpcAuth.Unary(), grpcAuth.Stream()),
+	)
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return testServer{
+		client:  orderv1.NewOrderServiceClient(conn),
+		health:  healthpb.NewHealthClient(conn),
+		store:   s,
+		metrics: metrics,
+	}
+}
+
+func testOrder(uid string) models.Order {
+	return models.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				Rid:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+	}
+}
+
+func TestConvert_RoundTrip(t *testing.T) {
+	order := testOrder("round-trip")
+
+	assert.Equal(t, order, codec.OrderFromProto(codec.OrderToProto(order)))
+}
+
+func TestOrderService_CreateOrder(t *testing.T) {
+	ts := newTestServer(t, config.Auth{})
+	ctx := context.Background()
+
+	var header metadata.MD
+	resp, err := ts.client.CreateOrder(ctx, &orderv1.CreateOrderRequest{Order: codec.OrderToProto(testOrder("grpc-order"))}, grpc.Header(&header))
+
+	require.NoError(t, err)
+	assert.Equal(t, "grpc-order", resp.GetOrderUid())
+	assert.Contains(t, ts.store.sent, "grpc-order")
+	assert.NotEmpty(t, header.Get(requestIDHeader))
+
+	_, err = ts.client.CreateOrder(ctx, &orderv1.CreateOrderRequest{Order: &orderv1.Order{OrderUid: "invalid"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(ts.metrics.handled.WithLabelValues(orderv1.OrderService_CreateOrder_FullMethodName, codes.OK.String())))
+	assert.Equal(t, 1.0, testutil.ToFloat64(ts.metrics.handled.WithLabelValues(orderv1.OrderService_CreateOrder_FullMethodName, codes.InvalidArgument.String())))
+}
+
+func TestOrderService_GetOrder(t *testing.T) {
+	ts := newTestServer(t, config.Auth{})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIDHeader, "req-42")
+	order := testOrder("stored-order")
+	require.NoError(t, ts.store.NewOrder(order))
+
+	var header metadata.MD
+	got, err := ts.client.GetOrder(ctx, &orderv1.GetOrderRequest{OrderUid: "stored-order"}, grpc.Header(&header))
+
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(codec.OrderToProto(order), got))
+	assert.Equal(t, []string{"req-42"}, header.Get(requestIDHeader))
+
+	_, err = ts.client.GetOrder(ctx, &orderv1.GetOrderRequest{OrderUid: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestOrderService_RecoversPanic(t *testing.T) {
+	ts := newTestServer(t, config.Auth{})
+
+	_, err := ts.client.GetOrder(context.Background(), &orderv1.GetOrderRequest{OrderUid: panicOrderUID})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	_, err = ts.health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err, "server keeps serving after a panic")
+}
+
+func TestOrderService_ListOrders(t *testing.T) {
+	ts := newTestServer(t, config.Auth{})
+	for _, uid := range []string{"a", "b"} {
+		require.NoError(t, ts.store.NewOrder(testOrder(uid)))
+	}
+	other := testOrder("c")
+	other.CustomerID = "other"
+	require.NoError(t, ts.store.NewOrder(other))
+
+	stream, err := ts.client.ListOrders(context.Background(), &orderv1.ListOrdersRequest{CustomerId: "test"})
+	require.NoError(t, err)
+
+	var uids []string
+	for {
+		order, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		uids = append(uids, order.GetOrderUid())
+	}
+
+	assert.ElementsMatch(t, []string{"a", "b"}, uids)
+}
+
+func TestAuth(t *testing.T) {
+	ts := newTestServer(t, config.Auth{
+		APIKeys: []config.APIKey{{Name: "svc", Key: "svc-key", Roles: []string{"service"}}, {Name: "other", Key: "other-key"}},
+		Groups:  map[string]config.AuthGroup{"orders": {Modes: []string{"api_key"}, Roles: []string{"service"}}},
+	})
+	require.NoError(t, ts.store.NewOrder(testOrder("secured")))
+	req := &orderv1.GetOrderRequest{OrderUid: "secured"}
+
+	tests := []struct {
+		name     string
+		key      string
+		wantCode codes.Code
+	}{
+		{name: "no credentials", wantCode: codes.Unauthenticated},
+		{name: "invalid key", key: "wrong", wantCode: codes.Unauthenticated},
+		{name: "missing role", key: "other-key", wantCode: codes.PermissionDenied},
+		{name: "authorized", key: "svc-key", wantCode: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.key != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", tt.key)
+			}
+
+			_, err := ts.client.GetOrder(ctx, req)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+
+			stream, err := ts.client.ListOrders(ctx, &orderv1.ListOrdersRequest{CustomerId: "test"})
+			require.NoError(t, err)
+			_, err = stream.Recv()
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+
+	t.Run("health is public", func(t *testing.T) {
+		resp, err := ts.health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: orderv1.OrderService_ServiceDesc.ServiceName})
+
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	})
+}
@@ -0,0 +1,93 @@
+// Package audit implements the hash chain of the audit log.
+//
+// Each record is hashed together with the hash of the previous record, so
+// changing, inserting or deleting a stored record breaks every following link.
+package audit
+
+import (
+	"WB/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// ErrChainBroken is returned when the stored records do not form a valid chain.
+var ErrChainBroken = errors.New("audit chain broken")
+
+// Precision is the timestamp resolution preserved by the storage.
+// Record times are truncated to it before hashing.
+const Precision = time.Microsecond
+
+// Seal links rec to the previous record and computes its hash.
+func Seal(prevHash string, rec *models.AuditRecord) {
+	rec.Time = rec.Time.UTC().Truncate(Precision)
+	rec.PrevHash = prevHash
+	rec.Hash = Hash(*rec)
+}
+
+// Hash returns the hash of the record content and its link. ID and Hash are not covered.
+func Hash(rec models.AuditRecord) string {
+	// json.Marshal of a struct is deterministic, so it is used as the canonical form.
+	// Fields added later are omitted when empty to keep older records verifiable.
+	data, _ := json.Marshal(struct {
+		Time      string `json:"time"`
+		RequestID string `json:"request_id"`
+		Actor     string `json:"actor"`
+		Action    string `json:"action"`
+		Resource  string `json:"resource"`
+		OrderUID  string `json:"order_uid,omitempty"`
+		Details   string `json:"details"`
+		Diff      string `json:"diff,omitempty"`
+		PrevHash  string `json:"prev_hash"`
+	}{
+		Time:      rec.Time.UTC().Truncate(Precision).Format(time.RFC3339Nano),
+		RequestID: rec.RequestID,
+		Actor:     rec.Actor,
+		Action:    rec.Action,
+		Resource:  rec.Resource,
+		OrderUID:  rec.OrderUID,
+		Details:   rec.Details,
+		Diff:      rec.Diff,
+		PrevHash:  rec.PrevHash,
+	})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that records, ordered by ID, form an unbroken chain starting after prevHash.
+func Verify(prevHash string, records []models.AuditRecord) error {
+	for _, rec := range records {
+		if rec.PrevHash != prevHash {
+			return fmt.Errorf("%w: record %d is not linked to its predecessor", ErrChainBroken, rec.ID)
+		}
+		if Hash(rec) != rec.Hash {
+			return fmt.Errorf("%w: record %d was modified", ErrChainBroken, rec.ID)
+		}
+		prevHash = rec.Hash
+	}
+
+	return nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID recorded in audit records.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx, falling back to the one
+// assigned by the RequestID middleware of the HTTP router.
+func RequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return middleware.GetReqID(ctx)
+}
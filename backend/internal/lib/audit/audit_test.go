@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"WB/internal/models"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func chain(n int) []models.AuditRecord {
+	records := make([]models.AuditRecord, n)
+	prev := ""
+	for i := range records {
+		records[i] = models.AuditRecord{
+			ID:        int64(i + 1),
+			Time:      time.Date(2026, 10, 19, 12, 0, i, 123456789, time.UTC),
+			RequestID: "req",
+			Actor:     "admin",
+			Action:    "privacy.export",
+			Resource:  "customer:test",
+			Details:   `{"orders":1}`,
+		}
+		Seal(prev, &records[i])
+		prev = records[i].Hash
+	}
+	return records
+}
+
+func TestSeal(t *testing.T) {
+	records := chain(2)
+
+	assert.Equal(t, "", records[0].PrevHash)
+	assert.Equal(t, records[0].Hash, records[1].PrevHash)
+	assert.Equal(t, 123456000, records[0].Time.Nanosecond())
+	assert.Len(t, records[0].Hash, 64)
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(records []models.AuditRecord) []models.AuditRecord
+		wantErr bool
+	}{
+		{
+			name:   "intact",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord { return records },
+		},
+		{
+			name: "modified",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				records[1].Actor = "someone else"
+				return records
+			},
+			wantErr: true,
+		},
+		{
+			name: "deleted",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				return append(records[:1], records[2:]...)
+			},
+			wantErr: true,
+		},
+		{
+			name: "rehashed",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				records[1].Details = `{"orders":0}`
+				records[1].Hash = Hash(records[1])
+				return records
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify("", tt.tamper(chain(3)))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrChainBroken)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := models.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: models.Delivery{Name: "Test Testov", City: "Kiryat Mozkin"},
+		Items:    []models.Item{{ChrtID: 1, Status: 202}},
+	}
+	after := before
+	after.Delivery.Name = "Anna Petrova"
+	after.Delivery.City = "Moscow"
+	after.Items = []models.Item{{ChrtID: 1, Status: 300}, {ChrtID: 2}}
+
+	got, err := Diff(before, after)
+	assert.NoError(t, err)
+
+	var changes map[string]Change
+	assert.NoError(t, json.Unmarshal([]byte(got), &changes))
+
+	assert.Equal(t, Change{Before: "T***", After: "A***"}, changes["delivery.name"])
+	assert.Equal(t, Change{Before: "Kiryat Mozkin", After: "Moscow"}, changes["delivery.city"])
+	assert.Equal(t, Change{Before: float64(202), After: float64(300)}, changes["items.0.status"])
+	assert.Equal(t, Change{After: float64(2)}, changes["items.1.chrt_id"])
+	assert.NotContains(t, changes, "order_uid")
+	assert.NotContains(t, got, "Testov")
+}
+
+func TestDiff_MaskedLookAlike(t *testing.T) {
+	before := models.Delivery{Name: "Test Testov", Address: "Street 1"}
+	after := models.Delivery{Name: "Test Testova", Address: "Street 2"}
+
+	got, err := Diff(before, after)
+	assert.NoError(t, err)
+
+	var changes map[string]Change
+	assert.NoError(t, json.Unmarshal([]byte(got), &changes))
+	assert.Equal(t, Change{Before: "T***", After: "T***"}, changes["name"])
+	assert.Contains(t, changes, "address")
+	assert.NotContains(t, got, "Street")
+}
+
+func TestDiff_Created(t *testing.T) {
+	got, err := Diff(nil, models.Delivery{City: "Moscow"})
+	assert.NoError(t, err)
+
+	var changes map[string]Change
+	assert.NoError(t, json.Unmarshal([]byte(got), &changes))
+	assert.Equal(t, Change{After: "Moscow"}, changes["city"])
+	assert.Equal(t, Change{After: ""}, changes["name"])
+}
+
+func TestHash_OptionalFields(t *testing.T) {
+	rec := chain(1)[0]
+	withOrder := rec
+	withOrder.OrderUID = "b563feb7b2b84b6test"
+
+	assert.Equal(t, rec.Hash, Hash(rec))
+	assert.NotEqual(t, rec.Hash, Hash(withOrder))
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "router-id")
+
+	assert.Equal(t, "router-id", RequestID(ctx))
+	assert.Equal(t, "explicit-id", RequestID(WithRequestID(ctx, "explicit-id")))
+	assert.Equal(t, "", RequestID(context.Background()))
+}
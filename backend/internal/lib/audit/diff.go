@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"WB/internal/lib/masking"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Change is the value of a field before and after a mutation.
+// A missing side is null.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff compares the JSON representations of before and after and returns the
+// changed fields as a JSON object keyed by dotted path, e.g. "delivery.name"
+// or "items.0.status". The raw values are compared, but the changes of fields
+// tagged with mask are recorded masked, so a correction of personal data is
+// logged without keeping the data. Either side may be nil.
+func Diff(before, after any) (string, error) {
+	b, err := flatten(before)
+	if err != nil {
+		return "", fmt.Errorf("diff before: %w", err)
+	}
+	a, err := flatten(after)
+	if err != nil {
+		return "", fmt.Errorf("diff after: %w", err)
+	}
+	mb, err := flatten(masking.Apply(before))
+	if err != nil {
+		return "", fmt.Errorf("diff before: %w", err)
+	}
+	ma, err := flatten(masking.Apply(after))
+	if err != nil {
+		return "", fmt.Errorf("diff after: %w", err)
+	}
+
+	changes := make(map[string]Change)
+	for path, bv := range b {
+		av, ok := a[path]
+		if !ok || av != bv {
+			changes[path] = Change{Before: mb[path], After: ma[path]}
+		}
+	}
+	for path := range a {
+		if _, ok := b[path]; !ok {
+			changes[path] = Change{After: ma[path]}
+		}
+	}
+
+	// map keys are sorted by json.Marshal, so the result is stable
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return "", fmt.Errorf("diff: %w", err)
+	}
+	return string(data), nil
+}
+
+// flatten returns the leaf values of v's JSON form by dotted path.
+func flatten(v any) (map[string]any, error) {
+	out := make(map[string]any)
+	if v == nil {
+		return out, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+
+	walk("", tree, out)
+	return out, nil
+}
+
+func walk(prefix string, v any, out map[string]any) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			walk(join(k), child, out)
+		}
+	case []any:
+		for i, child := range t {
+			walk(join(strconv.Itoa(i)), child, out)
+		}
+	default:
+		out[prefix] = t
+	}
+}
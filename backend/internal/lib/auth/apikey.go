@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"WB/internal/config"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the header carrying a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates service-to-service calls by static API keys.
+type APIKeys struct {
+	keys []apiKey
+}
+
+type apiKey struct {
+	hash     [sha256.Size]byte
+	identity Identity
+}
+
+// NewAPIKeys creates an authenticator for the configured keys.
+func NewAPIKeys(keys []config.APIKey) *APIKeys {
+	a := &APIKeys{keys: make([]apiKey, 0, len(keys))}
+	for _, k := range keys {
+		a.keys = append(a.keys, apiKey{
+			hash: sha256.Sum256([]byte(k.Key)),
+			identity: Identity{
+				Subject: k.Name,
+				Method:  MethodAPIKey,
+				Roles:   k.Roles,
+				Tenant:  k.Tenant,
+			},
+		})
+	}
+	return a
+}
+
+// Authenticate checks the X-API-Key header or an "Authorization: ApiKey <key>" header.
+func (a *APIKeys) Authenticate(r *http.Request) (Identity, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+			key = v
+		}
+	}
+	if key == "" {
+		return Identity{}, ErrNoCredentials
+	}
+
+	// Compare hashes in constant time to not leak key prefixes through timing.
+	hash := sha256.Sum256([]byte(key))
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], k.hash[:]) == 1 {
+			return k.identity, nil
+		}
+	}
+
+	return Identity{}, ErrInvalidCredentials
+}
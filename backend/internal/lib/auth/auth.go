@@ -0,0 +1,51 @@
+// Package auth authenticates API callers and carries their identity
+// through the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Authentication methods.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrNoCredentials is returned when the request carries no credentials for the method.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when the credentials are present but not accepted.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity describes an authenticated caller.
+type Identity struct {
+	Subject string
+	Method  string
+	Roles   []string
+	// Tenant is the seller the caller acts for, if any.
+	Tenant string
+	// Claims holds all JWT claims; it is nil for API keys.
+	Claims map[string]any
+}
+
+// HasRole reports whether the caller has the role.
+func (i Identity) HasRole(role string) bool {
+	return slices.Contains(i.Roles, role)
+}
+
+type ctxKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the identity stored in ctx, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
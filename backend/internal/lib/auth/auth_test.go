@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"WB/internal/config"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys_Authenticate(t *testing.T) {
+	a := NewAPIKeys([]config.APIKey{
+		{Name: "billing", Key: "secret-key", Roles: []string{"service"}, Tenant: "WBIL"},
+	})
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		want    Identity
+		wantErr error
+	}{
+		{
+			name:   "x-api-key header",
+			header: APIKeyHeader,
+			value:  "secret-key",
+			want:   Identity{Subject: "billing", Method: MethodAPIKey, Roles: []string{"service"}, Tenant: "WBIL"},
+		},
+		{
+			name:   "authorization header",
+			header: "Authorization",
+			value:  "ApiKey secret-key",
+			want:   Identity{Subject: "billing", Method: MethodAPIKey, Roles: []string{"service"}, Tenant: "WBIL"},
+		},
+		{
+			name:    "wrong key",
+			header:  APIKeyHeader,
+			value:   "other-key",
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "no key",
+			wantErr: ErrNoCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+
+			got, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestJWT_HMAC(t *testing.T) {
+	a, err := NewJWT(config.JWT{
+		HMACSecret:  "secret",
+		Issuer:      "wb-auth",
+		RolesClaim:  "roles",
+		TenantClaim: "tenant",
+	})
+	require.NoError(t, err)
+
+	sign := func(claims jwt.MapClaims, secret string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return token
+	}
+	valid := jwt.MapClaims{
+		"sub":    "seller-1",
+		"iss":    "wb-auth",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"roles":  []string{"seller"},
+		"tenant": "WBIL",
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: sign(valid, "secret")},
+		{name: "wrong secret", token: sign(valid, "other"), wantErr: ErrInvalidCredentials},
+		{name: "wrong issuer", token: sign(jwt.MapClaims{"sub": "x", "iss": "evil", "exp": valid["exp"]}, "secret"), wantErr: ErrInvalidCredentials},
+		{name: "expired", token: sign(jwt.MapClaims{"sub": "x", "iss": "wb-auth", "exp": time.Now().Add(-time.Hour).Unix()}, "secret"), wantErr: ErrInvalidCredentials},
+		{name: "no expiry", token: sign(jwt.MapClaims{"sub": "x", "iss": "wb-auth"}, "secret"), wantErr: ErrInvalidCredentials},
+		{name: "no token", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			got, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "seller-1", got.Subject)
+			assert.Equal(t, MethodJWT, got.Method)
+			assert.Equal(t, []string{"seller"}, got.Roles)
+			assert.Equal(t, "WBIL", got.Tenant)
+			assert.Equal(t, "wb-auth", got.Claims["iss"])
+		})
+	}
+}
+
+func TestJWT_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	a, err := NewJWT(config.JWT{JWKSFile: path, RolesClaim: "roles"})
+	require.NoError(t, err)
+
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub":   "svc",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": "support admin",
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sign("key-1"))
+	got, err := a.Authenticate(r)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"support", "admin"}, got.Roles)
+
+	r.Header.Set("Authorization", "Bearer "+sign("key-2"))
+	_, err = a.Authenticate(r)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestNewJWT_NoKeys(t *testing.T) {
+	_, err := NewJWT(config.JWT{})
+	assert.Error(t, err)
+}
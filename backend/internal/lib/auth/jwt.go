@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"WB/internal/config"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWT authenticates bearer tokens signed with an HMAC secret or a key from a JWKS file.
+type JWT struct {
+	secret      []byte
+	keys        map[string]any
+	parser      *jwt.Parser
+	rolesClaim  string
+	tenantClaim string
+}
+
+// NewJWT creates a JWT authenticator. At least one of the HMAC secret and
+// the JWKS file must be configured.
+func NewJWT(cfg config.JWT) (*JWT, error) {
+	const op = "auth.NewJWT"
+
+	if cfg.HMACSecret == "" && cfg.JWKSFile == "" {
+		return nil, fmt.Errorf("%s: hmac_secret or jwks_file is required", op)
+	}
+
+	a := &JWT{
+		rolesClaim:  cfg.RolesClaim,
+		tenantClaim: cfg.TenantClaim,
+	}
+
+	var methods []string
+	if cfg.HMACSecret != "" {
+		a.secret = []byte(cfg.HMACSecret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		a.keys = keys
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+
+	return a, nil
+}
+
+// Authenticate validates the "Authorization: Bearer <token>" header.
+func (a *JWT) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Identity{}, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.key); err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	sub, _ := claims.GetSubject()
+
+	var tenant string
+	if v := claims[a.tenantClaim]; v != nil {
+		tenant = fmt.Sprint(v)
+	}
+
+	return Identity{
+		Subject: sub,
+		Method:  MethodJWT,
+		Roles:   stringsClaim(claims[a.rolesClaim]),
+		Tenant:  tenant,
+		Claims:  claims,
+	}, nil
+}
+
+// key returns the verification key for the token's algorithm and key ID.
+func (a *JWT) key(t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.secret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+// stringsClaim converts a string or a list claim to a string slice.
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,287 @@
+package codec
+
+import (
+	"WB/internal/models"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// orderSchema is the Avro schema of the orders.
+//
+//go:embed order.avsc
+var orderSchema string
+
+// avroMagic is the first byte of the Confluent wire format.
+const avroMagic = 0
+
+var errShortAvro = errors.New("unexpected end of avro data")
+
+// SchemaRegistry stores Avro schemas by ID, like the Confluent schema registry.
+type SchemaRegistry interface {
+	// Register returns the ID of the schema under the subject, adding the schema if it is new.
+	Register(subject, schema string) (int, error)
+	// Schema returns the schema with the ID.
+	Schema(id int) (string, error)
+}
+
+// Avro encodes orders in the Avro binary encoding of order.avsc framed in the
+// Confluent wire format: the magic byte 0, the 4-byte big-endian schema ID and
+// the encoded order. Schema resolution is not supported: orders written with
+// a schema other than order.avsc are rejected with models.ErrUnknownSchema.
+type Avro struct {
+	registry SchemaRegistry
+	id       int
+
+	mu sync.Mutex
+	// known caches whether the schemas of the IDs seen so far are order.avsc.
+	known map[int]bool
+}
+
+// NewAvro registers order.avsc in the registry under the subject and returns
+// the codec writing orders with its ID.
+func NewAvro(registry SchemaRegistry, subject string) (*Avro, error) {
+	const op = "codec.NewAvro"
+
+	id, err := registry.Register(subject, orderSchema)
+	if err != nil {
+		return nil, fmt.Errorf("%s: register schema: %w", op, err)
+	}
+
+	return &Avro{registry: registry, id: id, known: map[int]bool{id: true}}, nil
+}
+
+// ContentType implements Codec.
+func (a *Avro) ContentType() string { return ContentTypeAvro }
+
+// Marshal implements Codec.
+func (a *Avro) Marshal(order models.Order) ([]byte, error) {
+	w := avroWriter{buf: make([]byte, 5, 512)}
+	w.buf[0] = avroMagic
+	binary.BigEndian.PutUint32(w.buf[1:5], uint32(a.id))
+
+	w.string(order.OrderUID)
+	w.string(order.TrackNumber)
+	w.string(order.Entry)
+
+	d := order.Delivery
+	for _, s := range []string{d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email} {
+		w.string(s)
+	}
+
+	p := order.Payment
+	w.string(p.Transaction)
+	w.string(p.RequestID)
+	w.string(p.Currency)
+	w.string(p.Provider)
+	w.long(int64(p.Amount))
+	w.long(p.PaymentDt)
+	w.string(p.Bank)
+	w.long(int64(p.DeliveryCost))
+	w.long(int64(p.GoodsTotal))
+	w.long(int64(p.CustomFee))
+
+	if len(order.Items) > 0 {
+		w.long(int64(len(order.Items)))
+		for _, it := range order.Items {
+			w.long(int64(it.ChrtID))
+			w.string(it.TrackNumber)
+			w.long(int64(it.Price))
+			w.string(it.Rid)
+			w.string(it.Name)
+			w.long(int64(it.Sale))
+			w.string(it.Size)
+			w.long(int64(it.TotalPrice))
+			w.long(int64(it.NmID))
+			w.string(it.Brand)
+			w.long(int64(it.Status))
+		}
+	}
+	w.long(0)
+
+	w.string(order.Locale)
+	w.string(order.InternalSignature)
+	w.string(order.CustomerID)
+	w.string(order.DeliveryService)
+	w.string(order.Shardkey)
+	w.long(int64(order.SmID))
+	// nanoseconds, the precision of the signed RFC 3339 form
+	w.long(order.DateCreated.UnixNano())
+	w.string(order.OofShard)
+
+	return w.buf, nil
+}
+
+// Unmarshal implements Codec.
+func (a *Avro) Unmarshal(data []byte) (models.Order, error) {
+	const op = "codec.Avro.Unmarshal"
+
+	if len(data) < 5 || data[0] != avroMagic {
+		return models.Order{}, fmt.Errorf("%s: not in the Confluent wire format", op)
+	}
+	id := int(binary.BigEndian.Uint32(data[1:5]))
+	if err := a.checkSchema(id); err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	r := avroReader{buf: data[5:]}
+	var order models.Order
+
+	order.OrderUID = r.string()
+	order.TrackNumber = r.string()
+	order.Entry = r.string()
+
+	d := &order.Delivery
+	for _, s := range []*string{&d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email} {
+		*s = r.string()
+	}
+
+	p := &order.Payment
+	p.Transaction = r.string()
+	p.RequestID = r.string()
+	p.Currency = r.string()
+	p.Provider = r.string()
+	p.Amount = int(r.long())
+	p.PaymentDt = r.long()
+	p.Bank = r.string()
+	p.DeliveryCost = int(r.long())
+	p.GoodsTotal = int(r.long())
+	p.CustomFee = int(r.long())
+
+	order.Items = []models.Item{}
+	for n := r.blockCount(); n > 0 && r.err == nil; n = r.blockCount() {
+		for range n {
+			order.Items = append(order.Items, models.Item{
+				ChrtID:      int(r.long()),
+				TrackNumber: r.string(),
+				Price:       int(r.long()),
+				Rid:         r.string(),
+				Name:        r.string(),
+				Sale:        int(r.long()),
+				Size:        r.string(),
+				TotalPrice:  int(r.long()),
+				NmID:        int(r.long()),
+				Brand:       r.string(),
+				Status:      int(r.long()),
+			})
+		}
+	}
+
+	order.Locale = r.string()
+	order.InternalSignature = r.string()
+	order.CustomerID = r.string()
+	order.DeliveryService = r.string()
+	order.Shardkey = r.string()
+	order.SmID = int(r.long())
+	order.DateCreated = time.Unix(0, r.long()).UTC()
+	order.OofShard = r.string()
+
+	if r.err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, r.err)
+	}
+	if len(r.buf) > 0 {
+		return models.Order{}, fmt.Errorf("%s: %d trailing bytes", op, len(r.buf))
+	}
+
+	return order, nil
+}
+
+// checkSchema reports an error unless the schema with the ID is order.avsc.
+func (a *Avro) checkSchema(id int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	same, ok := a.known[id]
+	if !ok {
+		schema, err := a.registry.Schema(id)
+		if err != nil {
+			return fmt.Errorf("schema %d: %w", id, err)
+		}
+		same = sameSchema(schema, orderSchema)
+		a.known[id] = same
+	}
+	if !same {
+		return fmt.Errorf("schema %d differs from the order schema: %w", id, models.ErrUnknownSchema)
+	}
+	return nil
+}
+
+// sameSchema reports whether the JSON schemas are equal up to formatting.
+func sameSchema(a, b string) bool {
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// avroWriter appends values in the Avro binary encoding.
+type avroWriter struct {
+	buf []byte
+}
+
+// long writes a zigzag varint, the encoding of binary.AppendVarint.
+func (w *avroWriter) long(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *avroWriter) string(s string) {
+	w.long(int64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// avroReader reads values in the Avro binary encoding. The first error
+// is kept in err and makes the following reads return zero values.
+type avroReader struct {
+	buf []byte
+	err error
+}
+
+func (r *avroReader) long() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = errShortAvro
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *avroReader) string() string {
+	n := r.long()
+	if r.err != nil {
+		return ""
+	}
+	if n < 0 || n > int64(len(r.buf)) {
+		r.err = errShortAvro
+		return ""
+	}
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
+
+// blockCount reads the item count of the next array block; 0 ends the array.
+func (r *avroReader) blockCount() int64 {
+	n := r.long()
+	if n < 0 {
+		// a negative count is followed by the block size in bytes
+		n = -n
+		r.long()
+	}
+	if r.err == nil && n > int64(len(r.buf)) {
+		r.err = errShortAvro
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+// Package codec encodes orders for Kafka in JSON, Protobuf or Avro.
+// The content type of a message, sent in its content-type header,
+// names the codec that decodes it.
+package codec
+
+import (
+	"WB/internal/models"
+	"encoding/json"
+	"fmt"
+)
+
+// Content types of the supported codecs.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// Codec converts orders to and from their wire form.
+type Codec interface {
+	// ContentType names the wire form.
+	ContentType() string
+	Marshal(order models.Order) ([]byte, error)
+	Unmarshal(data []byte) (models.Order, error)
+}
+
+// JSON encodes orders as the JSON of models.Order.
+type JSON struct{}
+
+// ContentType implements Codec.
+func (JSON) ContentType() string { return ContentTypeJSON }
+
+// Marshal implements Codec.
+func (JSON) Marshal(order models.Order) ([]byte, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("codec.JSON.Marshal: %w", err)
+	}
+	return data, nil
+}
+
+// Unmarshal implements Codec.
+func (JSON) Unmarshal(data []byte) (models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return models.Order{}, fmt.Errorf("codec.JSON.Unmarshal: %w", err)
+	}
+	return order, nil
+}
@@ -0,0 +1,146 @@
+package codec
+
+import (
+	"WB/internal/lib/signature"
+	"WB/internal/models"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:          "b563feb7b2b84b6test",
+		TrackNumber:       "WBILMTESTTRACK",
+		Entry:             "WBIL",
+		Locale:            "en",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		Shardkey:          "9",
+		SmID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 123456789, time.UTC),
+		OofShard:          "1",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Кирьят-Моцкин",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest",
+				Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202},
+			{ChrtID: -1, Name: "Brush", Price: 1 << 40},
+		},
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	registry, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	avro, err := NewAvro(registry, "orders-value")
+	require.NoError(t, err)
+
+	for _, c := range []Codec{JSON{}, Protobuf{}, avro} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			for _, order := range []models.Order{testOrder(), {Items: []models.Item{}, DateCreated: time.Unix(0, 0).UTC()}} {
+				data, err := c.Marshal(order)
+				require.NoError(t, err)
+
+				got, err := c.Unmarshal(data)
+
+				require.NoError(t, err)
+				assert.Equal(t, order, got)
+			}
+		})
+	}
+}
+
+func TestCodecs_KeepSignature(t *testing.T) {
+	registry, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	avro, err := NewAvro(registry, "orders-value")
+	require.NoError(t, err)
+	secret := []byte("codec-test-secret")
+
+	order := testOrder()
+	order.DateCreated = time.Date(2021, 11, 26, 9, 22, 19, 123456789, time.FixedZone("MSK", 3*60*60))
+	order.InternalSignature, err = signature.SignHMAC(order, secret)
+	require.NoError(t, err)
+
+	for _, c := range []Codec{JSON{}, Protobuf{}, avro} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			data, err := c.Marshal(order)
+			require.NoError(t, err)
+			got, err := c.Unmarshal(data)
+			require.NoError(t, err)
+
+			want, err := signature.SignHMAC(got, secret)
+			require.NoError(t, err)
+			assert.Equal(t, order.InternalSignature, want)
+		})
+	}
+}
+
+func TestAvro_Unmarshal(t *testing.T) {
+	registry, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	avro, err := NewAvro(registry, "orders-value")
+	require.NoError(t, err)
+	otherID, err := registry.Register("orders-value", `{"type": "record", "name": "Order", "fields": []}`)
+	require.NoError(t, err)
+
+	data, err := avro.Marshal(testOrder())
+	require.NoError(t, err)
+
+	other := append([]byte(nil), data...)
+	binary.BigEndian.PutUint32(other[1:5], uint32(otherID))
+	_, err = avro.Unmarshal(other)
+	assert.ErrorIs(t, err, models.ErrUnknownSchema)
+
+	_, err = avro.Unmarshal(data[:len(data)-3])
+	assert.Error(t, err, "truncated")
+
+	_, err = avro.Unmarshal(append(data, 0))
+	assert.Error(t, err, "trailing bytes")
+
+	_, err = avro.Unmarshal([]byte(`{"order_uid": "x"}`))
+	assert.Error(t, err, "not framed")
+}
+
+func TestFileRegistry(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+
+	id, err := registry.Register("orders-value", orderSchema)
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	// the same schema in another formatting keeps its ID, also after a restart
+	reopened, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	var compact bytes.Buffer
+	require.NoError(t, json.Compact(&compact, []byte(orderSchema)))
+	again, err := reopened.Register("orders-value", compact.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	otherID, err := reopened.Register("orders-value", `{"type": "string"}`)
+	require.NoError(t, err)
+	assert.Equal(t, 2, otherID)
+
+	schema, err := reopened.Schema(otherID)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type": "string"}`, schema)
+
+	_, err = reopened.Schema(3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package codec
+
+import (
+	orderv1 "WB/api/gen/order/v1"
+	"WB/internal/models"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Protobuf encodes orders as the Order message of the gRPC API (api/proto/order/v1).
+type Protobuf struct{}
+
+// ContentType implements Codec.
+func (Protobuf) ContentType() string { return ContentTypeProtobuf }
+
+// Marshal implements Codec.
+func (Protobuf) Marshal(order models.Order) ([]byte, error) {
+	data, err := proto.Marshal(OrderToProto(order))
+	if err != nil {
+		return nil, fmt.Errorf("codec.Protobuf.Marshal: %w", err)
+	}
+	return data, nil
+}
+
+// Unmarshal implements Codec.
+func (Protobuf) Unmarshal(data []byte) (models.Order, error) {
+	var o orderv1.Order
+	if err := proto.Unmarshal(data, &o); err != nil {
+		return models.Order{}, fmt.Errorf("codec.Protobuf.Unmarshal: %w", err)
+	}
+	return OrderFromProto(&o), nil
+}
+
+// OrderFromProto converts the wire order into the domain model.
+func OrderFromProto(o *orderv1.Order) models.Order {
+	order := models.Order{
+		OrderUID:          o.GetOrderUid(),
+		TrackNumber:       o.GetTrackNumber(),
+		Entry:             o.GetEntry(),
+		Locale:            o.GetLocale(),
+		InternalSignature: o.GetInternalSignature(),
+		CustomerID:        o.GetCustomerId(),
+		DeliveryService:   o.GetDeliveryService(),
+		Shardkey:          o.GetShardkey(),
+		SmID:              int(o.GetSmId()),
+		OofShard:          o.GetOofShard(),
+		Items:             make([]models.Item, 0, len(o.GetItems())),
+	}
+	if o.GetDateCreated() != nil {
+		order.DateCreated = o.GetDateCreated().AsTime()
+	}
+
+	if d := o.GetDelivery(); d != nil {
+		order.Delivery = models.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		}
+	}
+
+	if p := o.GetPayment(); p != nil {
+		order.Payment = models.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       int(p.GetAmount()),
+			PaymentDt:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: int(p.GetDeliveryCost()),
+			GoodsTotal:   int(p.GetGoodsTotal()),
+			CustomFee:    int(p.GetCustomFee()),
+		}
+	}
+
+	for _, i := range o.GetItems() {
+		order.Items = append(order.Items, models.Item{
+			ChrtID:      int(i.GetChrtId()),
+			TrackNumber: i.GetTrackNumber(),
+			Price:       int(i.GetPrice()),
+			Rid:         i.GetRid(),
+			Name:        i.GetName(),
+			Sale:        int(i.GetSale()),
+			Size:        i.GetSize(),
+			TotalPrice:  int(i.GetTotalPrice()),
+			NmID:        int(i.GetNmId()),
+			Brand:       i.GetBrand(),
+			Status:      int(i.GetStatus()),
+		})
+	}
+
+	return order
+}
+
+// OrderToProto converts the domain model into the wire order.
+func OrderToProto(order models.Order) *orderv1.Order {
+	o := &orderv1.Order{
+		OrderUid:          order.OrderUID,
+		TrackNumber:       order.TrackNumber,
+		Entry:             order.Entry,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerId:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmId:              int64(order.SmID),
+		DateCreated:       timestamppb.New(order.DateCreated),
+		OofShard:          order.OofShard,
+		Delivery: &orderv1.Delivery{
+			Name:    order.Delivery.Name,
+			Phone:   order.Delivery.Phone,
+			Zip:     order.Delivery.Zip,
+			City:    order.Delivery.City,
+			Address: order.Delivery.Address,
+			Region:  order.Delivery.Region,
+			Email:   order.Delivery.Email,
+		},
+		Payment: &orderv1.Payment{
+			Transaction:  order.Payment.Transaction,
+			RequestId:    order.Payment.RequestID,
+			Currency:     order.Payment.Currency,
+			Provider:     order.Payment.Provider,
+			Amount:       int64(order.Payment.Amount),
+			PaymentDt:    order.Payment.PaymentDt,
+			Bank:         order.Payment.Bank,
+			DeliveryCost: int64(order.Payment.DeliveryCost),
+			GoodsTotal:   int64(order.Payment.GoodsTotal),
+			CustomFee:    int64(order.Payment.CustomFee),
+		},
+		Items: make([]*orderv1.Item, 0, len(order.Items)),
+	}
+
+	for _, i := range order.Items {
+		o.Items = append(o.Items, &orderv1.Item{
+			ChrtId:      int64(i.ChrtID),
+			TrackNumber: i.TrackNumber,
+			Price:       int64(i.Price),
+			Rid:         i.Rid,
+			Name:        i.Name,
+			Sale:        int64(i.Sale),
+			Size:        i.Size,
+			TotalPrice:  int64(i.TotalPrice),
+			NmId:        int64(i.NmID),
+			Brand:       i.Brand,
+			Status:      int64(i.Status),
+		})
+	}
+
+	return o
+}
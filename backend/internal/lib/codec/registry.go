@@ -0,0 +1,103 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FileRegistry is a SchemaRegistry kept in a directory, a local stand-in for
+// the Confluent schema registry. Schema N is stored in the file N.avsc.
+// Subjects are not tracked: every distinct schema gets its own ID.
+type FileRegistry struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileRegistry opens the registry in dir, creating the directory if needed.
+func NewFileRegistry(dir string) (*FileRegistry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("codec.NewFileRegistry: %w", err)
+	}
+	return &FileRegistry{dir: dir}, nil
+}
+
+// Register implements SchemaRegistry.
+func (r *FileRegistry) Register(subject, schema string) (int, error) {
+	const op = "codec.FileRegistry.Register"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids, err := r.ids()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	next := 1
+	for _, id := range ids {
+		stored, err := r.Schema(id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if sameSchema(stored, schema) {
+			return id, nil
+		}
+		next = max(next, id+1)
+	}
+
+	// O_EXCL keeps two processes from taking the same ID
+	for ; ; next++ {
+		f, err := os.OpenFile(r.path(next), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		_, err = f.WriteString(schema)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return next, nil
+	}
+}
+
+// Schema implements SchemaRegistry.
+func (r *FileRegistry) Schema(id int) (string, error) {
+	data, err := os.ReadFile(r.path(id))
+	if err != nil {
+		return "", fmt.Errorf("codec.FileRegistry.Schema: %w", err)
+	}
+	return string(data), nil
+}
+
+// ids returns the IDs of the stored schemas.
+func (r *FileRegistry) ids() ([]int, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".avsc")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *FileRegistry) path(id int) string {
+	return filepath.Join(r.dir, strconv.Itoa(id)+".avsc")
+}
@@ -0,0 +1,238 @@
+// Package envelope wraps Kafka message payloads with their schema version,
+// event type, producer, time and content type, and upcasts payloads of older
+// schema versions to the current one. Upcasters work on decoded payloads, so
+// the same chain serves every content type.
+//
+// A JSON payload is sent in an envelope encoded as the message value.
+// Payloads in other formats, such as Protobuf, are sent as the message value
+// with the rest of the envelope in the message headers.
+//
+// Messages written before the envelope was introduced carry a bare payload.
+// Decode returns them as schema version 0 of the given legacy type.
+package envelope
+
+import (
+	"WB/internal/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ContentTypeJSON is the content type of JSON payloads.
+const ContentTypeJSON = "application/json"
+
+// Kafka headers of the envelope of a message.
+const (
+	HeaderContentType   = "content-type"
+	HeaderSchemaVersion = "schema-version"
+	HeaderType          = "type"
+	HeaderProducerID    = "producer-id"
+	HeaderTime          = "time"
+)
+
+// Envelope is a Kafka message with its metadata.
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Type          string          `json:"type"`
+	ProducerID    string          `json:"producer_id"`
+	Time          time.Time       `json:"time"`
+	ContentType   string          `json:"content_type"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Upcaster converts a decoded payload of one schema version into the next one.
+type Upcaster[T any] func(payload T) (T, error)
+
+// Registry knows the current schema version of every event type and the
+// upcasters of their older versions, for payloads decoded to T.
+type Registry[T any] struct {
+	current   map[string]int
+	upcasters map[string]map[int]Upcaster[T]
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry[T any]() *Registry[T] {
+	return &Registry[T]{
+		current:   make(map[string]int),
+		upcasters: make(map[string]map[int]Upcaster[T]),
+	}
+}
+
+// Current sets the current schema version of the event type.
+func (r *Registry[T]) Current(eventType string, version int) *Registry[T] {
+	r.current[eventType] = version
+	return r
+}
+
+// Register adds the upcaster of the event type from version to version+1.
+func (r *Registry[T]) Register(eventType string, from int, up Upcaster[T]) *Registry[T] {
+	if r.upcasters[eventType] == nil {
+		r.upcasters[eventType] = make(map[int]Upcaster[T])
+	}
+	r.upcasters[eventType][from] = up
+	return r
+}
+
+// Wrap returns the encoded envelope of the JSON payload of the event type at
+// its current schema version.
+func (r *Registry[T]) Wrap(eventType, producerID string, payload []byte) ([]byte, error) {
+	const op = "envelope.Wrap"
+
+	version, ok := r.current[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%s: event type %q: %w", op, eventType, models.ErrUnknownSchema)
+	}
+
+	data, err := json.Marshal(Envelope{
+		SchemaVersion: version,
+		Type:          eventType,
+		ProducerID:    producerID,
+		Time:          time.Now().UTC(),
+		ContentType:   ContentTypeJSON,
+		Payload:       payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+// WrapHeaders returns the envelope headers of a payload of the event type at
+// its current schema version in the content type. The payload is sent as the
+// message value.
+func (r *Registry[T]) WrapHeaders(eventType, producerID, contentType string) (map[string]string, error) {
+	version, ok := r.current[eventType]
+	if !ok {
+		return nil, fmt.Errorf("envelope.WrapHeaders: event type %q: %w", eventType, models.ErrUnknownSchema)
+	}
+
+	return map[string]string{
+		HeaderContentType:   contentType,
+		HeaderSchemaVersion: strconv.Itoa(version),
+		HeaderType:          eventType,
+		HeaderProducerID:    producerID,
+		HeaderTime:          time.Now().UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+// OpenMessage decodes a Kafka message. A message with a content-type header
+// other than JSON has the envelope in its headers and the payload as its value.
+// Other messages are opened with Open. The payload is returned as is, decode
+// it and pass it to Upcast.
+func (r *Registry[T]) OpenMessage(value []byte, headers map[string]string, legacyType string) (Envelope, error) {
+	const op = "envelope.OpenMessage"
+
+	contentType := headers[HeaderContentType]
+	if contentType == "" || contentType == ContentTypeJSON {
+		return r.Open(value, legacyType)
+	}
+
+	env := Envelope{
+		Type:        headers[HeaderType],
+		ProducerID:  headers[HeaderProducerID],
+		ContentType: contentType,
+		Payload:     value,
+	}
+	version, err := strconv.Atoi(headers[HeaderSchemaVersion])
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%s: schema version %q: %w", op, headers[HeaderSchemaVersion], models.ErrUnknownSchema)
+	}
+	env.SchemaVersion = version
+	if t := headers[HeaderTime]; t != "" {
+		if env.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+			return Envelope{}, fmt.Errorf("%s: time: %w", op, err)
+		}
+	}
+
+	if err := r.check(env); err != nil {
+		return Envelope{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return env, nil
+}
+
+// Open decodes the message and checks that its payload can be upcast to the
+// current schema version of its type. Unknown types, content types and schema
+// versions, including versions newer than the current one, yield models.ErrUnknownSchema.
+func (r *Registry[T]) Open(data []byte, legacyType string) (Envelope, error) {
+	const op = "envelope.Open"
+
+	env, err := Decode(data, legacyType)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if env.ContentType != ContentTypeJSON {
+		return Envelope{}, fmt.Errorf("%s: content type %q: %w", op, env.ContentType, models.ErrUnknownSchema)
+	}
+	if err := r.check(env); err != nil {
+		return Envelope{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return env, nil
+}
+
+// Upcast converts the payload of the envelope, decoded to T, from the schema
+// version of the envelope to the current one of its type.
+func (r *Registry[T]) Upcast(env Envelope, payload T) (T, error) {
+	const op = "envelope.Upcast"
+
+	if err := r.check(env); err != nil {
+		return payload, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var err error
+	for v := env.SchemaVersion; v < r.current[env.Type]; v++ {
+		if payload, err = r.upcasters[env.Type][v](payload); err != nil {
+			return payload, fmt.Errorf("%s: upcast %s version %d: %w", op, env.Type, v, err)
+		}
+	}
+
+	return payload, nil
+}
+
+// check reports models.ErrUnknownSchema unless the type of the envelope is
+// known and its schema version is upcast to the current one.
+func (r *Registry[T]) check(env Envelope) error {
+	current, ok := r.current[env.Type]
+	if !ok {
+		return fmt.Errorf("event type %q: %w", env.Type, models.ErrUnknownSchema)
+	}
+	if env.SchemaVersion > current {
+		return fmt.Errorf("%s version %d is newer than %d: %w", env.Type, env.SchemaVersion, current, models.ErrUnknownSchema)
+	}
+	for v := env.SchemaVersion; v < current; v++ {
+		if _, ok := r.upcasters[env.Type][v]; !ok {
+			return fmt.Errorf("%s version %d: %w", env.Type, v, models.ErrUnknownSchema)
+		}
+	}
+	return nil
+}
+
+// Decode parses the envelope without upcasting it. A message without an
+// envelope is returned as a JSON payload of version 0 of legacyType.
+func Decode(data []byte, legacyType string) (Envelope, error) {
+	var probe struct {
+		SchemaVersion *int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return Envelope{}, fmt.Errorf("decode envelope: %w", err)
+	}
+	if probe.SchemaVersion == nil {
+		return Envelope{
+			Type:        legacyType,
+			ContentType: ContentTypeJSON,
+			Payload:     bytes.Clone(data),
+		}, nil
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, fmt.Errorf("decode envelope: %w", err)
+	}
+
+	return env, nil
+}
@@ -0,0 +1,166 @@
+package envelope
+
+import (
+	"WB/internal/models"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry renames "name" to "title" in version 1 and wraps the payload in "order" in version 2.
+func testRegistry() *Registry[map[string]any] {
+	return NewRegistry[map[string]any]().
+		Current("order.created", 3).
+		Register("order.created", 1, func(v map[string]any) (map[string]any, error) {
+			name, ok := v["name"]
+			if !ok {
+				return nil, errors.New("no name")
+			}
+			v["title"] = name
+			delete(v, "name")
+			return v, nil
+		}).
+		Register("order.created", 2, func(v map[string]any) (map[string]any, error) {
+			return map[string]any{"order": v}, nil
+		})
+}
+
+// openJSON opens the message, decodes its JSON payload and upcasts it.
+func openJSON(r *Registry[map[string]any], msg []byte) (Envelope, map[string]any, error) {
+	env, err := r.Open(msg, "order.created")
+	if err != nil {
+		return Envelope{}, nil, err
+	}
+	var v map[string]any
+	if err := json.Unmarshal(env.Payload, &v); err != nil {
+		return Envelope{}, nil, err
+	}
+	v, err = r.Upcast(env, v)
+	return env, v, err
+}
+
+func TestRegistry_WrapOpen(t *testing.T) {
+	r := testRegistry()
+
+	msg, err := r.Wrap("order.created", "test", []byte(`{"order":{"title":"x"}}`))
+	require.NoError(t, err)
+
+	env, err := r.Open(msg, "order.created")
+	require.NoError(t, err)
+	assert.Equal(t, 3, env.SchemaVersion)
+	assert.Equal(t, "test", env.ProducerID)
+	assert.Equal(t, ContentTypeJSON, env.ContentType)
+	assert.False(t, env.Time.IsZero())
+	assert.JSONEq(t, `{"order":{"title":"x"}}`, string(env.Payload))
+
+	_, err = r.Wrap("order.deleted", "test", []byte(`{}`))
+	assert.ErrorIs(t, err, models.ErrUnknownSchema)
+}
+
+func TestRegistry_Open(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         string
+		wantPayload string
+		wantErr     error
+	}{
+		{
+			name:        "upcast",
+			msg:         `{"schema_version": 1, "type": "order.created", "content_type": "application/json", "payload": {"name": "x"}}`,
+			wantPayload: `{"order":{"title":"x"}}`,
+		},
+		{
+			name:    "bare payload is version 0",
+			msg:     `{"name": "x"}`,
+			wantErr: models.ErrUnknownSchema,
+		},
+		{
+			name:    "newer version",
+			msg:     `{"schema_version": 4, "type": "order.created", "content_type": "application/json", "payload": {}}`,
+			wantErr: models.ErrUnknownSchema,
+		},
+		{
+			name:    "unknown type",
+			msg:     `{"schema_version": 1, "type": "order.deleted", "content_type": "application/json", "payload": {}}`,
+			wantErr: models.ErrUnknownSchema,
+		},
+		{
+			name:    "unknown content type",
+			msg:     `{"schema_version": 3, "type": "order.created", "content_type": "application/avro", "payload": "AA=="}`,
+			wantErr: models.ErrUnknownSchema,
+		},
+		{
+			name:    "broken payload",
+			msg:     `{"schema_version": 1, "type": "order.created", "content_type": "application/json", "payload": {}}`,
+			wantErr: errors.New("upcast"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, payload, err := openJSON(testRegistry(), []byte(tt.msg))
+
+			if tt.wantErr != nil {
+				if errors.Is(tt.wantErr, models.ErrUnknownSchema) {
+					assert.ErrorIs(t, err, tt.wantErr)
+				} else {
+					assert.ErrorContains(t, err, tt.wantErr.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+			got, err := json.Marshal(payload)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantPayload, string(got))
+		})
+	}
+}
+
+func TestDecode_Legacy(t *testing.T) {
+	env, err := Decode([]byte(`{"order_uid": "x"}`), "order.created")
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, env.SchemaVersion)
+	assert.Equal(t, "order.created", env.Type)
+	assert.JSONEq(t, `{"order_uid": "x"}`, string(env.Payload))
+
+	_, err = Decode([]byte(`not json`), "order.created")
+	assert.Error(t, err)
+}
+
+func TestRegistry_OpenMessage(t *testing.T) {
+	r := testRegistry()
+
+	headers, err := r.WrapHeaders("order.created", "test", "application/x-protobuf")
+	require.NoError(t, err)
+
+	env, err := r.OpenMessage([]byte{1, 2, 3}, headers, "order.created")
+	require.NoError(t, err)
+	assert.Equal(t, 3, env.SchemaVersion)
+	assert.Equal(t, "test", env.ProducerID)
+	assert.Equal(t, "application/x-protobuf", env.ContentType)
+	assert.Equal(t, []byte{1, 2, 3}, []byte(env.Payload))
+	assert.False(t, env.Time.IsZero())
+
+	// a JSON content type is an envelope in the value
+	_, err = r.OpenMessage([]byte(`{"name": "x"}`), map[string]string{HeaderContentType: ContentTypeJSON}, "order.created")
+	assert.ErrorIs(t, err, models.ErrUnknownSchema, "bare version 0 has no upcaster in the test registry")
+
+	// older versions of any content type are upcast after decoding
+	headers[HeaderSchemaVersion] = "1"
+	env, err = r.OpenMessage([]byte{1}, headers, "order.created")
+	require.NoError(t, err)
+	payload, err := r.Upcast(env, map[string]any{"name": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"order": map[string]any{"title": "x"}}, payload)
+
+	headers[HeaderSchemaVersion] = "0"
+	_, err = r.OpenMessage([]byte{1}, headers, "order.created")
+	assert.ErrorIs(t, err, models.ErrUnknownSchema, "version 0 has no upcaster")
+
+	headers[HeaderSchemaVersion] = "4"
+	_, err = r.OpenMessage([]byte{1}, headers, "order.created")
+	assert.ErrorIs(t, err, models.ErrUnknownSchema)
+}
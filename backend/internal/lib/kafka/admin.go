@@ -0,0 +1,250 @@
+package kafka
+
+import (
+	"WB/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Status returns the state of the consumer with the assignment, committed
+// offset and lag of every partition of the topic, as the brokers report them.
+func (c *Consumer) Status(ctx context.Context) (models.ConsumerStatus, error) {
+	const op = "kafka.admin.Status"
+
+	status := c.State()
+
+	partitions, err := c.partitionIDs(ctx)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+	members, err := c.assignments(ctx)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+	committed, err := c.committedOffsets(ctx, partitions)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+	last, err := c.listOffsets(ctx, partitions, kafka.LastOffsetOf)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+
+	status.Partitions = partitionStates(partitions, members, committed, last)
+	return status, nil
+}
+
+// RecordLag queries the brokers for the lag of every partition and records it
+// in the metrics, so the lag keeps growing while the group consumes nothing.
+func (c *Consumer) RecordLag(ctx context.Context) error {
+	const op = "kafka.admin.RecordLag"
+
+	if c.metrics == nil {
+		return nil
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	c.metrics.setLag(c.group, c.topic, status.Partitions)
+	return nil
+}
+
+// ResetOffsets moves the committed offset of every partition to the first
+// message written at or after t, or to the end of partitions without one.
+// The consumer must be paused; it leaves the group for the reset, since the
+// brokers accept offsets only for a group without members, and joins it again
+// still paused. Other members of the group fail the reset with ErrConsumerRunning.
+func (c *Consumer) ResetOffsets(ctx context.Context, t time.Time) (models.ConsumerStatus, error) {
+	const op = "kafka.admin.ResetOffsets"
+
+	if !c.State().Paused {
+		return c.State(), fmt.Errorf("%s: %w", op, models.ErrConsumerRunning)
+	}
+
+	c.inflight.Lock()
+	defer c.inflight.Unlock()
+
+	// a new reader joins the group right away, so it replaces the old one after the commit
+	defer func() {
+		c.reader = c.newReader()
+	}()
+	c.pending = nil
+	if err := c.reader.Close(); err != nil {
+		return c.State(), fmt.Errorf("%s: failed to leave group: %w", op, err)
+	}
+
+	partitions, err := c.partitionIDs(ctx)
+	if err != nil {
+		return c.State(), fmt.Errorf("%s: %w", op, err)
+	}
+	at, err := c.listOffsets(ctx, partitions, func(p int) kafka.OffsetRequest {
+		return kafka.TimeOffsetOf(p, t)
+	})
+	if err != nil {
+		return c.State(), fmt.Errorf("%s: %w", op, err)
+	}
+	last, err := c.listOffsets(ctx, partitions, kafka.LastOffsetOf)
+	if err != nil {
+		return c.State(), fmt.Errorf("%s: %w", op, err)
+	}
+
+	commits := make([]kafka.OffsetCommit, 0, len(partitions))
+	for _, p := range partitions {
+		offset, ok := at[p]
+		if !ok || offset < 0 {
+			offset = last[p]
+		}
+		commits = append(commits, kafka.OffsetCommit{Partition: p, Offset: offset})
+	}
+
+	resp, err := c.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      c.group,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{c.topic: commits},
+	})
+	if err != nil {
+		return c.State(), fmt.Errorf("%s: failed to commit offsets: %w", op, err)
+	}
+	for _, p := range resp.Topics[c.topic] {
+		if p.Error == nil {
+			continue
+		}
+		if errors.Is(p.Error, kafka.IllegalGeneration) || errors.Is(p.Error, kafka.UnknownMemberId) ||
+			errors.Is(p.Error, kafka.RebalanceInProgress) {
+			return c.State(), fmt.Errorf("%s: partition %d: %w", op, p.Partition, models.ErrConsumerRunning)
+		}
+		return c.State(), fmt.Errorf("%s: failed to commit partition %d: %w", op, p.Partition, p.Error)
+	}
+
+	return c.Status(ctx)
+}
+
+// partitionIDs returns the partitions of the topic in ascending order.
+func (c *Consumer) partitionIDs(ctx context.Context) ([]int, error) {
+	resp, err := c.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{c.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topic metadata: %w", err)
+	}
+
+	var ids []int
+	for _, topic := range resp.Topics {
+		if topic.Name != c.topic {
+			continue
+		}
+		if topic.Error != nil {
+			return nil, fmt.Errorf("failed to get topic metadata: %w", topic.Error)
+		}
+		for _, p := range topic.Partitions {
+			ids = append(ids, p.ID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// assignments returns the group member of every assigned partition of the topic.
+func (c *Consumer) assignments(ctx context.Context) (map[int]kafka.DescribeGroupsResponseMember, error) {
+	resp, err := c.client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{c.group}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe group: %w", err)
+	}
+
+	members := make(map[int]kafka.DescribeGroupsResponseMember)
+	for _, group := range resp.Groups {
+		if group.Error != nil {
+			return nil, fmt.Errorf("failed to describe group: %w", group.Error)
+		}
+		for _, member := range group.Members {
+			for _, topic := range member.MemberAssignments.Topics {
+				if topic.Topic != c.topic {
+					continue
+				}
+				for _, p := range topic.Partitions {
+					members[p] = member
+				}
+			}
+		}
+	}
+	return members, nil
+}
+
+// committedOffsets returns the committed offsets of the group, -1 for partitions without one.
+func (c *Consumer) committedOffsets(ctx context.Context, partitions []int) (map[int]int64, error) {
+	resp, err := c.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: c.group,
+		Topics:  map[string][]int{c.topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", resp.Error)
+	}
+
+	offsets := make(map[int]int64, len(partitions))
+	for _, p := range resp.Topics[c.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("failed to fetch committed offset of partition %d: %w", p.Partition, p.Error)
+		}
+		offsets[p.Partition] = p.CommittedOffset
+	}
+	return offsets, nil
+}
+
+// listOffsets returns the offsets of the partitions that request selects;
+// a partition without a matching message gets -1.
+func (c *Consumer) listOffsets(ctx context.Context, partitions []int, request func(partition int) kafka.OffsetRequest) (map[int]int64, error) {
+	requests := make([]kafka.OffsetRequest, 0, len(partitions))
+	for _, p := range partitions {
+		requests = append(requests, request(p))
+	}
+
+	resp, err := c.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{c.topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+
+	offsets := make(map[int]int64, len(partitions))
+	for _, p := range resp.Topics[c.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("failed to list offsets of partition %d: %w", p.Partition, p.Error)
+		}
+		offsets[p.Partition] = p.LastOffset
+		for offset := range p.Offsets {
+			// requests by time return the offset in Offsets
+			offsets[p.Partition] = offset
+		}
+	}
+	return offsets, nil
+}
+
+// partitionStates combines the group assignment and offsets of the partitions.
+func partitionStates(partitions []int, members map[int]kafka.DescribeGroupsResponseMember, committed, last map[int]int64) []models.PartitionState {
+	states := make([]models.PartitionState, 0, len(partitions))
+	for _, p := range partitions {
+		state := models.PartitionState{
+			Partition:     p,
+			Committed:     -1,
+			HighWatermark: last[p],
+		}
+		if offset, ok := committed[p]; ok {
+			state.Committed = offset
+		}
+		if member, ok := members[p]; ok {
+			state.Member = member.MemberID
+			state.Host = member.ClientHost
+		}
+		state.Lag = max(state.HighWatermark-max(state.Committed, 0), 0)
+		states = append(states, state)
+	}
+	return states
+}
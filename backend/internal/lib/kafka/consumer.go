@@ -3,34 +3,116 @@
 package kafka
 
 import (
+	"WB/internal/models"
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
+
+const defaultDrainTimeout = 10 * time.Second
+
+// messageReader is the part of kafka.Reader used by the Consumer.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// errClosed stops Start after Close.
+var errClosed = errors.New("consumer closed")
+
 // Consumer represents Message broker consumer.
 type Consumer struct {
-	reader    *kafka.Reader
+	group string
+	topic string
+
+	// inflight is held from fetching a message until it is committed,
+	// it guards reader and pending.
+	inflight  sync.Mutex
+	reader    messageReader
+	pending   *kafka.Message
+	newReader func() messageReader
 	dlqWriter *kafka.Writer
+	client    *kafka.Client
+	metrics   *Metrics
+
+	// drainTimeout bounds the handling of the last message on shutdown.
+	drainTimeout time.Duration
+
+	mu        sync.Mutex
+	paused    bool
+	closed    bool
+	resumed   chan struct{}
+	stopFetch context.CancelFunc
+
+	// dlqErrors are handler errors that retrying can't fix.
+	dlqErrors []error
+	// stopErrors are handler errors the following messages must not be committed past.
+	stopErrors []error
+}
+
+// ConsumerOption configures optional Consumer settings.
+type ConsumerOption func(*Consumer)
+
+// WithDLQErrors sends messages whose handler error matches any of errs
+// (see errors.Is) to the DLQ and commits them instead of leaving them uncommitted.
+func WithDLQErrors(errs ...error) ConsumerOption {
+	return func(c *Consumer) {
+		c.dlqErrors = append(c.dlqErrors, errs...)
+	}
+}
+
+// WithStopErrors stops Start with the handler error if it matches any of errs
+// (see errors.Is). The message is not committed, so neither are the messages
+// after it, and it is consumed again once the consumer is restarted.
+func WithStopErrors(errs ...error) ConsumerOption {
+	return func(c *Consumer) {
+		c.stopErrors = append(c.stopErrors, errs...)
+	}
+}
+
+// WithDrainTimeout sets how long the message being handled when Start's
+// context is canceled may take to finish, 10 seconds by default.
+func WithDrainTimeout(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.drainTimeout = d
+	}
+}
+
+// WithMetrics records the offsets, lag and pause state of the consumer in m.
+func WithMetrics(m *Metrics) ConsumerOption {
+	return func(c *Consumer) {
+		c.metrics = m
+	}
 }
 
 // MessageHandler is a function type for processing incoming Kafka messages.
-type MessageHandler func(ctx context.Context, value []byte) error
+// headers holds the message headers by key.
+type MessageHandler func(ctx context.Context, value []byte, headers map[string]string) error
 
 
 // NewConsumer creates and configures a new Kafka consumer with DLQ writer.
-// It connects to the specified brokers, joins the consumer group and subscribes to the topic.
-func NewConsumer(brokers []string, group, topic, dlqTopic string) *Consumer {
-	return &Consumer{
-		reader: kafka.NewReader(kafka.ReaderConfig{
-			Brokers:  brokers,
-			GroupID:  group,
-			Topic:    topic,
-			MinBytes: 10e3, // 10KB
-			MaxBytes: 10e6, // 10MB
-		}),
+// It connects to the specified brokers with the given security, joins the
+// consumer group and subscribes to the topic.
+func NewConsumer(brokers []string, group, topic, dlqTopic string, sec *Security, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		group:        group,
+		topic:        topic,
+		drainTimeout: defaultDrainTimeout,
+		newReader: func() messageReader {
+			return kafka.NewReader(kafka.ReaderConfig{
+				Brokers:  brokers,
+				GroupID:  group,
+				Topic:    topic,
+				MinBytes: 10e3, // 10KB
+				MaxBytes: 10e6, // 10MB
+				Dialer:   sec.dialer(),
+			})
+		},
 		dlqWriter: &kafka.Writer{
 			Addr:                   kafka.TCP(brokers...),
 			Topic:                  dlqTopic,
@@ -38,41 +120,255 @@ func NewConsumer(brokers []string, group, topic, dlqTopic string) *Consumer {
 			WriteTimeout:           5 * time.Second,
 			RequiredAcks:           kafka.RequireOne,
 			AllowAutoTopicCreation: true,
+			Transport:              sec.transport(),
+		},
+		client: &kafka.Client{
+			Addr:      kafka.TCP(brokers...),
+			Timeout:   dialTimeout,
+			Transport: sec.transport(),
 		},
 	}
+	c.reader = c.newReader()
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 // Start begins consuming messages from Kafka and processes them using the provided handler.
-// It runs until the context is canceled or a fatal error occurs.
+// It runs until the context is canceled, the consumer is closed or a fatal error occurs.
+// Canceling the context stops fetching; the message being handled gets the
+// drain timeout (see WithDrainTimeout) to finish and be committed before
+// its handler context is canceled too.
 // On handler error — message is skipped (not committed), but consumption continues.
+// Errors registered with WithDLQErrors send the message to DLQ with the error
+// as the reason, and the message is committed. Errors registered with
+// WithStopErrors are returned without committing the message.
 // On commit error — message is sent to DLQ if possible.
 func (c *Consumer) Start(ctx context.Context, handler MessageHandler) error {
     const op = "kafka.consumer.Start"
 
 	for {
-		msg, err := c.reader.FetchMessage(ctx)
+		fetchCtx, cancel, err := c.fetchContext(ctx)
 		if err != nil {
+			return nil
+		}
+
+		c.inflight.Lock()
+		msg, err := c.fetch(fetchCtx)
+		stopped := fetchCtx.Err() != nil
+		cancel()
+		if err != nil {
+			c.inflight.Unlock()
 			if errors.Is(err, context.Canceled) {
+				if ctx.Err() == nil {
+					// paused or closed
+					continue
+				}
 				return nil
 			}
 			return fmt.Errorf("%s: fetch err: %w", op, err)
 		}
-
-		if err := handler(ctx, msg.Value); err != nil {
+		if stopped {
+			// paused or stopped as the message arrived
+			c.pending = &msg
+			c.inflight.Unlock()
 			continue
 		}
 
-		if err := c.reader.CommitMessages(ctx, msg); err != nil {
-			_ = c.sendToDLQ(ctx, msg, err)
+		err = c.process(ctx, msg, handler)
+		c.inflight.Unlock()
+		if err != nil {
+			return fmt.Errorf("%s: handler err: %w", op, err)
 		}
 	}
 }
 
+// fetch returns the message left over by Pause or the next one of the reader.
+func (c *Consumer) fetch(ctx context.Context) (kafka.Message, error) {
+	if c.pending != nil {
+		msg := *c.pending
+		c.pending = nil
+		return msg, nil
+	}
+	return c.reader.FetchMessage(ctx)
+}
+
+// process handles and commits a fetched message.
+// It returns the handler errors registered with WithStopErrors.
+func (c *Consumer) process(ctx context.Context, msg kafka.Message, handler MessageHandler) error {
+	ctx, cancel := c.drainContext(ctx)
+	defer cancel()
+
+	if err := handler(ctx, msg.Value, headerMap(msg.Headers)); err != nil {
+		if matchesAny(err, c.stopErrors) {
+			return err
+		}
+		if !c.isDLQError(err) {
+			return nil
+		}
+		if dlqErr := c.sendToDLQ(ctx, msg, err); dlqErr != nil {
+			return nil
+		}
+	}
+
+	if err := c.reader.CommitMessages(ctx, msg); err != nil {
+		_ = c.sendToDLQ(ctx, msg, err)
+		return nil
+	}
+	c.metrics.committed(c.group, msg)
+	return nil
+}
+
+// drainContext returns the context of handling a message: it outlives ctx
+// by the drain timeout, so a shutdown doesn't interrupt the handler or the commit.
+func (c *Consumer) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(c.drainTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			cancel()
+		case <-drainCtx.Done():
+		}
+	})
+
+	return drainCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// fetchContext waits while the consumer is paused and returns the context
+// of the next fetch, which Pause cancels.
+func (c *Consumer) fetchContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, nil, errClosed
+		}
+		if !c.paused {
+			fetchCtx, cancel := context.WithCancel(ctx)
+			c.stopFetch = cancel
+			c.mu.Unlock()
+			return fetchCtx, cancel, nil
+		}
+		resumed := c.resumed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-resumed:
+		}
+	}
+}
+
+// Pause stops fetching messages on this instance while staying in the consumer
+// group. Other members of the group keep consuming, and the partitions of this
+// one are not reassigned while it is paused, so stopping the whole group takes
+// pausing every instance.
+// It returns once the message being handled, if any, is committed, or with the
+// error of ctx if ctx is done first; the consumer stays paused either way.
+func (c *Consumer) Pause(ctx context.Context) error {
+	const op = "kafka.consumer.Pause"
+
+	c.mu.Lock()
+	if !c.paused {
+		c.paused = true
+		c.resumed = make(chan struct{})
+		if c.stopFetch != nil {
+			c.stopFetch()
+		}
+		c.metrics.setPaused(c.group, c.topic, true)
+	}
+	c.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		c.inflight.Lock()
+		defer c.inflight.Unlock()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: waiting for the message being handled: %w", op, ctx.Err())
+	}
+}
+
+// Resume continues fetching messages after Pause.
+func (c *Consumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		c.paused = false
+		close(c.resumed)
+		c.metrics.setPaused(c.group, c.topic, false)
+	}
+}
+
+// State returns the group, topic and pause state of the consumer.
+func (c *Consumer) State() models.ConsumerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return models.ConsumerStatus{Group: c.group, Topic: c.topic, Paused: c.paused}
+}
+
+// headerMap returns the headers by key; of repeated keys the last one wins.
+func headerMap(headers []kafka.Header) map[string]string {
+	m := make(map[string]string, len(headers))
+	for _, h := range headers {
+		m[h.Key] = string(h.Value)
+	}
+	return m
+}
+
+// isDLQError reports whether the handler error is permanent.
+func (c *Consumer) isDLQError(err error) bool {
+	return matchesAny(err, c.dlqErrors)
+}
+
+// matchesAny reports whether err matches any of targets, see errors.Is.
+func matchesAny(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // Close gracefully shuts down the consumer and DLQ writer.
-// It closes both connections and collects any errors.
+// It stops fetching, waits until the message being handled is committed,
+// then closes the reader, which leaves the consumer group, and the DLQ writer.
+// It collects any errors.
 func (c *Consumer) Close() error {
     const op = "kafka.consumer.Close"
 
+	c.mu.Lock()
+	c.closed = true
+	if c.stopFetch != nil {
+		c.stopFetch()
+	}
+	if c.paused {
+		c.paused = false
+		close(c.resumed)
+	}
+	c.mu.Unlock()
+
+	c.inflight.Lock()
+	defer c.inflight.Unlock()
+
 	var errs []error
 	if c.reader != nil {
 		if err := c.reader.Close(); err != nil {
@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"WB/internal/models"
+	"WB/internal/repository/memory"
+	"WB/internal/usecase"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderStore keeps the orders stored by the use case.
+type orderStore struct {
+	mu     sync.Mutex
+	orders map[string]models.Order
+}
+
+func (s *orderStore) NewOrder(order models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.OrderUID] = order
+	return nil
+}
+
+func (s *orderStore) GetOrder(orderUID string) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderUID]
+	if !ok {
+		return models.Order{}, models.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// auditStore keeps the appended audit records, or fails the appends while down.
+type auditStore struct {
+	mu   sync.Mutex
+	down bool
+	recs []models.AuditRecord
+}
+
+func (s *auditStore) AppendAudit(ctx context.Context, recs ...models.AuditRecord) ([]models.AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errors.New("db down")
+	}
+	s.recs = append(s.recs, recs...)
+	return recs, nil
+}
+
+func (s *auditStore) ListAudit(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errors.New("db down")
+	}
+	var out []models.AuditRecord
+	for _, rec := range s.recs {
+		if rec.OrderUID == filter.OrderUID && rec.Actor == filter.Actor {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+func (s *auditStore) stored() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uids []string
+	for _, rec := range s.recs {
+		if rec.Action == usecase.ActionOrderStore {
+			uids = append(uids, rec.OrderUID)
+		}
+	}
+	return uids
+}
+
+type nopBroker struct{}
+
+func (nopBroker) Send(ctx context.Context, key string, value []byte) error { return nil }
+func (nopBroker) Close() error                                             { return nil }
+
+func orderMessages(t *testing.T, uids ...string) []kafka.Message {
+	t.Helper()
+
+	msgs := make([]kafka.Message, 0, len(uids))
+	for i, uid := range uids {
+		value, err := json.Marshal(models.Order{OrderUID: uid})
+		require.NoError(t, err)
+		msgs = append(msgs, kafka.Message{Topic: "orders", Offset: int64(i), Value: value})
+	}
+	return msgs
+}
+
+func TestConsumer_StopsOnUnauditedOrder(t *testing.T) {
+	repo := &orderStore{orders: make(map[string]models.Order)}
+	auditLog := &auditStore{down: true}
+	uc := usecase.NewOrderUseCase(repo, memory.New(10, time.Minute), nopBroker{}, usecase.WithAuditLog(auditLog))
+	msgs := orderMessages(t, "first-order", "second-order")
+
+	r := newFakeReader()
+	c := newTestConsumer(r, WithStopErrors(models.ErrAuditUnavailable))
+	for _, msg := range msgs {
+		r.msgs <- msg
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(context.Background(), uc.HandleMessage)
+	}()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, models.ErrAuditUnavailable)
+	case <-time.After(time.Second):
+		t.Fatal("consumer didn't stop on the unaudited order")
+	}
+
+	r.mu.Lock()
+	assert.Empty(t, r.committed)
+	r.mu.Unlock()
+	_, err := repo.GetOrder("first-order")
+	assert.NoError(t, err)
+	_, err = repo.GetOrder("second-order")
+	assert.ErrorIs(t, err, models.ErrOrderNotFound, "messages after the unaudited order must not be consumed")
+
+	// after a restart the messages are consumed again from the committed offset
+	auditLog.mu.Lock()
+	auditLog.down = false
+	auditLog.mu.Unlock()
+
+	r = newFakeReader()
+	c = newTestConsumer(r, WithStopErrors(models.ErrAuditUnavailable))
+	for _, msg := range msgs {
+		r.msgs <- msg
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done = make(chan error, 1)
+	go func() {
+		done <- c.Start(ctx, uc.HandleMessage)
+	}()
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.committed) == 2
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"first-order", "second-order"}, auditLog.stored())
+}
@@ -0,0 +1,367 @@
+package kafka
+
+import (
+	"WB/internal/models"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader serves the messages sent to msgs and records the commits.
+type fakeReader struct {
+	msgs chan kafka.Message
+
+	mu        sync.Mutex
+	committed []kafka.Message
+	closed    bool
+}
+
+func newFakeReader() *fakeReader {
+	return &fakeReader{msgs: make(chan kafka.Message, 10)}
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case msg := <-r.msgs:
+		return msg, nil
+	}
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func newTestConsumer(r *fakeReader, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		group:        "orders-group",
+		topic:        "orders",
+		reader:       r,
+		newReader:    func() messageReader { return r },
+		drainTimeout: defaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// startConsumer runs the consumer until the test ends and returns the handled message values.
+func startConsumer(t *testing.T, c *Consumer) <-chan string {
+	t.Helper()
+
+	handled := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(ctx, func(ctx context.Context, value []byte, headers map[string]string) error {
+			handled <- string(value)
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return handled
+}
+
+func receive(t *testing.T, handled <-chan string) string {
+	t.Helper()
+
+	select {
+	case v := <-handled:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("message was not handled")
+		return ""
+	}
+}
+
+func TestConsumer_PauseResume(t *testing.T) {
+	r := newFakeReader()
+	c := newTestConsumer(r)
+	handled := startConsumer(t, c)
+
+	r.msgs <- kafka.Message{Topic: "orders", Offset: 0, Value: []byte("first")}
+	assert.Equal(t, "first", receive(t, handled))
+
+	require.NoError(t, c.Pause(context.Background()))
+	assert.True(t, c.State().Paused)
+
+	r.msgs <- kafka.Message{Topic: "orders", Offset: 1, Value: []byte("second")}
+	select {
+	case v := <-handled:
+		t.Fatalf("message %q handled while paused", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Resume()
+	assert.False(t, c.State().Paused)
+	assert.Equal(t, "second", receive(t, handled))
+}
+
+func TestConsumer_PauseWaitsForHandler(t *testing.T) {
+	r := newFakeReader()
+	c := newTestConsumer(r)
+
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.Start(ctx, func(ctx context.Context, value []byte, headers map[string]string) error {
+			<-release
+			return nil
+		})
+	}()
+
+	r.msgs <- kafka.Message{Topic: "orders", Offset: 0}
+	require.Eventually(t, func() bool { return len(r.msgs) == 0 }, time.Second, time.Millisecond)
+
+	paused := make(chan struct{})
+	go func() {
+		assert.NoError(t, c.Pause(context.Background()))
+		close(paused)
+	}()
+
+	select {
+	case <-paused:
+		t.Fatal("Pause returned before the message was handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-paused
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assert.Len(t, r.committed, 1)
+}
+
+func TestConsumer_PauseTimeout(t *testing.T) {
+	r := newFakeReader()
+	c := newTestConsumer(r)
+
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.Start(ctx, func(ctx context.Context, value []byte, headers map[string]string) error {
+			<-release
+			return nil
+		})
+	}()
+
+	r.msgs <- kafka.Message{Topic: "orders", Offset: 0}
+	require.Eventually(t, func() bool { return len(r.msgs) == 0 }, time.Second, time.Millisecond)
+
+	pauseCtx, pauseCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer pauseCancel()
+	err := c.Pause(pauseCtx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, c.State().Paused)
+}
+
+func TestConsumer_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+	r := newFakeReader()
+	c := newTestConsumer(r, WithMetrics(m))
+	handled := startConsumer(t, c)
+
+	r.msgs <- kafka.Message{Topic: "orders", Partition: 2, Offset: 4, HighWaterMark: 10, Value: []byte("order")}
+	receive(t, handled)
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.offset.WithLabelValues("orders-group", "orders", "2")) == 5
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, c.Pause(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.paused.WithLabelValues("orders-group", "orders")))
+	c.Resume()
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.paused.WithLabelValues("orders-group", "orders")))
+}
+
+func TestPartitionStates(t *testing.T) {
+	members := map[int]kafka.DescribeGroupsResponseMember{
+		0: {MemberID: "kafka-go-1", ClientHost: "/10.0.0.1"},
+	}
+	committed := map[int]int64{0: 5, 1: -1}
+	last := map[int]int64{0: 7, 1: 3, 2: 0}
+
+	states := partitionStates([]int{0, 1, 2}, members, committed, last)
+
+	require.Len(t, states, 3)
+	assert.Equal(t, "kafka-go-1", states[0].Member)
+	assert.Equal(t, "/10.0.0.1", states[0].Host)
+	assert.Equal(t, int64(5), states[0].Committed)
+	assert.Equal(t, int64(2), states[0].Lag)
+	assert.Equal(t, int64(-1), states[1].Committed)
+	assert.Equal(t, int64(3), states[1].Lag)
+	assert.Empty(t, states[1].Member)
+	assert.Equal(t, int64(-1), states[2].Committed)
+	assert.Equal(t, int64(0), states[2].Lag)
+}
+
+func TestMetrics_SetLag(t *testing.T) {
+	m := NewMetrics(prometheus.NewRegistry())
+
+	m.setLag("orders-group", "orders", partitionStates([]int{0, 1}, nil, map[int]int64{0: 5}, map[int]int64{0: 7, 1: 3}))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.lag.WithLabelValues("orders-group", "orders", "0")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.lag.WithLabelValues("orders-group", "orders", "1")))
+}
+
+func TestConsumer_ResetOffsetsRequiresPause(t *testing.T) {
+	c := newTestConsumer(newFakeReader())
+
+	_, err := c.ResetOffsets(context.Background(), time.Now())
+
+	assert.ErrorIs(t, err, models.ErrConsumerRunning)
+}
+
+func TestConsumer_ShutdownDrainsHandler(t *testing.T) {
+	r := newFakeReader()
+	c := newTestConsumer(r, WithDrainTimeout(time.Second))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(ctx, func(ctx context.Context, value []byte, headers map[string]string) error {
+			close(started)
+			<-release
+			return ctx.Err()
+		})
+	}()
+
+	r.msgs <- kafka.Message{Topic: "orders", Offset: 7}
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+		t.Fatal("Start returned before the handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-done)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	require.Len(t, r.committed, 1)
+	assert.Equal(t, int64(7), r.committed[0].Offset)
+}
+
+func TestConsumer_ShutdownDeadline(t *testing.T) {
+	r := newFakeReader()
+	c := newTestConsumer(r, WithDrainTimeout(20*time.Millisecond))
+
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(ctx, func(ctx context.Context, value []byte, headers map[string]string) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	r.msgs <- kafka.Message{Topic: "orders", Offset: 7}
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("handler was not canceled after the drain timeout")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assert.Empty(t, r.committed)
+}
+
+func TestConsumer_Close(t *testing.T) {
+	r := newFakeReader()
+	c := newTestConsumer(r)
+	c.dlqWriter = &kafka.Writer{Addr: kafka.TCP("localhost:9092"), Topic: "DLQ"}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(context.Background(), func(ctx context.Context, value []byte, headers map[string]string) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+
+	r.msgs <- kafka.Message{Topic: "orders", Offset: 3}
+	<-started
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- c.Close()
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-closed)
+	require.NoError(t, <-done)
+
+	r.mu.Lock()
+	assert.Len(t, r.committed, 1)
+	assert.True(t, r.closed)
+	r.mu.Unlock()
+	err := c.dlqWriter.WriteMessages(context.Background(), kafka.Message{Value: []byte("x")})
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestConsumer_ClosePaused(t *testing.T) {
+	r := newFakeReader()
+	c := newTestConsumer(r)
+	require.NoError(t, c.Pause(context.Background()))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start(context.Background(), func(ctx context.Context, value []byte, headers map[string]string) error {
+			return nil
+		})
+	}()
+
+	require.NoError(t, c.Close())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Start didn't return after Close")
+	}
+}
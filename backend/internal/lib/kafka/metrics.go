@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"WB/internal/models"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+// Metrics exposes the progress of a Consumer per partition.
+// A nil *Metrics records nothing.
+type Metrics struct {
+	offset *prometheus.GaugeVec
+	lag    *prometheus.GaugeVec
+	paused *prometheus.GaugeVec
+}
+
+// NewMetrics creates the consumer metrics and registers them with reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		offset: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kafka_consumer_committed_offset",
+			Help: "Next offset of the partition the consumer group reads.",
+		}, []string{"group", "topic", "partition"}),
+		lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kafka_consumer_lag",
+			Help: "Messages of the partition after the offset committed by the consumer group.",
+		}, []string{"group", "topic", "partition"}),
+		paused: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kafka_consumer_paused",
+			Help: "Whether consumption is paused by an administrator.",
+		}, []string{"group", "topic"}),
+	}
+	reg.MustRegister(m.offset, m.lag, m.paused)
+	return m
+}
+
+// setLag records the lag of every partition of the topic.
+func (m *Metrics) setLag(group, topic string, partitions []models.PartitionState) {
+	if m == nil {
+		return
+	}
+	for _, p := range partitions {
+		m.lag.WithLabelValues(group, topic, strconv.Itoa(p.Partition)).Set(float64(p.Lag))
+	}
+}
+
+// committed records the offset following msg.
+func (m *Metrics) committed(group string, msg kafka.Message) {
+	if m == nil {
+		return
+	}
+	m.offset.WithLabelValues(group, msg.Topic, strconv.Itoa(msg.Partition)).Set(float64(msg.Offset + 1))
+}
+
+func (m *Metrics) setPaused(group, topic string, paused bool) {
+	if m == nil {
+		return
+	}
+	v := 0.0
+	if paused {
+		v = 1
+	}
+	m.paused.WithLabelValues(group, topic).Set(v)
+}
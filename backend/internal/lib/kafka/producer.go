@@ -3,7 +3,9 @@
 package kafka
 
 import (
+	"WB/internal/config"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -15,26 +17,48 @@ import (
 // Producer represents Message broker producer.
 type Producer struct {
 	writer *kafka.Writer
+	// async batches the messages of SendAsync in the background, nil unless enabled.
+	async      *kafka.Writer
+	onDelivery DeliveryCallback
 }
 
-// MustProducer initializes Message broker producer.
+// errAsyncDisabled is returned by SendAsync of a producer without async sends.
+var errAsyncDisabled = errors.New("async sends are not enabled")
+
+// DeliveryCallback receives the outcome of every message written by a Producer,
+// err is nil once the broker acknowledged the message.
+type DeliveryCallback func(key string, err error)
+
+// ProducerOption configures optional Producer settings.
+type ProducerOption func(*Producer)
+
+// WithDeliveryCallback reports the outcome of every written message to fn.
+// For SendAsync it is the only way to learn that a message was lost.
+func WithDeliveryCallback(fn DeliveryCallback) ProducerOption {
+	return func(p *Producer) {
+		p.onDelivery = fn
+	}
+}
+
+// MustProducer initializes Message broker producer connected with the given security.
+// Messages are partitioned by key, so all messages of an order keep their order.
 // If a failure occurs during migration, os.exit is executed
-func MustProducer(log *slog.Logger, brokers []string, topic string) *Producer {
+func MustProducer(log *slog.Logger, brokers []string, topic string, sec *Security, cfg config.KafkaProducer, opts ...ProducerOption) *Producer {
 	const op = "kafka.produser.MustProducer"
 
-	writer := &kafka.Writer{
-		Addr:                   kafka.TCP(brokers...),
-		Topic:                  topic,
-		Balancer:               &kafka.LeastBytes{},
-		WriteTimeout:           5 * time.Second,
-		RequiredAcks:           kafka.RequireOne,
-		AllowAutoTopicCreation: true,
+	p, err := newProducer(brokers, topic, sec, cfg, opts...)
+	if err != nil {
+		log.Error("invalid kafka producer config",
+			slog.String("op", op),
+			slog.String("topic", topic),
+			slog.Any("err", err))
+		os.Exit(1)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	conn, err := kafka.DialContext(ctx, "tcp", brokers[0])
+	conn, err := sec.dialer().DialContext(ctx, "tcp", brokers[0])
 	if err != nil {
 		log.Error("failed to dial kafka broker",
 			slog.String("op", op),
@@ -51,10 +75,79 @@ func MustProducer(log *slog.Logger, brokers []string, topic string) *Producer {
 		os.Exit(1)
 	}
 
-	return &Producer{writer: writer}
+	return p
+}
+
+// newProducer builds a producer whose writer follows cfg.
+func newProducer(brokers []string, topic string, sec *Security, cfg config.KafkaProducer, opts ...ProducerOption) (*Producer, error) {
+	acks, err := requiredAcks(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var compression kafka.Compression
+	if cfg.Compression != "" {
+		if err := compression.UnmarshalText([]byte(cfg.Compression)); err != nil {
+			return nil, fmt.Errorf("unknown compression %q", cfg.Compression)
+		}
+	}
+
+	newWriter := func(async bool) *kafka.Writer {
+		return &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Topic:                  topic,
+			Balancer:               kafka.Murmur2Balancer{},
+			MaxAttempts:            cfg.MaxAttempts,
+			BatchSize:              cfg.BatchSize,
+			BatchBytes:             cfg.BatchBytes,
+			BatchTimeout:           cfg.BatchTimeout,
+			WriteTimeout:           cfg.WriteTimeout,
+			RequiredAcks:           acks,
+			Async:                  async,
+			Compression:            compression,
+			AllowAutoTopicCreation: true,
+			Transport:              sec.transport(),
+		}
+	}
+
+	p := &Producer{writer: newWriter(false)}
+	if cfg.Async {
+		p.async = newWriter(true)
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.onDelivery != nil {
+		p.writer.Completion = p.complete
+		if p.async != nil {
+			p.async.Completion = p.complete
+		}
+	}
+
+	return p, nil
+}
+
+// requiredAcks parses cfg.Acks, an empty value means "one".
+func requiredAcks(cfg config.KafkaProducer) (kafka.RequiredAcks, error) {
+	acks := kafka.RequireOne
+	if cfg.Acks != "" {
+		if err := acks.UnmarshalText([]byte(cfg.Acks)); err != nil {
+			return 0, err
+		}
+	}
+	return acks, nil
+}
+
+// complete is the writer completion hook, called once per written batch.
+func (p *Producer) complete(messages []kafka.Message, err error) {
+	for _, msg := range messages {
+		p.onDelivery(string(msg.Key), err)
+	}
 }
 
-// Send writes a batch of messages to the kafka topic configured on this writer.
+// Send writes a message to the kafka topic configured on this writer.
+// It returns once the broker acknowledged the message.
 func (p *Producer) Send(ctx context.Context, key string, value []byte) error {
 	const op = "kafka.produser.Send"
 
@@ -68,8 +161,53 @@ func (p *Producer) Send(ctx context.Context, key string, value []byte) error {
 	return nil
 }
 
-// Close flushes pending writes, and waits for all writes to complete before returning
+// SendAsync queues a message with the headers, if any, for the kafka topic
+// configured on this writer and returns before the broker acknowledges it.
+// Queued messages are written in batches; the outcome is reported to the
+// delivery callback only, so callers that must know the message was stored
+// use Send. It fails unless async sends are enabled in the config.
+func (p *Producer) SendAsync(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	const op = "kafka.produser.SendAsync"
+
+	if p.async == nil {
+		return fmt.Errorf("%s: %w", op, errAsyncDisabled)
+	}
+
+	if err := p.async.WriteMessages(ctx, message(key, value, headers)); err != nil {
+		return fmt.Errorf("%s: failed to queue message: %w", op, err)
+	}
+	return nil
+}
+
+// SendHeaders writes a message with the headers to the kafka topic configured on this writer.
+func (p *Producer) SendHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	const op = "kafka.produser.SendHeaders"
+
+	if err := p.writer.WriteMessages(ctx, message(key, value, headers)); err != nil {
+		return fmt.Errorf("%s: failed to send message: %w", op, err)
+	}
+	return nil
+}
+
+// message builds a kafka message with the headers.
+func message(key string, value []byte, headers map[string]string) kafka.Message {
+	msg := kafka.Message{
+		Key:   []byte(key),
+		Value: value,
+	}
+	for k, v := range headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return msg
+}
+
+// Close flushes pending writes, including the queued async ones, and waits
+// for all writes to complete before returning
 // Should be called on application shutdown.
 func (p *Producer) Close() error {
-	return p.writer.Close()
+	err := p.writer.Close()
+	if p.async != nil {
+		err = errors.Join(err, p.async.Close())
+	}
+	return err
 }
@@ -0,0 +1,123 @@
+package kafka
+
+import (
+	"WB/internal/config"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProducer(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             config.KafkaProducer
+		wantAcks        kafka.RequiredAcks
+		wantCompression kafka.Compression
+		wantErr         bool
+	}{
+		{name: "defaults", cfg: config.KafkaProducer{}, wantAcks: kafka.RequireOne},
+		{name: "acks all", cfg: config.KafkaProducer{Acks: "all"}, wantAcks: kafka.RequireAll},
+		{name: "acks none", cfg: config.KafkaProducer{Acks: "none"}, wantAcks: kafka.RequireNone},
+		{name: "unknown acks", cfg: config.KafkaProducer{Acks: "some"}, wantErr: true},
+		{name: "zstd", cfg: config.KafkaProducer{Compression: "zstd"}, wantAcks: kafka.RequireOne, wantCompression: kafka.Zstd},
+		{name: "none compression", cfg: config.KafkaProducer{Compression: "none"}, wantAcks: kafka.RequireOne},
+		{name: "unknown compression", cfg: config.KafkaProducer{Compression: "brotli"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newProducer([]string{"localhost:9092"}, "orders", nil, tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantAcks, p.writer.RequiredAcks)
+			assert.Equal(t, tt.wantCompression, p.writer.Compression)
+			assert.Nil(t, p.writer.Completion)
+		})
+	}
+}
+
+func TestNewProducer_Batching(t *testing.T) {
+	cfg := config.KafkaProducer{
+		MaxAttempts:  3,
+		BatchSize:    50,
+		BatchBytes:   1 << 20,
+		BatchTimeout: 10 * time.Millisecond,
+		WriteTimeout: 2 * time.Second,
+	}
+
+	p, err := newProducer([]string{"localhost:9092"}, "orders", nil, cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, p.writer.MaxAttempts)
+	assert.Equal(t, 50, p.writer.BatchSize)
+	assert.Equal(t, int64(1<<20), p.writer.BatchBytes)
+	assert.Equal(t, 10*time.Millisecond, p.writer.BatchTimeout)
+	assert.Equal(t, 2*time.Second, p.writer.WriteTimeout)
+	assert.False(t, p.writer.Async)
+	assert.Nil(t, p.async)
+}
+
+func TestNewProducer_Async(t *testing.T) {
+	p, err := newProducer([]string{"localhost:9092"}, "orders", nil, config.KafkaProducer{BatchSize: 50, Async: true},
+		WithDeliveryCallback(func(key string, err error) {}))
+	require.NoError(t, err)
+
+	assert.False(t, p.writer.Async, "Send waits for the broker")
+	require.NotNil(t, p.async)
+	assert.True(t, p.async.Async)
+	assert.Equal(t, 50, p.async.BatchSize)
+	assert.NotNil(t, p.async.Completion)
+	assert.NoError(t, p.Close())
+}
+
+func TestProducer_SendAsyncDisabled(t *testing.T) {
+	p, err := newProducer([]string{"localhost:9092"}, "orders", nil, config.KafkaProducer{})
+	require.NoError(t, err)
+
+	err = p.SendAsync(context.Background(), "b563feb7b2b84b6test", []byte("{}"), nil)
+
+	assert.ErrorIs(t, err, errAsyncDisabled)
+}
+
+func TestNewProducer_KeyPartitioning(t *testing.T) {
+	p, err := newProducer([]string{"localhost:9092"}, "orders", nil, config.KafkaProducer{})
+	require.NoError(t, err)
+
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	msg := kafka.Message{Key: []byte("b563feb7b2b84b6test")}
+	want := p.writer.Balancer.Balance(msg, partitions...)
+	for range 10 {
+		assert.Equal(t, want, p.writer.Balancer.Balance(msg, partitions...))
+	}
+}
+
+func TestProducer_DeliveryCallback(t *testing.T) {
+	type delivery struct {
+		key string
+		err error
+	}
+	var got []delivery
+	p, err := newProducer([]string{"localhost:9092"}, "orders", nil, config.KafkaProducer{Async: true},
+		WithDeliveryCallback(func(key string, err error) {
+			got = append(got, delivery{key: key, err: err})
+		}))
+	require.NoError(t, err)
+	require.NotNil(t, p.writer.Completion)
+	require.NotNil(t, p.async.Completion)
+
+	errWrite := errors.New("leader not available")
+	p.writer.Completion([]kafka.Message{{Key: []byte("a")}, {Key: []byte("b")}}, nil)
+	p.writer.Completion([]kafka.Message{{Key: []byte("c")}}, errWrite)
+	// a batch of SendAsync is reported once it is written in the background
+	p.async.Completion([]kafka.Message{{Key: []byte("d")}}, errWrite)
+
+	assert.Equal(t, []delivery{{key: "a"}, {key: "b"}, {key: "c", err: errWrite}, {key: "d", err: errWrite}}, got)
+}
@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"WB/internal/config"
+	"WB/internal/lib/tlsconfig"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Supported SASL mechanisms.
+const (
+	SASLNone        = "none"
+	SASLPlain       = "plain"
+	SASLScramSHA256 = "scram-sha-256"
+	SASLScramSHA512 = "scram-sha-512"
+)
+
+const dialTimeout = 10 * time.Second
+
+// Security holds the TLS and SASL settings of broker connections.
+// A nil Security connects over plain TCP without authentication.
+type Security struct {
+	tls  *tls.Config
+	sasl sasl.Mechanism
+}
+
+// NewSecurity builds connection security from the Kafka config.
+func NewSecurity(cfg config.Kafka) (*Security, error) {
+	const op = "kafka.NewSecurity"
+
+	tlsCfg, err := tlsconfig.Client(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mechanism, err := saslMechanism(cfg.SASL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Security{tls: tlsCfg, sasl: mechanism}, nil
+}
+
+// saslMechanism returns the configured SASL mechanism or nil if SASL is off.
+func saslMechanism(cfg config.SASL) (sasl.Mechanism, error) {
+	switch cfg.Mechanism {
+	case "", SASLNone:
+		return nil, nil
+	case SASLPlain:
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unknown sasl mechanism %q", cfg.Mechanism)
+	}
+}
+
+// dialer returns the dialer used by readers and connectivity checks.
+func (s *Security) dialer() *kafka.Dialer {
+	d := &kafka.Dialer{
+		Timeout:   dialTimeout,
+		DualStack: true,
+	}
+	if s != nil {
+		d.TLS = s.tls
+		d.SASLMechanism = s.sasl
+	}
+	return d
+}
+
+// transport returns the transport used by writers.
+func (s *Security) transport() *kafka.Transport {
+	t := &kafka.Transport{
+		DialTimeout: dialTimeout,
+	}
+	if s != nil {
+		t.TLS = s.tls
+		t.SASL = s.sasl
+	}
+	return t
+}
@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"WB/internal/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSecurity(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Kafka
+		wantSASL string
+		wantErr  bool
+	}{
+		{name: "plaintext", cfg: config.Kafka{}},
+		{name: "plain", cfg: config.Kafka{SASL: config.SASL{Mechanism: SASLPlain, Username: "u", Password: "p"}}, wantSASL: "PLAIN"},
+		{name: "scram-sha-256", cfg: config.Kafka{SASL: config.SASL{Mechanism: SASLScramSHA256, Username: "u", Password: "p"}}, wantSASL: "SCRAM-SHA-256"},
+		{name: "scram-sha-512", cfg: config.Kafka{SASL: config.SASL{Mechanism: SASLScramSHA512, Username: "u", Password: "p"}}, wantSASL: "SCRAM-SHA-512"},
+		{name: "unknown mechanism", cfg: config.Kafka{SASL: config.SASL{Mechanism: "gssapi"}}, wantErr: true},
+		{name: "bad tls", cfg: config.Kafka{TLS: config.TLS{Enabled: true, CAFile: "/nonexistent/ca.pem"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sec, err := NewSecurity(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			d := sec.dialer()
+			if tt.wantSASL == "" {
+				assert.Nil(t, d.SASLMechanism)
+				return
+			}
+			assert.Equal(t, tt.wantSASL, d.SASLMechanism.Name())
+			assert.Equal(t, d.SASLMechanism, sec.transport().SASL)
+		})
+	}
+}
+
+func TestSecurity_Nil(t *testing.T) {
+	var sec *Security
+
+	assert.Nil(t, sec.dialer().TLS)
+	assert.Nil(t, sec.transport().SASL)
+}
@@ -0,0 +1,69 @@
+// Package redact provides a slog.Handler that masks personal data in log attributes.
+package redact
+
+import (
+	"WB/internal/lib/masking"
+	"context"
+	"log/slog"
+)
+
+// Handler masks the tagged fields of struct values logged as attributes
+// (see package masking) before passing records to the wrapped handler.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler wraps next with redaction.
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+// Enabled reports whether the wrapped handler handles records at the level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle masks the record attributes and passes the record on.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+
+	return h.next.Handle(ctx, out)
+}
+
+// WithAttrs returns a handler with additional masked attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+
+	return &Handler{next: h.next.WithAttrs(redacted)}
+}
+
+// WithGroup returns a handler that puts the following attributes into the group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr masks the attribute value. Values implementing slog.LogValuer are resolved first.
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = redactAttr(ga)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		a.Value = slog.AnyValue(masking.Apply(a.Value.Any()))
+	}
+
+	return a
+}
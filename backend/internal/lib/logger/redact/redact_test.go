@@ -0,0 +1,52 @@
+package redact
+
+import (
+	"WB/internal/models"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	order := models.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Delivery: models.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: "test@gmail.com", City: "Kiryat Mozkin"},
+		Payment:  models.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD"},
+	}
+
+	var buf bytes.Buffer
+	log := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil)))
+
+	log.With(slog.Any("delivery", order.Delivery)).
+		WithGroup("req").
+		Info("order received", slog.Any("order", order), slog.Group("payment", slog.Any("value", &order.Payment)))
+
+	var got struct {
+		Delivery models.Delivery `json:"delivery"`
+		Req      struct {
+			Order   models.Order `json:"order"`
+			Payment struct {
+				Value models.Payment `json:"value"`
+			} `json:"payment"`
+		} `json:"req"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+
+	assert.Equal(t, "T***", got.Delivery.Name)
+	assert.Equal(t, "T***", got.Req.Order.Delivery.Name)
+	assert.Equal(t, "+***", got.Req.Order.Delivery.Phone)
+	assert.Equal(t, "t***@gmail.com", got.Req.Order.Delivery.Email)
+	assert.Equal(t, "Kiryat Mozkin", got.Req.Order.Delivery.City)
+	assert.Equal(t, "b***", got.Req.Order.Payment.Transaction)
+	assert.Equal(t, "b***", got.Req.Payment.Value.Transaction)
+	assert.Equal(t, "USD", got.Req.Payment.Value.Currency)
+	assert.Equal(t, "b563feb7b2b84b6test", got.Req.Order.OrderUID)
+
+	// the logged values are not modified
+	assert.Equal(t, "Test Testov", order.Delivery.Name)
+	assert.Equal(t, "b563feb7b2b84b6test", order.Payment.Transaction)
+}
@@ -3,6 +3,7 @@
 package slogpretty
 
 import (
+	"WB/internal/lib/logger/redact"
 	"context"
 	"encoding/json"
 	"io"
@@ -116,34 +117,31 @@ func (h *PrettyHandler) WithGroup(name string) slog.Handler {
 }
 
 // SetupLogger configures and returns a slog.Logger based on the environment.
+// Personal data in logged orders is masked in every environment.
 func SetupLogger(env string) *slog.Logger {
-	var log *slog.Logger
+	var handler slog.Handler
 
 	switch env {
 	case envLocal:
-		log = setupPrettySlog()
+		handler = setupPrettyHandler()
 	case envDev:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-		)
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
 	case envProd:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
-		)
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
+	default:
+		return nil
 	}
 
-	return log
+	return slog.New(redact.NewHandler(handler))
 }
 
-// setupPrettySlog creates a logger with pretty handler for local development.
-func setupPrettySlog() *slog.Logger {
+// setupPrettyHandler creates a pretty handler for local development.
+func setupPrettyHandler() slog.Handler {
 	opts := PrettyHandlerOptions{
 		SlogOpts: &slog.HandlerOptions{
 			Level: slog.LevelDebug,
 		},
 	}
 
-	handler := opts.NewPrettyHandler(os.Stdout)
-
-	return slog.New(handler)
+	return opts.NewPrettyHandler(os.Stdout)
 }
@@ -0,0 +1,172 @@
+// Package masking hides personal data in values before they leave the service.
+//
+// Fields to hide are marked with the mask struct tag naming the mask kind:
+//
+//	Email string `json:"email" mask:"email"`
+//
+// Only string fields can be masked. Nested structs, pointers, slices and maps
+// are walked, so tagging models.Delivery is enough to mask it inside models.Order.
+package masking
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Supported mask kinds.
+const (
+	KindPartial = "partial" // keeps the first character
+	KindEmail   = "email"   // masks the local part and keeps the domain
+	KindFull    = "full"    // hides the whole value
+)
+
+const (
+	tagName     = "mask"
+	placeholder = "***"
+)
+
+// tagged caches whether values of a type may contain masked fields.
+var tagged sync.Map // reflect.Type -> bool
+
+// String masks s according to the kind. Empty values stay empty.
+func String(kind, s string) string {
+	if s == "" {
+		return s
+	}
+
+	switch kind {
+	case KindEmail:
+		local, domain, ok := strings.Cut(s, "@")
+		if !ok {
+			return String(KindPartial, s)
+		}
+		return String(KindPartial, local) + "@" + domain
+	case KindPartial:
+		r := []rune(s)
+		if len(r) <= 1 {
+			return placeholder
+		}
+		return string(r[:1]) + placeholder
+	default:
+		return placeholder
+	}
+}
+
+// Apply returns a copy of v with every tagged field masked. v itself is not modified.
+func Apply[T any](v T) T {
+	rv := reflect.ValueOf(&v).Elem()
+	if !hasTags(rv.Type()) {
+		return v
+	}
+	var out T
+	reflect.ValueOf(&out).Elem().Set(mask(rv))
+	return out
+}
+
+// mask returns a masked copy of v. Values that can't contain tagged fields are returned as is.
+func mask(v reflect.Value) reflect.Value {
+	if !hasTags(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(mask(v.Elem()))
+		return out
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(mask(v.Elem()))
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if kind, ok := f.Tag.Lookup(tagName); ok && f.Type.Kind() == reflect.String {
+				out.Field(i).SetString(String(kind, v.Field(i).String()))
+				continue
+			}
+			out.Field(i).Set(mask(v.Field(i)))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(mask(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(mask(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), mask(iter.Value()))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// hasTags reports whether values of type t may contain tagged fields.
+// Interfaces are always walked, since their dynamic type is unknown.
+func hasTags(t reflect.Type) bool {
+	if v, ok := tagged.Load(t); ok {
+		return v.(bool)
+	}
+
+	res := scan(t, map[reflect.Type]bool{})
+	tagged.Store(t, res)
+	return res
+}
+
+// scan looks for tagged fields in t. seen breaks cycles of recursive types.
+func scan(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return scan(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if _, ok := f.Tag.Lookup(tagName); ok && f.Type.Kind() == reflect.String {
+				return true
+			}
+			if scan(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
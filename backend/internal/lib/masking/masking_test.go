@@ -0,0 +1,93 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		in   string
+		want string
+	}{
+		{name: "partial", kind: KindPartial, in: "Test Testov", want: "T***"},
+		{name: "partial unicode", kind: KindPartial, in: "Тест", want: "Т***"},
+		{name: "partial short", kind: KindPartial, in: "T", want: "***"},
+		{name: "email", kind: KindEmail, in: "test@gmail.com", want: "t***@gmail.com"},
+		{name: "email without domain", kind: KindEmail, in: "test", want: "t***"},
+		{name: "full", kind: KindFull, in: "+9720000000", want: "***"},
+		{name: "unknown kind", kind: "", in: "secret", want: "***"},
+		{name: "empty", kind: KindFull, in: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, String(tt.kind, tt.in))
+		})
+	}
+}
+
+type contact struct {
+	Name  string `mask:"partial"`
+	Email string `mask:"email"`
+	City  string
+}
+
+type account struct {
+	ID       int
+	Contact  contact
+	Backup   *contact
+	History  []contact
+	ByRegion map[string]contact
+	Extra    any
+	Token    string `mask:"full"`
+	Next     *account
+	note     string
+}
+
+func TestApply(t *testing.T) {
+	c := contact{Name: "Test Testov", Email: "test@gmail.com", City: "Kiryat Mozkin"}
+	masked := contact{Name: "T***", Email: "t***@gmail.com", City: "Kiryat Mozkin"}
+
+	in := account{
+		ID:       1,
+		Contact:  c,
+		Backup:   &c,
+		History:  []contact{c},
+		ByRegion: map[string]contact{"north": c},
+		Extra:    c,
+		Token:    "secret",
+		Next:     &account{Token: "other"},
+		note:     "kept",
+	}
+
+	out := Apply(in)
+
+	assert.Equal(t, 1, out.ID)
+	assert.Equal(t, masked, out.Contact)
+	assert.Equal(t, masked, *out.Backup)
+	assert.Equal(t, []contact{masked}, out.History)
+	assert.Equal(t, map[string]contact{"north": masked}, out.ByRegion)
+	assert.Equal(t, masked, out.Extra)
+	assert.Equal(t, "***", out.Token)
+	assert.Equal(t, "***", out.Next.Token)
+	assert.Equal(t, "kept", out.note)
+
+	// the original is untouched
+	assert.Equal(t, c, in.Contact)
+	assert.Equal(t, c, *in.Backup)
+	assert.Equal(t, []contact{c}, in.History)
+	assert.Equal(t, c, in.ByRegion["north"])
+	assert.Equal(t, "other", in.Next.Token)
+}
+
+func TestApply_Untagged(t *testing.T) {
+	type plain struct{ Name string }
+
+	assert.Equal(t, plain{Name: "Test"}, Apply(plain{Name: "Test"}))
+	assert.Equal(t, "Test", Apply("Test"))
+	assert.Nil(t, Apply[any](nil))
+	assert.Nil(t, Apply[*contact](nil))
+}
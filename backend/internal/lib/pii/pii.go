@@ -0,0 +1,211 @@
+// Package pii implements envelope encryption of personal data fields.
+//
+// Every value is encrypted with its own random data key (AES-256-GCM), and
+// the data key is wrapped with a key encryption key from the keyring. The
+// result is a self-describing string:
+//
+//	enc:v1:<key id>:<base64 wrapped data key>:<base64 nonce+ciphertext>
+//
+// Values without the enc: prefix are treated as legacy plaintext.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	prefix     = "enc:v1:"
+	keySize    = 32
+	partsCount = 3
+)
+
+// ErrUnknownKey is returned when a value is encrypted with a key missing from the keyring.
+var ErrUnknownKey = errors.New("unknown key id")
+
+// Keyring holds key encryption keys by ID. New values are encrypted with the active key.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// keyringFile is the on-disk keyring format.
+type keyringFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"` // base64 encoded 32 bytes
+	} `json:"keys"`
+}
+
+// LoadKeyring reads the keyring from a local JSON file.
+func LoadKeyring(path string) (*Keyring, error) {
+	const op = "pii.LoadKeyring"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: read keyring: %w", op, err)
+	}
+
+	var f keyringFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: parse keyring: %w", op, err)
+	}
+
+	keys := make(map[string][]byte, len(f.Keys))
+	for _, k := range f.Keys {
+		raw, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, k.ID, err)
+		}
+		keys[k.ID] = raw
+	}
+
+	kr, err := NewKeyring(f.Active, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return kr, nil
+}
+
+// NewKeyring creates a keyring from raw 32-byte keys.
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	kr := &Keyring{active: active, keys: make(map[string]cipher.AEAD, len(keys))}
+
+	for id, raw := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("key %q must be %d bytes", id, keySize)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		kr.keys[id] = aead
+	}
+
+	if _, ok := kr.keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", active)
+	}
+
+	return kr, nil
+}
+
+// Encrypt seals the value of the named field with a fresh data key wrapped
+// by the active key. The field name is authenticated, so a ciphertext
+// cannot be moved to another field.
+func (k *Keyring) Encrypt(field, plaintext string) (string, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("generate data key: %w", err)
+	}
+
+	wrapped, err := seal(k.keys[k.active], dek, []byte(k.active))
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	ct, err := seal(aead, []byte(plaintext), []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("encrypt value: %w", err)
+	}
+
+	return prefix + k.active + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ct), nil
+}
+
+// Decrypt opens a value produced by Encrypt for the same field.
+// Legacy plaintext values are returned unchanged.
+func (k *Keyring) Decrypt(field, value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+
+	parts := strings.SplitN(rest, ":", partsCount)
+	if len(parts) != partsCount {
+		return "", errors.New("malformed encrypted value")
+	}
+	kid := parts[0]
+
+	kek, ok := k.keys[kid]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("decode data key: %w", err)
+	}
+	ct, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("decode value: %w", err)
+	}
+
+	dek, err := open(kek, wrapped, []byte(kid))
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	pt, err := open(aead, ct, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+
+	return string(pt), nil
+}
+
+// NeedsRotation reports whether the value is plaintext or encrypted with a non-active key.
+func (k *Keyring) NeedsRotation(value string) bool {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return true
+	}
+	kid, _, _ := strings.Cut(rest, ":")
+	return kid != k.active
+}
+
+// newAEAD creates AES-GCM for the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data and prepends the random nonce.
+func seal(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, ad), nil
+}
+
+// open splits off the nonce and decrypts data.
+func open(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ct := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ct, ad)
+}
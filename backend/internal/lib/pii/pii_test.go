@@ -0,0 +1,109 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, keySize)
+	key2 = bytes.Repeat([]byte{2}, keySize)
+)
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	kr, err := NewKeyring("k1", map[string][]byte{"k1": key1})
+	require.NoError(t, err)
+
+	enc, err := kr.Encrypt("phone", "+9720000000")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enc, "enc:v1:k1:"))
+	assert.NotContains(t, enc, "9720000000")
+
+	dec, err := kr.Decrypt("phone", enc)
+	require.NoError(t, err)
+	assert.Equal(t, "+9720000000", dec)
+
+	other, err := kr.Encrypt("phone", "+9720000000")
+	require.NoError(t, err)
+	assert.NotEqual(t, enc, other, "every value gets its own data key")
+}
+
+func TestKeyring_Decrypt_Errors(t *testing.T) {
+	kr, err := NewKeyring("k1", map[string][]byte{"k1": key1})
+	require.NoError(t, err)
+
+	enc, err := kr.Encrypt("phone", "+9720000000")
+	require.NoError(t, err)
+
+	_, err = kr.Decrypt("email", enc)
+	assert.Error(t, err, "ciphertext is bound to its field")
+
+	last := "A"
+	if strings.HasSuffix(enc, last) {
+		last = "B"
+	}
+	tampered := enc[:len(enc)-1] + last
+	_, err = kr.Decrypt("phone", tampered)
+	assert.Error(t, err)
+
+	other, err := NewKeyring("k2", map[string][]byte{"k2": key2})
+	require.NoError(t, err)
+	_, err = other.Decrypt("phone", enc)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	plain, err := kr.Decrypt("phone", "+9720000000")
+	require.NoError(t, err)
+	assert.Equal(t, "+9720000000", plain, "legacy plaintext is passed through")
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	old, err := NewKeyring("k1", map[string][]byte{"k1": key1})
+	require.NoError(t, err)
+	enc, err := old.Encrypt("name", "Test Testov")
+	require.NoError(t, err)
+
+	kr, err := NewKeyring("k2", map[string][]byte{"k1": key1, "k2": key2})
+	require.NoError(t, err)
+
+	assert.True(t, kr.NeedsRotation(enc))
+	assert.True(t, kr.NeedsRotation("Test Testov"))
+
+	dec, err := kr.Decrypt("name", enc)
+	require.NoError(t, err)
+	reenc, err := kr.Encrypt("name", dec)
+	require.NoError(t, err)
+	assert.False(t, kr.NeedsRotation(reenc))
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	content := `{"active":"k2","keys":[` +
+		`{"id":"k1","key":"` + base64.StdEncoding.EncodeToString(key1) + `"},` +
+		`{"id":"k2","key":"` + base64.StdEncoding.EncodeToString(key2) + `"}]}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	kr, err := LoadKeyring(path)
+	require.NoError(t, err)
+
+	enc, err := kr.Encrypt("email", "test@gmail.com")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enc, "enc:v1:k2:"))
+}
+
+func TestNewKeyring_Invalid(t *testing.T) {
+	_, err := NewKeyring("k3", map[string][]byte{"k1": key1})
+	assert.Error(t, err, "active key must exist")
+
+	_, err = NewKeyring("k1", map[string][]byte{"k1": key1[:16]})
+	assert.Error(t, err, "keys must be 32 bytes")
+
+	_, err = NewKeyring("a:b", map[string][]byte{"a:b": key1})
+	assert.Error(t, err, "key ids must not contain the separator")
+}
@@ -0,0 +1,98 @@
+// Package policy implements role- and tenant-based access rules for orders.
+package policy
+
+import (
+	"WB/internal/config"
+	"WB/internal/lib/auth"
+	"WB/internal/models"
+	"fmt"
+	"strconv"
+)
+
+// Rule scopes.
+const (
+	ScopeAll    = "all"
+	ScopeTenant = "tenant"
+)
+
+// Tenant fields of an order.
+const (
+	FieldEntry = "entry"
+	FieldSmID  = "sm_id"
+)
+
+// Decision is the outcome of an access check.
+type Decision struct {
+	Allow bool
+	// MaskPII requires personal data to be masked before the order is returned.
+	MaskPII bool
+}
+
+// Policy evaluates access rules. Anything not explicitly granted is denied.
+type Policy struct {
+	rules []config.AuthzRule
+}
+
+// New validates the rules and creates a policy.
+func New(cfg config.Authz) (*Policy, error) {
+	const op = "policy.New"
+
+	for i, rule := range cfg.Rules {
+		if rule.Role == "" {
+			return nil, fmt.Errorf("%s: rule %d: role is required", op, i)
+		}
+
+		switch rule.Scope {
+		case ScopeAll:
+		case ScopeTenant:
+			if len(rule.TenantFields) == 0 {
+				return nil, fmt.Errorf("%s: rule %d: tenant scope requires tenant_fields", op, i)
+			}
+			for _, f := range rule.TenantFields {
+				if f != FieldEntry && f != FieldSmID {
+					return nil, fmt.Errorf("%s: rule %d: unknown tenant field %q", op, i, f)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%s: rule %d: unknown scope %q", op, i, rule.Scope)
+		}
+	}
+
+	return &Policy{rules: cfg.Rules}, nil
+}
+
+// Authorize returns the decision of the first rule granting the caller access to the order.
+func (p *Policy) Authorize(id auth.Identity, order models.Order) Decision {
+	for _, rule := range p.rules {
+		if !id.HasRole(rule.Role) {
+			continue
+		}
+		if rule.Scope == ScopeAll || ownsOrder(id.Tenant, rule.TenantFields, order) {
+			return Decision{Allow: true, MaskPII: rule.MaskPII}
+		}
+	}
+
+	return Decision{}
+}
+
+// ownsOrder reports whether any of the tenant fields of the order equals tenant.
+func ownsOrder(tenant string, fields []string, order models.Order) bool {
+	if tenant == "" {
+		return false
+	}
+
+	for _, f := range fields {
+		switch f {
+		case FieldEntry:
+			if order.Entry == tenant {
+				return true
+			}
+		case FieldSmID:
+			if strconv.Itoa(order.SmID) == tenant {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"WB/internal/config"
+	"WB/internal/lib/auth"
+	"WB/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Authorize(t *testing.T) {
+	p, err := New(config.Authz{Rules: []config.AuthzRule{
+		{Role: "admin", Scope: ScopeAll},
+		{Role: "support", Scope: ScopeAll, MaskPII: true},
+		{Role: "seller", Scope: ScopeTenant, TenantFields: []string{FieldEntry, FieldSmID}},
+	}})
+	require.NoError(t, err)
+
+	order := models.Order{OrderUID: "order", Entry: "WBIL", SmID: 99}
+
+	tests := []struct {
+		name string
+		id   auth.Identity
+		want Decision
+	}{
+		{
+			name: "admin",
+			id:   auth.Identity{Roles: []string{"admin"}},
+			want: Decision{Allow: true},
+		},
+		{
+			name: "support masked",
+			id:   auth.Identity{Roles: []string{"support"}},
+			want: Decision{Allow: true, MaskPII: true},
+		},
+		{
+			name: "seller by entry",
+			id:   auth.Identity{Roles: []string{"seller"}, Tenant: "WBIL"},
+			want: Decision{Allow: true},
+		},
+		{
+			name: "seller by sm_id",
+			id:   auth.Identity{Roles: []string{"seller"}, Tenant: "99"},
+			want: Decision{Allow: true},
+		},
+		{
+			name: "foreign seller",
+			id:   auth.Identity{Roles: []string{"seller"}, Tenant: "OTHER"},
+			want: Decision{},
+		},
+		{
+			name: "seller without tenant",
+			id:   auth.Identity{Roles: []string{"seller"}},
+			want: Decision{},
+		},
+		{
+			name: "unknown role",
+			id:   auth.Identity{Roles: []string{"guest"}},
+			want: Decision{},
+		},
+		{
+			name: "no roles",
+			id:   auth.Identity{},
+			want: Decision{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.Authorize(tt.id, order))
+		})
+	}
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.AuthzRule
+	}{
+		{name: "no role", rule: config.AuthzRule{Scope: ScopeAll}},
+		{name: "unknown scope", rule: config.AuthzRule{Role: "seller", Scope: "own"}},
+		{name: "tenant without fields", rule: config.AuthzRule{Role: "seller", Scope: ScopeTenant}},
+		{name: "unknown field", rule: config.AuthzRule{Role: "seller", Scope: ScopeTenant, TenantFields: []string{"customer_id"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(config.Authz{Rules: []config.AuthzRule{tt.rule}})
+			assert.Error(t, err)
+		})
+	}
+}
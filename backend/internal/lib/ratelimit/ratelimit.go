@@ -0,0 +1,137 @@
+// Package ratelimit implements token bucket rate limiting.
+// Buckets live in Redis so limits hold across replicas; an in-process
+// limiter takes over while Redis is unavailable.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit describes a token bucket: up to Burst requests at once, refilled at Rate per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long to wait for the next token when the request is denied.
+	RetryAfter time.Duration
+	// ResetAfter is how long it takes for the bucket to refill completely.
+	ResetAfter time.Duration
+}
+
+// Limiter takes a token from the bucket identified by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Local keeps token buckets in process memory.
+type Local struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// bucket keeps the limit it was taken with, so sweep refills it at its own rate.
+type bucket struct {
+	tokens float64
+	ts     time.Time
+	limit  Limit
+}
+
+// sweepInterval is how often full buckets are dropped from memory.
+const sweepInterval = time.Minute
+
+// NewLocal creates an in-process limiter.
+func NewLocal() *Local {
+	return &Local{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket. It never returns an error.
+func (l *Local) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), ts: now}
+		l.buckets[key] = b
+	}
+	b.limit = limit
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.ts).Seconds()*limit.Rate)
+	b.ts = now
+
+	res := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.ResetAfter = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	return res, nil
+}
+
+// sweep drops buckets that have refilled completely, as they hold no state. Must hold l.mu.
+func (l *Local) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.ts).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Fallback uses the primary limiter and switches to the secondary one
+// for the requests the primary fails to serve.
+type Fallback struct {
+	log       *slog.Logger
+	primary   Limiter
+	secondary Limiter
+}
+
+// NewFallback creates a limiter that falls back to secondary on primary errors.
+func NewFallback(log *slog.Logger, primary, secondary Limiter) *Fallback {
+	return &Fallback{log: log, primary: primary, secondary: secondary}
+}
+
+// Allow takes a token from the primary limiter or, if it fails, from the secondary one.
+func (f *Fallback) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := f.primary.Allow(ctx, key, limit)
+	if err == nil {
+		return res, nil
+	}
+
+	f.log.Warn("primary rate limiter failed, using fallback",
+		slog.String("op", "ratelimit.Fallback.Allow"),
+		slog.String("error", err.Error()),
+	)
+
+	return f.secondary.Allow(ctx, key, limit)
+}
+
+// seconds converts fractional seconds to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
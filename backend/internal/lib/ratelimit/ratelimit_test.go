@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal_Allow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	l := NewLocal()
+	l.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 1, Burst: 2}
+
+	res, err := l.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	res, _ = l.Allow(ctx, "client", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, _ = l.Allow(ctx, "client", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 2*time.Second, res.ResetAfter)
+
+	res, _ = l.Allow(ctx, "other", limit)
+	assert.True(t, res.Allowed, "buckets are per key")
+
+	now = now.Add(time.Second)
+	res, _ = l.Allow(ctx, "client", limit)
+	assert.True(t, res.Allowed, "token is refilled")
+}
+
+func TestLocal_SweepUsesBucketLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	l := NewLocal()
+	l.now = func() time.Time { return now }
+
+	slow := Limit{Rate: 0.001, Burst: 1}
+	fast := Limit{Rate: 100, Burst: 1}
+
+	_, _ = l.Allow(ctx, "slow", slow)
+	now = now.Add(sweepInterval)
+	_, _ = l.Allow(ctx, "fast", fast)
+
+	res, _ := l.Allow(ctx, "slow", slow)
+	assert.False(t, res.Allowed, "the slow bucket is not refilled by the fast limit")
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("redis is down")
+}
+
+func TestFallback_Allow(t *testing.T) {
+	ctx := context.Background()
+	f := NewFallback(slog.New(slog.NewTextHandler(io.Discard, nil)), failingLimiter{}, NewLocal())
+
+	limit := Limit{Rate: 1, Burst: 1}
+
+	res, err := f.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	res, err = f.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+}
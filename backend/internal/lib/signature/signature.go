@@ -0,0 +1,272 @@
+// Package signature verifies internal_signature of incoming orders.
+//
+// The signature is computed over the canonical form of the order: the JSON
+// encoding of the signed fields listed in canonicalOrder, in that order, with
+// internal_signature set to "", date_created in UTC, no insignificant
+// whitespace and no HTML escaping. Fields added to models.Order later, such
+// as version and updated_at, are not signed.
+// internal_signature holds the base64 (standard encoding) HMAC-SHA256 or
+// Ed25519 signature of that form. Keys are selected by the order entry.
+package signature
+
+import (
+	"WB/internal/config"
+	"WB/internal/models"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Supported signature algorithms.
+const (
+	AlgHMACSHA256 = "hmac-sha256"
+	AlgEd25519    = "ed25519"
+)
+
+// Policies for orders without a signature.
+const (
+	UnsignedAllow  = "allow"
+	UnsignedWarn   = "warn"
+	UnsignedReject = "reject"
+)
+
+// key verifies signatures of one entry.
+type key struct {
+	alg    string
+	secret []byte
+}
+
+// Verifier checks order signatures with the keys of the order entry.
+type Verifier struct {
+	log      *slog.Logger
+	unsigned string
+	keys     map[string][]key
+}
+
+// New parses the signing keys. Several keys per entry are accepted during rotation.
+func New(log *slog.Logger, cfg config.Signing) (*Verifier, error) {
+	const op = "signature.New"
+
+	v := &Verifier{
+		log:      log.With(slog.String("component", "signature")),
+		unsigned: cfg.Unsigned,
+		keys:     make(map[string][]key, len(cfg.Keys)),
+	}
+
+	switch v.unsigned {
+	case "":
+		v.unsigned = UnsignedAllow
+	case UnsignedAllow, UnsignedWarn, UnsignedReject:
+	default:
+		return nil, fmt.Errorf("%s: unknown unsigned policy %q", op, cfg.Unsigned)
+	}
+
+	for entry, keys := range cfg.Keys {
+		for i, k := range keys {
+			secret, err := base64.StdEncoding.DecodeString(k.Key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: entry %q key %d: decode: %w", op, entry, i, err)
+			}
+
+			switch k.Algorithm {
+			case AlgHMACSHA256:
+				if len(secret) < sha256.Size {
+					return nil, fmt.Errorf("%s: entry %q key %d: hmac secret must be at least %d bytes", op, entry, i, sha256.Size)
+				}
+			case AlgEd25519:
+				if len(secret) != ed25519.PublicKeySize {
+					return nil, fmt.Errorf("%s: entry %q key %d: ed25519 public key must be %d bytes", op, entry, i, ed25519.PublicKeySize)
+				}
+			default:
+				return nil, fmt.Errorf("%s: entry %q key %d: unknown algorithm %q", op, entry, i, k.Algorithm)
+			}
+
+			v.keys[entry] = append(v.keys[entry], key{alg: k.Algorithm, secret: secret})
+		}
+	}
+
+	return v, nil
+}
+
+// Verify checks the order signature. Unsigned orders are handled by the
+// configured policy: allowed, allowed with a warning or rejected with
+// models.ErrUnsignedOrder. A wrong signature yields models.ErrInvalidSignature.
+func (v *Verifier) Verify(order models.Order) error {
+	if order.InternalSignature == "" {
+		switch v.unsigned {
+		case UnsignedReject:
+			return fmt.Errorf("order %s from entry %q: %w", order.OrderUID, order.Entry, models.ErrUnsignedOrder)
+		case UnsignedWarn:
+			v.log.Warn("unsigned order accepted",
+				slog.String("order_uid", order.OrderUID),
+				slog.String("entry", order.Entry),
+			)
+		}
+		return nil
+	}
+
+	keys := v.keys[order.Entry]
+	if len(keys) == 0 {
+		return fmt.Errorf("order %s: no signing key for entry %q: %w", order.OrderUID, order.Entry, models.ErrInvalidSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(order.InternalSignature)
+	if err != nil {
+		return fmt.Errorf("order %s: signature is not base64: %w", order.OrderUID, models.ErrInvalidSignature)
+	}
+
+	data, err := Canonical(order)
+	if err != nil {
+		return fmt.Errorf("order %s: %w", order.OrderUID, err)
+	}
+
+	for _, k := range keys {
+		if k.verify(data, sig) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("order %s: signature does not match any key of entry %q: %w", order.OrderUID, order.Entry, models.ErrInvalidSignature)
+}
+
+// verify reports whether sig is a valid signature of data.
+func (k key) verify(data, sig []byte) bool {
+	switch k.alg {
+	case AlgHMACSHA256:
+		return hmac.Equal(sig, macSHA256(k.secret, data))
+	case AlgEd25519:
+		return ed25519.Verify(ed25519.PublicKey(k.secret), data, sig)
+	default:
+		return false
+	}
+}
+
+// canonicalOrder lists the signed fields of an order. It must not change,
+// or signatures of existing producers stop matching.
+type canonicalOrder struct {
+	OrderUID          string            `json:"order_uid"`
+	TrackNumber       string            `json:"track_number"`
+	Entry             string            `json:"entry"`
+	Delivery          canonicalDelivery `json:"delivery"`
+	Payment           canonicalPayment  `json:"payment"`
+	Items             []canonicalItem   `json:"items"`
+	Locale            string            `json:"locale"`
+	InternalSignature string            `json:"internal_signature"`
+	CustomerID        string            `json:"customer_id"`
+	DeliveryService   string            `json:"delivery_service"`
+	Shardkey          string            `json:"shardkey"`
+	SmID              int               `json:"sm_id"`
+	DateCreated       time.Time         `json:"date_created"`
+	OofShard          string            `json:"oof_shard"`
+}
+
+type canonicalDelivery struct {
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Zip     string `json:"zip"`
+	City    string `json:"city"`
+	Address string `json:"address"`
+	Region  string `json:"region"`
+	Email   string `json:"email"`
+}
+
+type canonicalPayment struct {
+	Transaction  string `json:"transaction"`
+	RequestID    string `json:"request_id"`
+	Currency     string `json:"currency"`
+	Provider     string `json:"provider"`
+	Amount       int    `json:"amount"`
+	PaymentDt    int64  `json:"payment_dt"`
+	Bank         string `json:"bank"`
+	DeliveryCost int    `json:"delivery_cost"`
+	GoodsTotal   int    `json:"goods_total"`
+	CustomFee    int    `json:"custom_fee"`
+}
+
+type canonicalItem struct {
+	ChrtID      int    `json:"chrt_id"`
+	TrackNumber string `json:"track_number"`
+	Price       int    `json:"price"`
+	Rid         string `json:"rid"`
+	Name        string `json:"name"`
+	Sale        int    `json:"sale"`
+	Size        string `json:"size"`
+	TotalPrice  int    `json:"total_price"`
+	NmID        int    `json:"nm_id"`
+	Brand       string `json:"brand"`
+	Status      int    `json:"status"`
+}
+
+// Canonical returns the bytes the order signature is computed over.
+func Canonical(order models.Order) ([]byte, error) {
+	d, p := order.Delivery, order.Payment
+	c := canonicalOrder{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: canonicalDelivery{
+			Name: d.Name, Phone: d.Phone, Zip: d.Zip, City: d.City,
+			Address: d.Address, Region: d.Region, Email: d.Email,
+		},
+		Payment: canonicalPayment{
+			Transaction: p.Transaction, RequestID: p.RequestID, Currency: p.Currency, Provider: p.Provider,
+			Amount: p.Amount, PaymentDt: p.PaymentDt, Bank: p.Bank, DeliveryCost: p.DeliveryCost,
+			GoodsTotal: p.GoodsTotal, CustomFee: p.CustomFee,
+		},
+		Locale:          order.Locale,
+		CustomerID:      order.CustomerID,
+		DeliveryService: order.DeliveryService,
+		Shardkey:        order.Shardkey,
+		SmID:            order.SmID,
+		DateCreated:     order.DateCreated.UTC(),
+		OofShard:        order.OofShard,
+	}
+	if order.Items != nil {
+		c.Items = make([]canonicalItem, 0, len(order.Items))
+	}
+	for _, it := range order.Items {
+		c.Items = append(c.Items, canonicalItem{
+			ChrtID: it.ChrtID, TrackNumber: it.TrackNumber, Price: it.Price, Rid: it.Rid, Name: it.Name,
+			Sale: it.Sale, Size: it.Size, TotalPrice: it.TotalPrice, NmID: it.NmID, Brand: it.Brand, Status: it.Status,
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(c); err != nil {
+		return nil, fmt.Errorf("canonical encoding: %w", err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// SignHMAC returns internal_signature of the order for an HMAC-SHA256 secret.
+func SignHMAC(order models.Order, secret []byte) (string, error) {
+	data, err := Canonical(order)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(macSHA256(secret, data)), nil
+}
+
+// SignEd25519 returns internal_signature of the order for an Ed25519 private key.
+func SignEd25519(order models.Order, priv ed25519.PrivateKey) (string, error) {
+	data, err := Canonical(order)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)), nil
+}
+
+func macSHA256(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,200 @@
+package signature
+
+import (
+	"WB/internal/config"
+	"WB/internal/models"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var hmacSecret = []byte(strings.Repeat("s", 32))
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		Entry:       "WBIL",
+		CustomerID:  "test",
+		Delivery:    models.Delivery{Name: "Test <Testov>", City: "Kiryat Mozkin"},
+		Items:       []models.Item{{ChrtID: 9934930, Price: 453}},
+		DateCreated: time.Date(2021, 11, 26, 9, 22, 19, 0, time.FixedZone("MSK", 3*3600)),
+	}
+}
+
+// goldenOrder fills every signed field, so the golden vector covers all of them.
+func goldenOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test <Testov>", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test", RequestID: "r1", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317, CustomFee: 1,
+		},
+		Items: []models.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest", Name: "Mascaras",
+			Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:            "en",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		Shardkey:          "9",
+		SmID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 9, 22, 19, 123456789, time.FixedZone("MSK", 3*3600)),
+		OofShard:          "1",
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	order := testOrder()
+	signed := order
+	signed.InternalSignature = "sig"
+	signed.DateCreated = order.DateCreated.UTC()
+
+	a, err := Canonical(order)
+	require.NoError(t, err)
+	b, err := Canonical(signed)
+	require.NoError(t, err)
+
+	assert.Equal(t, a, b)
+	assert.Contains(t, string(a), `"date_created":"2021-11-26T06:22:19Z"`)
+	assert.Contains(t, string(a), `"name":"Test <Testov>"`)
+	assert.False(t, bytes.HasSuffix(a, []byte("\n")))
+}
+
+func TestCanonical_Golden(t *testing.T) {
+	const want = `{"order_uid":"b563feb7b2b84b6test","track_number":"WBILMTESTTRACK","entry":"WBIL",` +
+		`"delivery":{"name":"Test <Testov>","phone":"+9720000000","zip":"2639809","city":"Kiryat Mozkin",` +
+		`"address":"Ploshad Mira 15","region":"Kraiot","email":"test@gmail.com"},` +
+		`"payment":{"transaction":"b563feb7b2b84b6test","request_id":"r1","currency":"USD","provider":"wbpay",` +
+		`"amount":1817,"payment_dt":1637907727,"bank":"alpha","delivery_cost":1500,"goods_total":317,"custom_fee":1},` +
+		`"items":[{"chrt_id":9934930,"track_number":"WBILMTESTTRACK","price":453,"rid":"ab4219087a764ae0btest",` +
+		`"name":"Mascaras","sale":30,"size":"0","total_price":317,"nm_id":2389212,"brand":"Vivienne Sabo","status":202}],` +
+		`"locale":"en","internal_signature":"","customer_id":"test","delivery_service":"meest","shardkey":"9",` +
+		`"sm_id":99,"date_created":"2021-11-26T06:22:19.123456789Z","oof_shard":"1"}`
+	const wantSig = "C40xLvRc/QdVcSJyZW4NJE3/TvcAVJd+STZS1Nkf1D8="
+
+	order := goldenOrder()
+	// fields outside the signed form don't change it
+	order.Version = 3
+	order.UpdatedAt = time.Date(2021, 11, 27, 0, 0, 0, 0, time.UTC)
+
+	got, err := Canonical(order)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+
+	sig, err := SignHMAC(order, hmacSecret)
+	require.NoError(t, err)
+	assert.Equal(t, wantSig, sig)
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	verifier, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Signing{
+		Unsigned: UnsignedReject,
+		Keys: map[string][]config.SigningKey{
+			"WBIL": {
+				{Algorithm: AlgHMACSHA256, Key: base64.StdEncoding.EncodeToString(hmacSecret)},
+				{Algorithm: AlgEd25519, Key: base64.StdEncoding.EncodeToString(pub)},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	sign := func(order models.Order, f func(models.Order) (string, error)) models.Order {
+		sig, err := f(order)
+		require.NoError(t, err)
+		order.InternalSignature = sig
+		return order
+	}
+	hmacSign := func(o models.Order) (string, error) { return SignHMAC(o, hmacSecret) }
+	edSign := func(o models.Order) (string, error) { return SignEd25519(o, priv) }
+
+	tampered := sign(testOrder(), hmacSign)
+	tampered.Items[0].Price = 1
+
+	otherEntry := testOrder()
+	otherEntry.Entry = "OTHER"
+
+	tests := []struct {
+		name    string
+		order   models.Order
+		wantErr error
+	}{
+		{name: "hmac", order: sign(testOrder(), hmacSign)},
+		{name: "ed25519", order: sign(testOrder(), edSign)},
+		{name: "unsigned", order: testOrder(), wantErr: models.ErrUnsignedOrder},
+		{name: "tampered", order: tampered, wantErr: models.ErrInvalidSignature},
+		{name: "not base64", order: models.Order{Entry: "WBIL", InternalSignature: "%%%"}, wantErr: models.ErrInvalidSignature},
+		{name: "unknown entry", order: sign(otherEntry, hmacSign), wantErr: models.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.Verify(tt.order)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestVerify_UnsignedPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantErr bool
+		wantLog bool
+	}{
+		{policy: "", wantErr: false},
+		{policy: UnsignedAllow, wantErr: false},
+		{policy: UnsignedWarn, wantErr: false, wantLog: true},
+		{policy: UnsignedReject, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			var buf bytes.Buffer
+			verifier, err := New(slog.New(slog.NewTextHandler(&buf, nil)), config.Signing{Unsigned: tt.policy})
+			require.NoError(t, err)
+
+			err = verifier.Verify(testOrder())
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantLog, strings.Contains(buf.String(), "unsigned order accepted"))
+		})
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Signing
+	}{
+		{name: "unknown policy", cfg: config.Signing{Unsigned: "maybe"}},
+		{name: "unknown algorithm", cfg: config.Signing{Keys: map[string][]config.SigningKey{"WBIL": {{Algorithm: "md5", Key: "c2VjcmV0"}}}}},
+		{name: "short hmac secret", cfg: config.Signing{Keys: map[string][]config.SigningKey{"WBIL": {{Algorithm: AlgHMACSHA256, Key: "c2VjcmV0"}}}}},
+		{name: "bad ed25519 key", cfg: config.Signing{Keys: map[string][]config.SigningKey{"WBIL": {{Algorithm: AlgEd25519, Key: "c2VjcmV0"}}}}},
+		{name: "not base64", cfg: config.Signing{Keys: map[string][]config.SigningKey{"WBIL": {{Algorithm: AlgHMACSHA256, Key: "%%%"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Package tlsconfig builds crypto/tls configurations from the service config.
+package tlsconfig
+
+import (
+	"WB/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Client returns TLS configuration for an outgoing connection.
+// It returns nil if TLS is disabled.
+func Client(cfg config.TLS) (*tls.Config, error) {
+	const op = "tlsconfig.Client"
+
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // explicit opt-in for local setups
+	}
+
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: load key pair: %w", op, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// Client certificate policies of a server.
+const (
+	ClientAuthNone             = "none"
+	ClientAuthRequest          = "request"
+	ClientAuthRequire          = "require"
+	ClientAuthVerifyIfGiven    = "verify_if_given"
+	ClientAuthRequireAndVerify = "require_and_verify"
+)
+
+// Server returns TLS configuration for a listener.
+// It returns nil if TLS is disabled. Client certificates are checked against
+// CAFile according to ClientAuth; verifying modes require CAFile.
+func Server(cfg config.TLS) (*tls.Config, error) {
+	const op = "tlsconfig.Server"
+
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("%s: cert_file and key_file are required", op)
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: load key pair: %w", op, err)
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	switch cfg.ClientAuth {
+	case "", ClientAuthNone:
+		tlsCfg.ClientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		tlsCfg.ClientAuth = tls.RequestClientCert
+	case ClientAuthRequire:
+		tlsCfg.ClientAuth = tls.RequireAnyClientCert
+	case ClientAuthVerifyIfGiven:
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequireAndVerify:
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("%s: unknown client_auth %q", op, cfg.ClientAuth)
+	}
+
+	if cfg.CAFile != "" {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		tlsCfg.ClientCAs = pool
+	} else if tlsCfg.ClientAuth >= tls.VerifyClientCertIfGiven {
+		return nil, fmt.Errorf("%s: client_auth %q requires ca_file", op, cfg.ClientAuth)
+	}
+
+	return tlsCfg, nil
+}
+
+// loadCertPool reads PEM encoded CA certificates from path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
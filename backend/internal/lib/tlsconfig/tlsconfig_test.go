@@ -0,0 +1,172 @@
+package tlsconfig
+
+import (
+	"WB/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate and its key to dir.
+func writeSelfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestClient(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t, t.TempDir())
+
+	tests := []struct {
+		name      string
+		cfg       config.TLS
+		wantNil   bool
+		wantErr   bool
+		wantCerts int
+	}{
+		{
+			name:    "disabled",
+			cfg:     config.TLS{},
+			wantNil: true,
+		},
+		{
+			name: "ca only",
+			cfg:  config.TLS{Enabled: true, CAFile: certFile, ServerName: "localhost"},
+		},
+		{
+			name:      "mutual",
+			cfg:       config.TLS{Enabled: true, CAFile: certFile, CertFile: certFile, KeyFile: keyFile},
+			wantCerts: 1,
+		},
+		{
+			name:    "missing ca",
+			cfg:     config.TLS{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "key without cert",
+			cfg:     config.TLS{Enabled: true, KeyFile: keyFile},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Client(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("Client() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got != nil && len(got.Certificates) != tt.wantCerts {
+				t.Errorf("Client() certificates = %d, want %d", len(got.Certificates), tt.wantCerts)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	certFile, keyFile := writeSelfSigned(t, t.TempDir())
+
+	tests := []struct {
+		name           string
+		cfg            config.TLS
+		wantNil        bool
+		wantErr        bool
+		wantClientAuth tls.ClientAuthType
+	}{
+		{
+			name:    "disabled",
+			cfg:     config.TLS{},
+			wantNil: true,
+		},
+		{
+			name:           "server only",
+			cfg:            config.TLS{Enabled: true, CertFile: certFile, KeyFile: keyFile},
+			wantClientAuth: tls.NoClientCert,
+		},
+		{
+			name:           "mutual",
+			cfg:            config.TLS{Enabled: true, CertFile: certFile, KeyFile: keyFile, CAFile: certFile, ClientAuth: "require_and_verify"},
+			wantClientAuth: tls.RequireAndVerifyClientCert,
+		},
+		{
+			name:    "verify without ca",
+			cfg:     config.TLS{Enabled: true, CertFile: certFile, KeyFile: keyFile, ClientAuth: "verify_if_given"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown client auth",
+			cfg:     config.TLS{Enabled: true, CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"},
+			wantErr: true,
+		},
+		{
+			name:    "missing key pair",
+			cfg:     config.TLS{Enabled: true, CAFile: certFile},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Server(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Server() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("Server() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got != nil && got.ClientAuth != tt.wantClientAuth {
+				t.Errorf("Server() client auth = %v, want %v", got.ClientAuth, tt.wantClientAuth)
+			}
+		})
+	}
+}
@@ -20,6 +20,27 @@ func init() {
 
 // ValidateOrder validates the Order model using predefined struct tags.
 // Returns nil if the order is valid, or an error describing validation failures.
+// Once every item is canceled or refunded, the payment amount and goods total
+// are refunded too, so they may be zero.
 func ValidateOrder(order *models.Order) error {
+    if closed(order) {
+        return validate.StructExcept(order, "Payment.Amount", "Payment.GoodsTotal")
+    }
     return validate.Struct(order)
-}
\ No newline at end of file
+}
+
+// closed reports whether the order has items and all of them are canceled or refunded.
+func closed(order *models.Order) bool {
+    for _, it := range order.Items {
+        if it.Status != models.ItemStatusCanceled && it.Status != models.ItemStatusRefunded {
+            return false
+        }
+    }
+    return len(order.Items) > 0
+}
+
+// ValidateOrderFields validates only the listed fields of the order, named
+// relative to it, e.g. "Delivery.City" or "Items[0].Status".
+func ValidateOrderFields(order *models.Order, fields ...string) error {
+    return validate.StructPartial(order, fields...)
+}
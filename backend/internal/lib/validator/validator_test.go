@@ -114,6 +114,21 @@ var incorrectOrder = &models.Order{
 	},
 }
 
+// canceledOrder is correctOrder with every item canceled and the payment refunded.
+var canceledOrder = func() *models.Order {
+	order := *correctOrder
+	order.Items = []models.Item{correctOrder.Items[0]}
+	order.Items[0].Status = models.ItemStatusCanceled
+	order.Payment.Amount, order.Payment.GoodsTotal, order.Payment.DeliveryCost = 0, 0, 0
+	return &order
+}()
+
+// zeroAmountOrder is correctOrder with open items and no payment.
+var zeroAmountOrder = func() *models.Order {
+	order := *correctOrder
+	order.Payment.Amount = 0
+	return &order
+}()
 
 func TestValidateOrder(t *testing.T) {
 	type args struct {
@@ -134,6 +149,16 @@ func TestValidateOrder(t *testing.T) {
 			args: args{order: incorrectOrder},
 			wantErr: true,
 		},
+		{
+			name: "canceled order with refunded payment",
+			args: args{order: canceledOrder},
+			wantErr: false,
+		},
+		{
+			name: "open order without payment",
+			args: args{order: zeroAmountOrder},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -143,3 +168,18 @@ func TestValidateOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateOrderFields(t *testing.T) {
+	erased := *correctOrder
+	erased.Delivery = models.Delivery{City: "Moscow"}
+
+	if err := ValidateOrderFields(&erased, "Delivery.City", "Items[0].Status"); err != nil {
+		t.Errorf("ValidateOrderFields() of valid fields error = %v", err)
+	}
+	if err := ValidateOrderFields(&erased, "Delivery.Name"); err == nil {
+		t.Error("ValidateOrderFields() of an empty name succeeded")
+	}
+	if err := ValidateOrderFields(incorrectOrder, "Delivery.Email"); err == nil {
+		t.Error("ValidateOrderFields() of an incorrect email succeeded")
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// AuditRecord is an entry of the tamper-evident audit log.
+// Hash covers every other field and PrevHash links the record to its predecessor.
+type AuditRecord struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Resource  string    `json:"resource"`
+	OrderUID  string    `json:"order_uid,omitempty"`
+	Details   string    `json:"details"`
+	// Diff maps changed field paths to their values before and after the change.
+	Diff     string `json:"diff,omitempty"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// AuditFilter selects audit records. Empty fields match any record.
+// Records are returned by ascending ID, starting after AfterID.
+type AuditFilter struct {
+	OrderUID string
+	Actor    string
+	AfterID  int64
+	Limit    int
+}
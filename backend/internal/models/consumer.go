@@ -0,0 +1,21 @@
+package models
+
+// ConsumerStatus is the state of the order consumer and the progress of its group.
+type ConsumerStatus struct {
+	Group      string           `json:"group"`
+	Topic      string           `json:"topic"`
+	Paused     bool             `json:"paused"`
+	Partitions []PartitionState `json:"partitions,omitempty"`
+}
+
+// PartitionState is the progress of the consumer group on a topic partition.
+// Committed is the next offset the group reads, or -1 before the first commit,
+// when Lag is the high watermark. Member and Host identify the assigned group member.
+type PartitionState struct {
+	Partition     int    `json:"partition"`
+	Member        string `json:"member,omitempty"`
+	Host          string `json:"host,omitempty"`
+	Committed     int64  `json:"committed"`
+	HighWatermark int64  `json:"high_watermark"`
+	Lag           int64  `json:"lag"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "errors"
+
+var (
+	// ErrInvalidOrder is returned when an order fails validation.
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrOrderNotFound is returned when an order with the requested UID does not exist.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrForbidden is returned when the caller is not allowed to access the order.
+	ErrForbidden = errors.New("access denied")
+	// ErrUnsignedOrder is returned when an order without internal_signature is rejected.
+	ErrUnsignedOrder = errors.New("order is not signed")
+	// ErrInvalidSignature is returned when internal_signature of an order can't be verified.
+	ErrInvalidSignature = errors.New("invalid order signature")
+	// ErrVersionConflict is returned when an order was changed since the version the update is based on.
+	ErrVersionConflict = errors.New("order version conflict")
+	// ErrOrderState is returned when the items of an order can't be canceled or refunded in their state.
+	ErrOrderState = errors.New("operation is not allowed in the order state")
+	// ErrUnknownSchema is returned when a message has a schema version, type or content type that can't be decoded.
+	ErrUnknownSchema = errors.New("unknown message schema")
+	// ErrConsumerRunning is returned when offsets are reset while a consumer of the group is running.
+	ErrConsumerRunning = errors.New("consumer is running")
+	// ErrStaleCache is returned with a stored change when the changed order couldn't be evicted from the cache.
+	ErrStaleCache = errors.New("changed order is not evicted from the cache")
+	// ErrAuditUnavailable is returned when an order is stored but its audit record can't be written.
+	ErrAuditUnavailable = errors.New("audit log is unavailable")
+)
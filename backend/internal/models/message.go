@@ -0,0 +1,9 @@
+package models
+
+// OrderSchemaVersion is the schema version of the orders written to Kafka.
+// Bump it when the wire form of Order changes incompatibly and register an
+// upcaster from the previous version.
+const OrderSchemaVersion = 1
+
+// EventOrderCreated is the type of the Kafka messages that carry new orders.
+const EventOrderCreated = "order.created"
@@ -17,20 +17,35 @@ type Order struct {
     SmID              int       `json:"sm_id" validate:"required,gte=0"`
     DateCreated       time.Time `json:"date_created" validate:"required"`
     OofShard          string    `json:"oof_shard" validate:"required"`
+    // Version is incremented on every update of a stored order.
+    Version           int       `json:"version,omitempty"`
+    // UpdatedAt is the time of the last update, zero if the order was never changed.
+    UpdatedAt         time.Time `json:"updated_at,omitzero"`
 }
 
+// Delivery holds the recipient details. Personal data fields are tagged with
+// mask, so they are hidden from callers without access (see package masking).
 type Delivery struct {
-    Name   string `json:"name" validate:"required"`
-    Phone  string `json:"phone" validate:"required"`
+    Name   string `json:"name" validate:"required" mask:"partial"`
+    Phone  string `json:"phone" validate:"required" mask:"partial"`
     Zip    string `json:"zip" validate:"required"`
     City   string `json:"city" validate:"required"`
-    Address string `json:"address" validate:"required"`
+    Address string `json:"address" validate:"required" mask:"partial"`
     Region  string `json:"region" validate:"required"`
-    Email   string `json:"email" validate:"email"`
+    Email   string `json:"email" validate:"email" mask:"email"`
+}
+
+// DeliveryRecord is a delivery row together with the order it belongs to.
+// Version and UpdatedAt are those of the order when the row was read.
+type DeliveryRecord struct {
+    OrderUID  string
+    Delivery  Delivery
+    Version   int
+    UpdatedAt time.Time
 }
 
 type Payment struct {
-    Transaction   string `json:"transaction" validate:"required"`
+    Transaction   string `json:"transaction" validate:"required" mask:"partial"`
     RequestID     string `json:"request_id" validate:"omitempty"`
     Currency      string `json:"currency" validate:"required,len=3"`
     Provider      string `json:"provider" validate:"required"`
@@ -54,4 +69,20 @@ type Item struct {
     NmID        int    `json:"nm_id" validate:"required,gt=0"`
     Brand       string `json:"brand" validate:"required"`
     Status      int    `json:"status" validate:"gte=0"`
-}
\ No newline at end of file
+}
+
+// CustomerExport is everything stored about a customer, returned on a data subject request.
+type CustomerExport struct {
+    CustomerID string    `json:"customer_id"`
+    ExportedAt time.Time `json:"exported_at"`
+    Orders     []Order   `json:"orders"`
+    // Refunds are the cancellations and refunds of the orders, with their reasons.
+    Refunds    []Refund  `json:"refunds"`
+}
+
+// ErasureResult describes the outcome of a customer data erasure request.
+type ErasureResult struct {
+    CustomerID string   `json:"customer_id"`
+    Mode       string   `json:"mode"`
+    OrderUIDs  []string `json:"order_uids"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// InitialVersion is the version of a newly stored order.
+const InitialVersion = 1
+
+// OrderPatch is a partial update of an order. Nil fields are left unchanged.
+type OrderPatch struct {
+	Delivery *DeliveryPatch
+	Items    []ItemStatus
+}
+
+// DeliveryPatch holds the changed delivery details.
+type DeliveryPatch struct {
+	Name    *string
+	Phone   *string
+	Zip     *string
+	City    *string
+	Address *string
+	Region  *string
+	Email   *string
+}
+
+// ItemStatus sets the status of the order items with ChrtID.
+type ItemStatus struct {
+	ChrtID int
+	Status int
+}
+
+// LastModified returns the time of the last change of the order:
+// its last update or, if it was never updated, its creation.
+func (o Order) LastModified() time.Time {
+	if o.UpdatedAt.IsZero() {
+		return o.DateCreated
+	}
+	return o.UpdatedAt
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Item statuses the cancellation workflow relies on. Other statuses are opaque.
+const (
+	// ItemStatusDelivered marks an item handed over to the customer. It can be
+	// refunded but no longer canceled.
+	ItemStatusDelivered = 300
+	// ItemStatusCanceled marks an item canceled before delivery.
+	ItemStatusCanceled = 400
+	// ItemStatusRefunded marks a delivered item whose price was refunded.
+	ItemStatusRefunded = 410
+)
+
+// Refund kinds.
+const (
+	// RefundKindCancel returns the money of items canceled before delivery.
+	RefundKindCancel = "cancel"
+	// RefundKindReturn returns the money of delivered items.
+	RefundKindReturn = "return"
+)
+
+// Order event types.
+const (
+	EventOrderCanceled = "order.canceled"
+	EventItemsCanceled = "order.items_canceled"
+	EventOrderRefunded = "order.refunded"
+)
+
+// Refund is money returned for items of an order, recorded against the order payment.
+type Refund struct {
+	ID          int64     `json:"id"`
+	OrderUID    string    `json:"order_uid"`
+	Transaction string    `json:"transaction"`
+	Kind        string    `json:"kind"`
+	ChrtIDs     []int     `json:"chrt_ids"`
+	Amount      int       `json:"amount"`
+	Currency    string    `json:"currency"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CancelRequest selects the items to cancel or refund. No ChrtIDs select the whole order.
+type CancelRequest struct {
+	ChrtIDs []int
+	Reason  string
+}
+
+// OrderEvent is published to the order events topic when an order is canceled or refunded.
+type OrderEvent struct {
+	Type     string    `json:"type"`
+	OrderUID string    `json:"order_uid"`
+	Version  int       `json:"version"`
+	Time     time.Time `json:"time"`
+	Refund   Refund    `json:"refund"`
+}
+
+// OutboxEvent is an order event stored together with the change it describes,
+// waiting to be published to the order events topic with Key.
+type OutboxEvent struct {
+	ID      int64
+	Key     string
+	Payload []byte
+}
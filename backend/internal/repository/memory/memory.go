@@ -0,0 +1,112 @@
+// Package memory provides an in-process order cache.
+// It is meant to sit in front of Redis with a short TTL and is kept
+// consistent across replicas by the Redis invalidation channel.
+package memory
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache is a size-bounded LRU cache of serialized orders.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+type entry struct {
+	orderUID  string
+	data      []byte
+	expiresAt time.Time
+}
+
+// New creates a cache holding at most size orders, each for at most ttl.
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// GetOrder returns the cached order or nil if it is absent or expired.
+func (c *Cache) GetOrder(_ context.Context, orderUID string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[orderUID]
+	if !ok {
+		return nil, nil
+	}
+
+	e := el.Value.(*entry)
+	if c.now().After(e.expiresAt) {
+		c.remove(el)
+		return nil, nil
+	}
+
+	c.lru.MoveToFront(el)
+	return e.data, nil
+}
+
+// SetOrder stores the order for the smaller of ttl and the cache TTL.
+// The least recently used order is evicted when the cache is full.
+func (c *Cache) SetOrder(_ context.Context, orderUID string, data []byte, ttl time.Duration) error {
+	if ttl <= 0 || ttl > c.ttl {
+		ttl = c.ttl
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[orderUID]; ok {
+		e := el.Value.(*entry)
+		e.data = data
+		e.expiresAt = c.now().Add(ttl)
+		c.lru.MoveToFront(el)
+		return nil
+	}
+
+	c.entries[orderUID] = c.lru.PushFront(&entry{
+		orderUID:  orderUID,
+		data:      data,
+		expiresAt: c.now().Add(ttl),
+	})
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+
+	return nil
+}
+
+// DeleteOrder evicts the order from the cache.
+func (c *Cache) DeleteOrder(_ context.Context, orderUID string) error {
+	c.Evict(orderUID)
+	return nil
+}
+
+// Evict removes the order from the cache.
+// It is used as the handler of cross-instance invalidation messages.
+func (c *Cache) Evict(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[orderUID]; ok {
+		c.remove(el)
+	}
+}
+
+// remove deletes the list element and its index entry. Must hold c.mu.
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*entry).orderUID)
+}
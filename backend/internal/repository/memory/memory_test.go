@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	c := New(10, time.Minute)
+
+	require.NoError(t, c.SetOrder(ctx, "order", []byte(`{}`), time.Hour))
+
+	got, err := c.GetOrder(ctx, "order")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{}`), got)
+
+	require.NoError(t, c.DeleteOrder(ctx, "order"))
+
+	got, err = c.GetOrder(ctx, "order")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestCache_Expiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := New(10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.SetOrder(ctx, "order", []byte(`{}`), time.Hour))
+
+	now = now.Add(59 * time.Second)
+	got, _ := c.GetOrder(ctx, "order")
+	assert.NotNil(t, got)
+
+	now = now.Add(2 * time.Second)
+	got, _ = c.GetOrder(ctx, "order")
+	assert.Nil(t, got)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := New(2, time.Minute)
+
+	require.NoError(t, c.SetOrder(ctx, "a", []byte("a"), 0))
+	require.NoError(t, c.SetOrder(ctx, "b", []byte("b"), 0))
+	_, _ = c.GetOrder(ctx, "a")
+	require.NoError(t, c.SetOrder(ctx, "c", []byte("c"), 0))
+
+	a, _ := c.GetOrder(ctx, "a")
+	b, _ := c.GetOrder(ctx, "b")
+	cc, _ := c.GetOrder(ctx, "c")
+	assert.NotNil(t, a)
+	assert.Nil(t, b)
+	assert.NotNil(t, cc)
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"WB/internal/lib/audit"
+	"WB/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// auditLockID is the advisory lock that serializes appends to the audit chain.
+const auditLockID = 7_246_001
+
+// AppendAudit links the records to the last one in the audit log and stores
+// them in one transaction. Appends are serialized, so concurrent writers can't
+// fork the chain. The stored records are returned with their IDs and hashes.
+func (s *Storage) AppendAudit(ctx context.Context, recs ...models.AuditRecord) ([]models.AuditRecord, error) {
+	const op = "storage.postgres.AppendAudit"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockID); err != nil {
+		return nil, fmt.Errorf("%s: lock: %w", op, err)
+	}
+
+	var prevHash string
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: get last record: %w", op, err)
+	}
+
+	stored := make([]models.AuditRecord, 0, len(recs))
+	for _, rec := range recs {
+		audit.Seal(prevHash, &rec)
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO audit_log (created_at, request_id, actor, action, resource, order_uid, details, diff, prev_hash, hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id`,
+			rec.Time, rec.RequestID, rec.Actor, rec.Action, rec.Resource, rec.OrderUID,
+			rec.Details, rec.Diff, rec.PrevHash, rec.Hash,
+		).Scan(&rec.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: insert record: %w", op, err)
+		}
+
+		stored = append(stored, rec)
+		prevHash = rec.Hash
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return stored, nil
+}
+
+// ListAudit returns audit records matching the filter in ascending ID order.
+func (s *Storage) ListAudit(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	const op = "storage.postgres.ListAudit"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, created_at, request_id, actor, action, resource, order_uid, details, diff, prev_hash, hash
+		FROM audit_log
+		WHERE id > $1
+			AND ($2 = '' OR order_uid = $2)
+			AND ($3 = '' OR actor = $3)
+		ORDER BY id
+		LIMIT $4`,
+		filter.AfterID, filter.OrderUID, filter.Actor, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	records := []models.AuditRecord{}
+	for rows.Next() {
+		var r models.AuditRecord
+		if err := rows.Scan(&r.ID, &r.Time, &r.RequestID, &r.Actor, &r.Action, &r.Resource,
+			&r.OrderUID, &r.Details, &r.Diff, &r.PrevHash, &r.Hash); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return records, nil
+}
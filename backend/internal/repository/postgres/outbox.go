@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"WB/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// insertOutbox stores the event in the outbox within the transaction of the change it describes.
+func insertOutbox(tx *sql.Tx, key string, event models.OrderEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO outbox (key, payload) VALUES ($1, $2)`, key, payload); err != nil {
+		return fmt.Errorf("insert outbox: %w", err)
+	}
+
+	return nil
+}
+
+// PendingEvents returns at most limit unpublished events of the outbox in the order they were stored.
+func (s *Storage) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	const op = "storage.postgres.PendingEvents"
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, key, payload
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Key, &e.Payload); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// MarkEventPublished removes the event from the pending events of the outbox.
+func (s *Storage) MarkEventPublished(ctx context.Context, id int64) error {
+	const op = "storage.postgres.MarkEventPublished"
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE outbox SET published_at = now() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -6,11 +6,13 @@ import (
 	"WB/internal/models"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	_ "github.com/jackc/pgx/v5/stdlib" //import pgx driver
 	"github.com/pressly/goose/v3"
 )
@@ -94,12 +96,12 @@ func (s *Storage) NewOrder(order models.Order) error {
 
 	// 3. Orders
 	_, err = tx.Exec(`
-        INSERT INTO orders (order_uid, track_number, entry, delivery_uid, payment_transaction, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        INSERT INTO orders (order_uid, track_number, entry, delivery_uid, payment_transaction, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
         ON CONFLICT (order_uid) DO NOTHING`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.OrderUID, order.Payment.Transaction,
 		order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.Shardkey, order.SmID, order.DateCreated, order.OofShard)
+		order.Shardkey, order.SmID, order.DateCreated, order.OofShard, max(order.Version, models.InitialVersion))
 	if err != nil {
 		return fmt.Errorf("%s: insert orders: %w", op, err)
 	}
@@ -131,16 +133,21 @@ func (s *Storage) GetOrder(orderID string) (models.Order, error) {
 
 	// 1. Orders
 	var order models.Order
+	var updatedAt sql.NullTime
 	err := s.db.QueryRow(`
 		SELECT order_uid, track_number, entry, payment_transaction, locale, internal_signature, customer_id, 
-				delivery_service, shardkey, sm_id, date_created, oof_shard
+				delivery_service, shardkey, sm_id, date_created, oof_shard, version, updated_at
 		FROM orders WHERE order_uid = $1`, orderID).Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Payment.Transaction, &order.Locale,
 		&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
-		&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard)
+		&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Version, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Order{}, fmt.Errorf("%s: get orders: %w", op, models.ErrOrderNotFound)
+	}
 	if err != nil {
 		return models.Order{}, fmt.Errorf("%s: get orders: %w", op, err)
 	}
+	order.UpdatedAt = updatedAt.Time
 
 	// 2. Delivery
 	err = s.db.QueryRow(`
@@ -189,6 +196,276 @@ func (s *Storage) GetOrder(orderID string) (models.Order, error) {
 	return order, nil
 }
 
+// UpdateOrder stores the delivery details and the item statuses of the order
+// if its stored version is still version, and increments the version.
+// It returns models.ErrVersionConflict if the order was changed in the meantime.
+func (s *Storage) UpdateOrder(order models.Order, version int) error {
+	const op = "storage.postgres.UpdateOrder"
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// 1. Version check
+	if err := bumpVersion(tx, order, version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// 2. Delivery
+	_, err = tx.Exec(`
+		UPDATE delivery SET name = $2, phone = $3, zip = $4, city = $5, address = $6, region = $7, email = $8
+		WHERE order_uid = $1`,
+		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
+		order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
+	if err != nil {
+		return fmt.Errorf("%s: update delivery: %w", op, err)
+	}
+
+	// 3. Items
+	if err := updateItemStatuses(tx, order); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveRefund stores the item statuses and the payment totals of the canceled
+// or refunded order together with the refund record and, in the outbox, the
+// event with the ID of the refund, if the stored version of the order is still
+// version, and increments the version. It returns the ID of the refund or
+// models.ErrVersionConflict if the order was changed in the meantime.
+func (s *Storage) SaveRefund(order models.Order, version int, refund models.Refund, event models.OrderEvent) (int64, error) {
+	const op = "storage.postgres.SaveRefund"
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// 1. Version check
+	if err := bumpVersion(tx, order, version); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// 2. Items
+	if err := updateItemStatuses(tx, order); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// 3. Payment
+	_, err = tx.Exec(`
+		UPDATE payment SET amount = $2, delivery_cost = $3, goods_total = $4, custom_fee = $5
+		WHERE transaction = $1`,
+		order.Payment.Transaction, order.Payment.Amount, order.Payment.DeliveryCost,
+		order.Payment.GoodsTotal, order.Payment.CustomFee)
+	if err != nil {
+		return 0, fmt.Errorf("%s: update payment: %w", op, err)
+	}
+
+	// 4. Refund
+	var id int64
+	err = tx.QueryRow(`
+		INSERT INTO refunds (transaction, order_uid, kind, chrt_ids, amount, currency, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		refund.Transaction, refund.OrderUID, refund.Kind, refund.ChrtIDs, refund.Amount,
+		refund.Currency, refund.Reason, refund.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: insert refund: %w", op, err)
+	}
+
+	// 5. Event
+	event.Refund.ID = id
+	if err := insertOutbox(tx, order.OrderUID, event); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// bumpVersion increments the version of the order if it is still version
+// and stores its UpdatedAt.
+func bumpVersion(tx *sql.Tx, order models.Order, version int) error {
+	orderUID := order.OrderUID
+	updatedAt := sql.NullTime{Time: order.UpdatedAt, Valid: !order.UpdatedAt.IsZero()}
+	res, err := tx.Exec(`
+		UPDATE orders SET version = version + 1, updated_at = $3
+		WHERE order_uid = $1 AND version = $2`, orderUID, version, updatedAt)
+	if err != nil {
+		return fmt.Errorf("update orders: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update orders: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, orderUID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check order: %w", err)
+	}
+	if !exists {
+		return models.ErrOrderNotFound
+	}
+	return fmt.Errorf("version %d: %w", version, models.ErrVersionConflict)
+}
+
+// updateItemStatuses stores the statuses of the order items.
+func updateItemStatuses(tx *sql.Tx, order models.Order) error {
+	for _, item := range order.Items {
+		_, err := tx.Exec(`
+			UPDATE items SET status = $3
+			WHERE order_uid = $1 AND chrt_id = $2`,
+			order.OrderUID, item.ChrtID, item.Status)
+		if err != nil {
+			return fmt.Errorf("update item %v: %w", item.ChrtID, err)
+		}
+	}
+	return nil
+}
+
+// ListDeliveries returns up to limit delivery records with order_uid greater
+// than afterUID, ordered by order_uid. It is used to walk the table in batches.
+func (s *Storage) ListDeliveries(afterUID string, limit int) ([]models.DeliveryRecord, error) {
+	const op = "storage.postgres.ListDeliveries"
+
+	rows, err := s.db.Query(`
+		SELECT d.order_uid, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, o.version, o.updated_at
+		FROM delivery d JOIN orders o ON o.order_uid = d.order_uid
+		WHERE d.order_uid > $1
+		ORDER BY d.order_uid
+		LIMIT $2`, afterUID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var records []models.DeliveryRecord
+	for rows.Next() {
+		var r models.DeliveryRecord
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&r.OrderUID, &r.Delivery.Name, &r.Delivery.Phone, &r.Delivery.Zip,
+			&r.Delivery.City, &r.Delivery.Address, &r.Delivery.Region, &r.Delivery.Email,
+			&r.Version, &updatedAt); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		r.UpdatedAt = updatedAt.Time
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return records, nil
+}
+
+// UpdateDeliveryPII stores the personal data fields of the order delivery
+// if its stored version is still version, and increments the version.
+// It returns models.ErrVersionConflict if the order was changed in the meantime.
+func (s *Storage) UpdateDeliveryPII(order models.Order, version int) error {
+	const op = "storage.postgres.UpdateDeliveryPII"
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// 1. Version check
+	if err := bumpVersion(tx, order, version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// 2. Delivery
+	_, err = tx.Exec(`
+		UPDATE delivery SET name = $2, phone = $3, address = $4, email = $5
+		WHERE order_uid = $1`,
+		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Address, order.Delivery.Email)
+	if err != nil {
+		return fmt.Errorf("%s: update delivery: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit transaction: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListCustomerOrders returns the UIDs of all orders of the customer.
+func (s *Storage) ListCustomerOrders(customerID string) ([]string, error) {
+	const op = "storage.postgres.ListCustomerOrders"
+
+	rows, err := s.db.Query(`
+		SELECT order_uid FROM orders
+		WHERE customer_id = $1
+		ORDER BY date_created, order_uid`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		uids = append(uids, uid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return uids, nil
+}
+
+// ListCustomerRefunds returns the cancellations and refunds of all orders of the customer.
+func (s *Storage) ListCustomerRefunds(customerID string) ([]models.Refund, error) {
+	const op = "storage.postgres.ListCustomerRefunds"
+
+	rows, err := s.db.Query(`
+		SELECT r.id, r.order_uid, r.transaction, r.kind, r.chrt_ids, r.amount, r.currency, r.reason, r.created_at
+		FROM refunds r JOIN orders o ON o.order_uid = r.order_uid
+		WHERE o.customer_id = $1
+		ORDER BY r.id`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	// the chrt_ids array is decoded by pgx, database/sql can't scan it
+	types := pgtype.NewMap()
+	refunds := []models.Refund{}
+	for rows.Next() {
+		var r models.Refund
+		if err := rows.Scan(&r.ID, &r.OrderUID, &r.Transaction, &r.Kind, types.SQLScanner(&r.ChrtIDs),
+			&r.Amount, &r.Currency, &r.Reason, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		refunds = append(refunds, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return refunds, nil
+}
+
 // Close closes the underlying database connection.
 // Should be called on application shutdown.
 func (s *Storage) Close() error {
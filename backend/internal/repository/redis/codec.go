@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported payload compression algorithms.
+const (
+	CompressionNone   = "none"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+)
+
+// Compressed payloads start with a marker byte naming the algorithm.
+// Plain JSON never starts with these bytes, so uncompressed entries
+// written before compression was enabled are still readable.
+const (
+	markerZstd   byte = 0x01
+	markerSnappy byte = 0x02
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// codec compresses cached payloads with the configured algorithm.
+type codec struct {
+	compression string
+}
+
+// newCodec returns a codec for the named compression algorithm.
+func newCodec(compression string) (codec, error) {
+	switch compression {
+	case "", CompressionNone:
+		return codec{compression: CompressionNone}, nil
+	case CompressionZstd, CompressionSnappy:
+		return codec{compression: compression}, nil
+	default:
+		return codec{}, fmt.Errorf("unknown compression %q", compression)
+	}
+}
+
+// encode compresses data and prefixes it with the algorithm marker.
+func (c codec) encode(data []byte) []byte {
+	switch c.compression {
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, []byte{markerZstd})
+	case CompressionSnappy:
+		return append([]byte{markerSnappy}, snappy.Encode(nil, data)...)
+	default:
+		return data
+	}
+}
+
+// decode detects the algorithm by the marker byte and decompresses data.
+// Data without a marker is returned as is.
+func (c codec) decode(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	switch data[0] {
+	case markerZstd:
+		return zstdDecoder.DecodeAll(data[1:], nil)
+	case markerSnappy:
+		return snappy.Decode(nil, data[1:])
+	default:
+		return data, nil
+	}
+}
@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	data := []byte(`{"order_uid":"b563feb7b2b84b6test","track_number":"WBILMTESTTRACK","entry":"WBIL"}`)
+
+	tests := []struct {
+		name        string
+		compression string
+	}{
+		{name: "none", compression: CompressionNone},
+		{name: "zstd", compression: CompressionZstd},
+		{name: "snappy", compression: CompressionSnappy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newCodec(tt.compression)
+			if err != nil {
+				t.Fatalf("newCodec() error = %v", err)
+			}
+
+			got, err := c.decode(c.encode(data))
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("decode(encode()) = %s, want %s", got, data)
+			}
+		})
+	}
+}
+
+func TestCodec_DecodesUncompressedWithAnyCompression(t *testing.T) {
+	data := []byte(`{"order_uid":"legacy"}`)
+
+	c, err := newCodec(CompressionZstd)
+	if err != nil {
+		t.Fatalf("newCodec() error = %v", err)
+	}
+
+	got, err := c.decode(data)
+	if err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("decode() = %s, want %s", got, data)
+	}
+}
+
+func TestNewCodec_Unknown(t *testing.T) {
+	if _, err := newCodec("lzma"); err == nil {
+		t.Error("newCodec() error = nil, want error")
+	}
+}
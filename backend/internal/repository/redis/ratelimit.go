@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"WB/internal/lib/ratelimit"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket atomically refills the bucket by the time elapsed since the
+// last request and takes a token from it. It uses the server clock, so all
+// replicas share the same notion of time.
+//
+// Returns {allowed, remaining, retry_after_ms, reset_after_ms}.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate * 1000)
+end
+
+local reset = math.ceil((burst - tokens) / rate * 1000)
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.max(reset, 1))
+
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// Allow takes a token from the distributed bucket identified by key.
+func (r *Redis) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	const op = "storage.redis.Allow"
+
+	vals, err := tokenBucket.Run(ctx, r.Client, []string{"ratelimit:{" + key + "}"}, limit.Rate, limit.Burst).Int64Slice()
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: token bucket failed: %w", op, err)
+	}
+	if len(vals) != 4 {
+		return ratelimit.Result{}, fmt.Errorf("%s: unexpected script result %v", op, vals)
+	}
+
+	return ratelimit.Result{
+		Allowed:    vals[0] == 1,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		ResetAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
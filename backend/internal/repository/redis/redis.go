@@ -3,6 +3,9 @@
 package redis
 
 import (
+	"WB/internal/config"
+	"WB/internal/lib/logger/sl"
+	"WB/internal/lib/tlsconfig"
 	"context"
 	"errors"
 	"fmt"
@@ -13,77 +16,270 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// Supported Redis topologies.
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+)
+
 // Redis represents Redis repository for orders.
+// It works on top of a single node, a Sentinel-managed master or a Cluster.
 type Redis struct {
-	Client *redis.Client
+	Client redis.UniversalClient
+
+	keyPrefix  string
+	channel    string
+	staleRatio float64
+	codec      codec
 }
 
 // MustLoad initializes Redis storage with database connection.
-func MustLoad(log *slog.Logger, host, port, password string, DB int) *Redis {
+// If the connection or the cache policy is invalid, os.exit is executed
+func MustLoad(log *slog.Logger, cfg config.Redis, cache config.Cache) *Redis {
 	const op = "storage.redis.MustLoad"
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", host, port),
-		Password: password,
-		DB:       DB,
-	})
+	opts, err := universalOptions(cfg)
+	if err != nil {
+		log.Error("invalid redis config", slog.String("op", op), sl.Err(err))
+		os.Exit(1)
+	}
+
+	client := redis.NewUniversalClient(opts)
 
 	if err := client.Ping(context.Background()).Err(); err != nil {
 		client.Close()
-		log.Error("%s: apply migrations: %v", op, err)
+		log.Error("failed to ping redis", slog.String("op", op), sl.Err(err))
+		os.Exit(1)
+	}
+
+	r, err := New(client, cache)
+	if err != nil {
+		client.Close()
+		log.Error("invalid cache config", slog.String("op", op), sl.Err(err))
 		os.Exit(1)
 	}
 
-	return &Redis{Client: client}
+	return r
+}
+
+// universalOptions translates the config into go-redis options for the selected mode.
+func universalOptions(cfg config.Redis) (*redis.UniversalOptions, error) {
+	tlsCfg, err := tlsconfig.Client(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+		DB:        cfg.DB,
+		TLSConfig: tlsCfg,
+	}
+
+	switch cfg.Mode {
+	case "", ModeSingle:
+		opts.Addrs = []string{fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)}
+	case ModeSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("sentinel mode requires master_name and addrs")
+		}
+		opts.Addrs = cfg.Addrs
+		opts.MasterName = cfg.MasterName
+		opts.SentinelUsername = cfg.SentinelUsername
+		opts.SentinelPassword = cfg.SentinelPassword
+	case ModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("cluster mode requires addrs")
+		}
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("cluster mode supports only db 0")
+		}
+		opts.Addrs = cfg.Addrs
+		opts.IsClusterMode = true
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+
+	return opts, nil
+}
+
+// New creates Redis repository on top of an existing client using the cache policy.
+func New(client redis.UniversalClient, cache config.Cache) (*Redis, error) {
+	const op = "storage.redis.New"
+
+	c, err := newCodec(cache.Compression)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if cache.StaleRatio <= 0 || cache.StaleRatio > 1 {
+		return nil, fmt.Errorf("%s: stale ratio must be in (0, 1], got %v", op, cache.StaleRatio)
+	}
+
+	return &Redis{
+		Client:     client,
+		keyPrefix:  cache.Namespace(),
+		channel:    cache.InvalidationChannel(),
+		staleRatio: cache.StaleRatio,
+		codec:      c,
+	}, nil
 }
 
 // GetOrder retrieves an order by its orderUID from the database.
 func (r *Redis) GetOrder(ctx context.Context, orderUID string) ([]byte, error) {
-	const op = "storage.redis.GetOrder"
+	data, _, err := r.GetOrderState(ctx, orderUID)
+	return data, err
+}
+
+// GetOrderState retrieves an order by its orderUID together with its freshness.
+// stale is true once the soft TTL has passed: the data is still served,
+// but the caller is expected to refresh it in the background.
+func (r *Redis) GetOrderState(ctx context.Context, orderUID string) ([]byte, bool, error) {
+	const op = "storage.redis.GetOrderState"
 
-	key := orderUID
+	key := r.key(orderUID)
+
+	pipe := r.Client.Pipeline()
+	get := pipe.Get(ctx, key)
+	fresh := pipe.Exists(ctx, freshKey(key))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, fmt.Errorf("%s: get failed: %w", op, err)
+	}
 
-	data, err := r.Client.Get(ctx, key).Bytes()
+	data, err := get.Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return nil, nil
+			return nil, false, nil
 		}
-		return nil, fmt.Errorf("%s: get failed: %w", op, err)
+		return nil, false, fmt.Errorf("%s: get failed: %w", op, err)
 	}
 
-	return data, nil
+	data, err = r.codec.decode(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: decode failed: %w", op, err)
+	}
+
+	return data, fresh.Val() == 0, nil
 }
 
 // SetOrder adds a new order to the Redis or returns an error.
+// The order expires after ttl and is reported as stale after its soft TTL.
 func (r *Redis) SetOrder(ctx context.Context, orderUID string, data []byte, ttl time.Duration) error {
 	const op = "storage.redis.SetOrder"
 
-	key := orderUID
+	key := r.key(orderUID)
+
+	pipe := r.Client.TxPipeline()
+	pipe.Set(ctx, key, r.codec.encode(data), ttl)
+	pipe.Set(ctx, freshKey(key), 1, r.softTTL(ttl))
+	pipe.Del(ctx, missingKey(key))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("%s: set failed: %w", op, err)
+	}
+
+	return nil
+}
+
+// IsOrderMissing reports whether the orderUID was recently looked up and not found.
+func (r *Redis) IsOrderMissing(ctx context.Context, orderUID string) (bool, error) {
+	const op = "storage.redis.IsOrderMissing"
 
-	err := r.Client.Set(ctx, key, data, ttl).Err()
+	n, err := r.Client.Exists(ctx, missingKey(r.key(orderUID))).Result()
 	if err != nil {
+		return false, fmt.Errorf("%s: exists failed: %w", op, err)
+	}
+
+	return n > 0, nil
+}
+
+// SetOrderMissing remembers for ttl that the orderUID does not exist.
+// The entry is cleared as soon as the order itself is cached.
+func (r *Redis) SetOrderMissing(ctx context.Context, orderUID string, ttl time.Duration) error {
+	const op = "storage.redis.SetOrderMissing"
+
+	if err := r.Client.Set(ctx, missingKey(r.key(orderUID)), 1, ttl).Err(); err != nil {
 		return fmt.Errorf("%s: set failed: %w", op, err)
 	}
 
 	return nil
 }
 
-// DeleteOrder deletes the order from Redis or returns an error.
+// DeleteOrder deletes the order from Redis and announces the eviction
+// on the invalidation channel, so every replica drops its in-process copy.
 func (r *Redis) DeleteOrder(ctx context.Context, orderUID string) error {
 	const op = "storage.redis.DeleteOrder"
 
-	key := orderUID
+	key := r.key(orderUID)
 
-	err := r.Client.Del(ctx, key).Err()
+	err := r.Client.Del(ctx, key, freshKey(key), missingKey(key)).Err()
 	if err != nil {
 		return fmt.Errorf("%s: del failed: %w", op, err)
 	}
 
+	if err := r.Client.Publish(ctx, r.channel, orderUID).Err(); err != nil {
+		return fmt.Errorf("%s: publish invalidation failed: %w", op, err)
+	}
+
 	return nil
 }
 
+// SubscribeInvalidations calls evict with the orderUID of every order deleted
+// by any replica. It blocks until the context is canceled.
+func (r *Redis) SubscribeInvalidations(ctx context.Context, evict func(orderUID string)) error {
+	const op = "storage.redis.SubscribeInvalidations"
+
+	pubsub := r.Client.Subscribe(ctx, r.channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return fmt.Errorf("%s: subscribe failed: %w", op, err)
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			evict(msg.Payload)
+		}
+	}
+}
+
 // Close closes the underlying database connection.
 // Should be called on application shutdown.
 func (r *Redis) Close() error {
 	return r.Client.Close()
 }
+
+// key returns the namespaced cache key of the order.
+// The UID is wrapped in a hash tag, so the order and its marker keys
+// land in the same Cluster slot and can be updated in one transaction.
+func (r *Redis) key(orderUID string) string {
+	return r.keyPrefix + "{" + orderUID + "}"
+}
+
+// freshKey returns the key of the marker that lives while the order is fresh.
+func freshKey(key string) string {
+	return key + ":fresh"
+}
+
+// missingKey returns the key of the negative cache entry for an unknown order.
+func missingKey(key string) string {
+	return key + ":missing"
+}
+
+// softTTL returns the period during which a cached order is considered fresh.
+func (r *Redis) softTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return ttl
+	}
+	return time.Duration(float64(ttl) * r.staleRatio)
+}
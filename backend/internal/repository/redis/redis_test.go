@@ -0,0 +1,275 @@
+package redis
+
+import (
+	"WB/internal/config"
+	"WB/internal/lib/ratelimit"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCache = config.Cache{
+	StaleRatio:  0.5,
+	KeyPrefix:   "wb:order",
+	KeyVersion:  1,
+	Compression: CompressionNone,
+}
+
+// newTestRedis starts an in-process Redis and returns the repository on top of it.
+func newTestRedis(t *testing.T, cache config.Cache) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{mr.Addr()}})
+	t.Cleanup(func() { _ = client.Close() })
+
+	r, err := New(client, cache)
+	require.NoError(t, err)
+
+	return r, mr
+}
+
+func TestRedis_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t, testCache)
+
+	data := []byte(`{"order_uid":"b563feb7b2b84b6test"}`)
+
+	require.NoError(t, r.SetOrder(ctx, "b563feb7b2b84b6test", data, time.Hour))
+	assert.True(t, mr.Exists("wb:order:v1:{b563feb7b2b84b6test}"))
+
+	got, err := r.GetOrder(ctx, "b563feb7b2b84b6test")
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	require.NoError(t, r.DeleteOrder(ctx, "b563feb7b2b84b6test"))
+
+	got, err = r.GetOrder(ctx, "b563feb7b2b84b6test")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestRedis_GetOrderState_Stale(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t, testCache)
+
+	data := []byte(`{"order_uid":"stale"}`)
+	require.NoError(t, r.SetOrder(ctx, "stale", data, time.Hour))
+
+	_, stale, err := r.GetOrderState(ctx, "stale")
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	mr.FastForward(31 * time.Minute)
+
+	got, stale, err := r.GetOrderState(ctx, "stale")
+	require.NoError(t, err)
+	assert.True(t, stale)
+	assert.Equal(t, data, got)
+
+	mr.FastForward(30 * time.Minute)
+
+	got, _, err = r.GetOrderState(ctx, "stale")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestRedis_OrderMissing(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t, testCache)
+
+	missing, err := r.IsOrderMissing(ctx, "unknown")
+	require.NoError(t, err)
+	assert.False(t, missing)
+
+	require.NoError(t, r.SetOrderMissing(ctx, "unknown", time.Minute))
+
+	missing, err = r.IsOrderMissing(ctx, "unknown")
+	require.NoError(t, err)
+	assert.True(t, missing)
+
+	require.NoError(t, r.SetOrder(ctx, "unknown", []byte(`{}`), time.Hour))
+
+	missing, err = r.IsOrderMissing(ctx, "unknown")
+	require.NoError(t, err)
+	assert.False(t, missing)
+
+	require.NoError(t, r.SetOrderMissing(ctx, "expiring", time.Minute))
+	mr.FastForward(time.Minute)
+
+	missing, err = r.IsOrderMissing(ctx, "expiring")
+	require.NoError(t, err)
+	assert.False(t, missing)
+}
+
+func TestRedis_Compression(t *testing.T) {
+	ctx := context.Background()
+	cache := testCache
+	cache.Compression = CompressionZstd
+	r, mr := newTestRedis(t, cache)
+
+	data := []byte(`{"order_uid":"compressed","items":[{"name":"Mascaras"},{"name":"Mascaras"}]}`)
+	require.NoError(t, r.SetOrder(ctx, "compressed", data, time.Hour))
+
+	raw, err := mr.Get("wb:order:v1:{compressed}")
+	require.NoError(t, err)
+	assert.Equal(t, markerZstd, raw[0])
+
+	got, err := r.GetOrder(ctx, "compressed")
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestRedis_KeyVersion(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t, testCache)
+
+	require.NoError(t, r.SetOrder(ctx, "versioned", []byte(`{}`), time.Hour))
+
+	cache := testCache
+	cache.KeyVersion = 2
+	v2, err := New(r.Client, cache)
+	require.NoError(t, err)
+
+	got, err := v2.GetOrder(ctx, "versioned")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	assert.True(t, mr.Exists("wb:order:v1:{versioned}"))
+}
+
+func TestNew_InvalidCache(t *testing.T) {
+	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{"localhost:0"}})
+	defer client.Close()
+
+	cache := testCache
+	cache.Compression = "lzma"
+	_, err := New(client, cache)
+	assert.Error(t, err)
+
+	cache = testCache
+	cache.StaleRatio = 0
+	_, err = New(client, cache)
+	assert.Error(t, err)
+}
+
+func TestUniversalOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Redis
+		want    redis.UniversalOptions
+		wantErr bool
+	}{
+		{
+			name: "single",
+			cfg:  config.Redis{Mode: ModeSingle, Host: "localhost", Port: "6379", Username: "app", Password: "secret", DB: 2},
+			want: redis.UniversalOptions{Addrs: []string{"localhost:6379"}, Username: "app", Password: "secret", DB: 2},
+		},
+		{
+			name: "sentinel",
+			cfg: config.Redis{
+				Mode: ModeSentinel, Addrs: []string{"s1:26379", "s2:26379"}, MasterName: "mymaster",
+				Password: "secret", SentinelPassword: "sentinel",
+			},
+			want: redis.UniversalOptions{
+				Addrs: []string{"s1:26379", "s2:26379"}, MasterName: "mymaster",
+				Password: "secret", SentinelPassword: "sentinel",
+			},
+		},
+		{
+			name: "cluster",
+			cfg:  config.Redis{Mode: ModeCluster, Addrs: []string{"n1:6379"}},
+			want: redis.UniversalOptions{Addrs: []string{"n1:6379"}, IsClusterMode: true},
+		},
+		{
+			name:    "sentinel without master",
+			cfg:     config.Redis{Mode: ModeSentinel, Addrs: []string{"s1:26379"}},
+			wantErr: true,
+		},
+		{
+			name:    "cluster with db",
+			cfg:     config.Redis{Mode: ModeCluster, Addrs: []string{"n1:6379"}, DB: 1},
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			cfg:     config.Redis{Mode: "ring"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := universalOptions(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func TestRedis_InvalidationBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	publisher, mr := newTestRedis(t, testCache)
+
+	client := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{mr.Addr()}})
+	defer client.Close()
+	replica, err := New(client, testCache)
+	require.NoError(t, err)
+
+	evicted := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- replica.SubscribeInvalidations(ctx, func(orderUID string) { evicted <- orderUID })
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(mr.PubSubChannels("")) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, publisher.DeleteOrder(ctx, "changed-order"))
+
+	select {
+	case got := <-evicted:
+		assert.Equal(t, "changed-order", got)
+	case <-time.After(time.Second):
+		t.Fatal("invalidation was not delivered")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRedis_Allow(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t, testCache)
+
+	limit := ratelimit.Limit{Rate: 0.5, Burst: 2}
+
+	res, err := r.Allow(ctx, "create_order:billing", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	res, err = r.Allow(ctx, "create_order:billing", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, err = r.Allow(ctx, "create_order:billing", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.InDelta(t, 2*time.Second, res.RetryAfter, float64(100*time.Millisecond))
+
+	res, err = r.Allow(ctx, "create_order:other", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"WB/internal/lib/audit"
+	"WB/internal/lib/auth"
+	"WB/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Audit log actions.
+const (
+	ActionOrderCreate  = "order.create"
+	ActionOrderStore   = "order.store"
+	ActionOrderReadPII = "order.read_pii"
+
+	ActionPrivacyExport = "privacy.export"
+	ActionPrivacyErase  = "privacy.erase"
+)
+
+// Actors of operations without a caller identity.
+const (
+	actorAnonymous = "anonymous"
+	actorConsumer  = "kafka-consumer"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditLog stores the tamper-evident audit log.
+type AuditLog interface {
+	AppendAudit(ctx context.Context, recs ...models.AuditRecord) ([]models.AuditRecord, error)
+	ListAudit(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error)
+}
+
+// WithAuditLog records order mutations and data subject requests in the audit log.
+func WithAuditLog(l AuditLog) Option {
+	return func(uc *OrderUseCase) {
+		uc.auditLog = l
+	}
+}
+
+// WithPIIReadAudit also records every read that returns unmasked personal data.
+// It requires WithAuditLog.
+func WithPIIReadAudit() Option {
+	return func(uc *OrderUseCase) {
+		uc.auditReads = true
+	}
+}
+
+// ListAuditRecords returns audit records matching the filter.
+func (uc *OrderUseCase) ListAuditRecords(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	const op = "usecase.ListAuditRecords"
+
+	if uc.auditLog == nil {
+		return nil, fmt.Errorf("%s: audit log is not configured", op)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	filter.Limit = min(filter.Limit, maxAuditLimit)
+
+	records, err := uc.auditLog.ListAudit(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return records, nil
+}
+
+// VerifyAuditLog walks the whole audit log and checks its hash chain.
+// It returns the number of verified records; a broken chain yields audit.ErrChainBroken.
+func (uc *OrderUseCase) VerifyAuditLog(ctx context.Context) (int, error) {
+	const op = "usecase.VerifyAuditLog"
+
+	if uc.auditLog == nil {
+		return 0, fmt.Errorf("%s: audit log is not configured", op)
+	}
+
+	var verified int
+	var prevHash string
+	filter := models.AuditFilter{Limit: maxAuditLimit}
+	for {
+		records, err := uc.auditLog.ListAudit(ctx, filter)
+		if err != nil {
+			return verified, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(records) == 0 {
+			return verified, nil
+		}
+
+		if err := audit.Verify(prevHash, records); err != nil {
+			return verified, fmt.Errorf("%s: %w", op, err)
+		}
+
+		verified += len(records)
+		last := records[len(records)-1]
+		prevHash = last.Hash
+		filter.AfterID = last.ID
+	}
+}
+
+// orderAudit describes a change of one order. The diff of before and after is
+// stored with personal data masked; either may be nil.
+func orderAudit(action, resource, orderUID string, before, after any, details map[string]any) (models.AuditRecord, error) {
+	rec := models.AuditRecord{
+		Action:   action,
+		Resource: resource,
+		OrderUID: orderUID,
+		Details:  "{}",
+	}
+
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return models.AuditRecord{}, fmt.Errorf("marshal audit details: %w", err)
+		}
+		rec.Details = string(data)
+	}
+
+	if before != nil || after != nil {
+		diff, err := audit.Diff(before, after)
+		if err != nil {
+			return models.AuditRecord{}, err
+		}
+		rec.Diff = diff
+	}
+
+	return rec, nil
+}
+
+// auditOrder records a change or a read of the order by the caller from ctx,
+// or by the given actor if it is set.
+func (uc *OrderUseCase) auditOrder(ctx context.Context, action, by, orderUID string, before, after any) error {
+	if uc.auditLog == nil {
+		return nil
+	}
+
+	rec, err := orderAudit(action, "order:"+orderUID, orderUID, before, after, nil)
+	if err != nil {
+		return err
+	}
+	rec.Actor = by
+
+	return uc.audit(ctx, rec)
+}
+
+// auditStoredOnce records the order stored from Kafka unless the consumer
+// already audited it. It is a no-op without an audit log.
+func (uc *OrderUseCase) auditStoredOnce(ctx context.Context, order models.Order) error {
+	if uc.auditLog == nil {
+		return nil
+	}
+
+	recs, err := uc.auditLog.ListAudit(ctx, models.AuditFilter{OrderUID: order.OrderUID, Actor: actorConsumer, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("list audit: %w", err)
+	}
+	if len(recs) > 0 {
+		return nil
+	}
+
+	return uc.auditOrder(ctx, ActionOrderStore, actorConsumer, order.OrderUID, nil, order)
+}
+
+// retryStoreAudit retries the audit write of an order stored from Kafka and
+// reports the last error as models.ErrAuditUnavailable.
+func (uc *OrderUseCase) retryStoreAudit(ctx context.Context, write func() error) error {
+	var err error
+	for attempt := range storeAuditAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %w", models.ErrAuditUnavailable, errors.Join(err, ctx.Err()))
+			case <-time.After(storeAuditBackoff):
+			}
+		}
+		if err = write(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %w", models.ErrAuditUnavailable, err)
+}
+
+// audit stamps the records with the time, the request ID and the caller from ctx
+// and appends them to the audit log. It is a no-op without an audit log.
+func (uc *OrderUseCase) audit(ctx context.Context, recs ...models.AuditRecord) error {
+	if uc.auditLog == nil || len(recs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range recs {
+		recs[i].Time = now
+		recs[i].RequestID = audit.RequestID(ctx)
+		if recs[i].Actor == "" {
+			recs[i].Actor = actor(ctx)
+		}
+	}
+
+	if _, err := uc.auditLog.AppendAudit(ctx, recs...); err != nil {
+		return fmt.Errorf("append audit records: %w", err)
+	}
+
+	return nil
+}
+
+// actor names the caller from ctx in audit records.
+func actor(ctx context.Context) string {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return actorAnonymous
+	}
+	return id.Method + ":" + id.Subject
+}
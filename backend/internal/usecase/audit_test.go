@@ -0,0 +1,332 @@
+package usecase
+
+import (
+	"WB/internal/lib/audit"
+	"WB/internal/lib/auth"
+	"WB/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAuditLog struct {
+	mock.Mock
+}
+
+func (m *mockAuditLog) AppendAudit(ctx context.Context, recs ...models.AuditRecord) ([]models.AuditRecord, error) {
+	args := m.Called(ctx, recs)
+	stored, _ := args.Get(0).([]models.AuditRecord)
+	return stored, args.Error(1)
+}
+
+func (m *mockAuditLog) ListAudit(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.AuditRecord), args.Error(1)
+}
+
+// oneRecord matches a batch of a single audit record.
+func oneRecord(check func(rec models.AuditRecord) bool) any {
+	return mock.MatchedBy(func(recs []models.AuditRecord) bool {
+		return len(recs) == 1 && check(recs[0])
+	})
+}
+
+func TestCreateOrder_Audited(t *testing.T) {
+	ctx := audit.WithRequestID(
+		auth.WithIdentity(context.Background(), auth.Identity{Subject: "seller-1", Method: auth.MethodJWT}),
+		"req-create",
+	)
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+	mockAudit := new(mockAuditLog)
+
+	order := validOrder()
+
+	mockProd.On("Send", ctx, order.OrderUID, mock.Anything).Return(nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, oneRecord(func(rec models.AuditRecord) bool {
+			var diff map[string]audit.Change
+			return rec.Action == ActionOrderCreate &&
+				rec.Actor == "jwt:seller-1" &&
+				rec.RequestID == "req-create" &&
+				rec.OrderUID == order.OrderUID &&
+				json.Unmarshal([]byte(rec.Diff), &diff) == nil &&
+				diff["delivery.city"].After == "Kiryat Mozkin" &&
+				diff["delivery.name"].After == "T***"
+		})).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithAuditLog(mockAudit))
+
+	err := uc.CreateOrder(ctx, order)
+
+	assert.NoError(t, err)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestCreateOrder_AuditedBeforeSend(t *testing.T) {
+	ctx := context.Background()
+	mockProd := new(mockMessageBroker)
+	mockAudit := new(mockAuditLog)
+
+	mockAudit.On("AppendAudit", ctx, mock.Anything).Return(nil, errors.New("db down")).Once()
+
+	uc := NewOrderUseCase(new(mockOrderRepo), new(mockCacheRepo), mockProd, WithAuditLog(mockAudit))
+
+	err := uc.CreateOrder(ctx, validOrder())
+
+	assert.ErrorContains(t, err, "db down")
+	mockProd.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleMessage_Audited(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+	mockAudit := new(mockAuditLog)
+
+	order := models.Order{OrderUID: "stored-order", Delivery: models.Delivery{Name: "Test Testov"}}
+	data, _ := json.Marshal(order)
+
+	mockRepo.On("GetOrder", "stored-order").Return(models.Order{}, models.ErrOrderNotFound).Once()
+	mockRepo.On("NewOrder", mock.Anything).Return(nil).Once()
+	mockCache.On("SetOrder", ctx, "stored-order", mock.Anything, mock.Anything).Return(nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, oneRecord(func(rec models.AuditRecord) bool {
+			return rec.Action == ActionOrderStore && rec.Actor == actorConsumer && rec.Resource == "order:stored-order"
+		})).
+		Return(nil, errors.New("db down")).
+		Times(storeAuditAttempts)
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithAuditLog(mockAudit))
+
+	err := uc.HandleMessage(ctx, data, nil)
+
+	assert.ErrorIs(t, err, models.ErrAuditUnavailable)
+	assert.ErrorContains(t, err, "db down")
+	mockAudit.AssertExpectations(t)
+	mockCache.AssertNotCalled(t, "SetOrder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleMessage_DuplicateAudited(t *testing.T) {
+	order := models.Order{OrderUID: "stored-order"}
+	data, _ := json.Marshal(order)
+	storeFilter := models.AuditFilter{OrderUID: "stored-order", Actor: actorConsumer, Limit: 1}
+
+	tests := []struct {
+		name      string
+		audited   []models.AuditRecord
+		wantAudit bool
+	}{
+		{
+			name:      "audit write failed before",
+			audited:   []models.AuditRecord{},
+			wantAudit: true,
+		},
+		{
+			name:    "already audited",
+			audited: []models.AuditRecord{{ID: 7, Action: ActionOrderStore, OrderUID: "stored-order"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockRepo := new(mockOrderRepo)
+			mockAudit := new(mockAuditLog)
+
+			mockRepo.On("GetOrder", "stored-order").Return(order, nil).Once()
+			mockAudit.On("ListAudit", ctx, storeFilter).Return(tt.audited, nil).Once()
+			if tt.wantAudit {
+				mockAudit.
+					On("AppendAudit", ctx, oneRecord(func(rec models.AuditRecord) bool {
+						return rec.Action == ActionOrderStore && rec.Actor == actorConsumer && rec.OrderUID == "stored-order"
+					})).
+					Return(nil, nil).
+					Once()
+			}
+
+			uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker), WithAuditLog(mockAudit))
+
+			err := uc.HandleMessage(ctx, data, nil)
+
+			assert.NoError(t, err)
+			mockAudit.AssertExpectations(t)
+			mockRepo.AssertNotCalled(t, "NewOrder")
+			if !tt.wantAudit {
+				mockAudit.AssertNotCalled(t, "AppendAudit", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGetOrder_PIIReadAudit(t *testing.T) {
+	order := models.Order{OrderUID: "read-order", Delivery: models.Delivery{Name: "Test Testov"}}
+	orderJSON, _ := json.Marshal(order)
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantAudit bool
+	}{
+		{
+			name:      "unmasked read",
+			ctx:       auth.WithIdentity(context.Background(), auth.Identity{Subject: "a1", Method: auth.MethodAPIKey, Roles: []string{"admin"}}),
+			wantAudit: true,
+		},
+		{
+			name: "masked read",
+			ctx:  context.Background(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCache := new(mockCacheRepo)
+			mockAudit := new(mockAuditLog)
+
+			mockCache.On("GetOrder", tt.ctx, "read-order").Return(orderJSON, nil).Once()
+			if tt.wantAudit {
+				mockAudit.
+					On("AppendAudit", tt.ctx, oneRecord(func(rec models.AuditRecord) bool {
+						return rec.Action == ActionOrderReadPII && rec.Actor == "api_key:a1" && rec.Diff == ""
+					})).
+					Return(nil, nil).
+					Once()
+			}
+
+			uc := NewOrderUseCase(new(mockOrderRepo), mockCache, new(mockMessageBroker),
+				WithAuditLog(mockAudit), WithPIIReadAudit(), WithPIIMasking([]string{"admin"}))
+
+			_, err := uc.GetOrder(tt.ctx, "read-order")
+
+			assert.NoError(t, err)
+			mockAudit.AssertExpectations(t)
+			if !tt.wantAudit {
+				mockAudit.AssertNotCalled(t, "AppendAudit", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestListAuditRecords_Limit(t *testing.T) {
+	ctx := context.Background()
+	mockAudit := new(mockAuditLog)
+
+	mockAudit.On("ListAudit", ctx, models.AuditFilter{Actor: "api_key:a1", Limit: defaultAuditLimit}).Return([]models.AuditRecord{}, nil).Once()
+	mockAudit.On("ListAudit", ctx, models.AuditFilter{OrderUID: "o1", Limit: maxAuditLimit}).Return([]models.AuditRecord{}, nil).Once()
+
+	uc := NewOrderUseCase(new(mockOrderRepo), new(mockCacheRepo), new(mockMessageBroker), WithAuditLog(mockAudit))
+
+	_, err := uc.ListAuditRecords(ctx, models.AuditFilter{Actor: "api_key:a1"})
+	assert.NoError(t, err)
+	_, err = uc.ListAuditRecords(ctx, models.AuditFilter{OrderUID: "o1", Limit: 1_000_000})
+	assert.NoError(t, err)
+
+	mockAudit.AssertExpectations(t)
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	var chain []models.AuditRecord
+	prev := ""
+	for i := range maxAuditLimit + 1 {
+		rec := models.AuditRecord{ID: int64(i + 1), Action: ActionOrderCreate, Details: "{}"}
+		audit.Seal(prev, &rec)
+		prev = rec.Hash
+		chain = append(chain, rec)
+	}
+
+	tests := []struct {
+		name    string
+		tamper  func(chain []models.AuditRecord)
+		wantErr bool
+	}{
+		{name: "intact", tamper: func([]models.AuditRecord) {}},
+		{name: "tampered in second batch", tamper: func(chain []models.AuditRecord) { chain[maxAuditLimit].Actor = "x" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			records := append([]models.AuditRecord(nil), chain...)
+			tt.tamper(records)
+
+			mockAudit := new(mockAuditLog)
+			mockAudit.On("ListAudit", ctx, models.AuditFilter{Limit: maxAuditLimit}).Return(records[:maxAuditLimit], nil).Once()
+			mockAudit.On("ListAudit", ctx, models.AuditFilter{AfterID: maxAuditLimit, Limit: maxAuditLimit}).Return(records[maxAuditLimit:], nil).Once()
+			mockAudit.On("ListAudit", ctx, models.AuditFilter{AfterID: maxAuditLimit + 1, Limit: maxAuditLimit}).Return([]models.AuditRecord{}, nil).Maybe()
+
+			uc := NewOrderUseCase(new(mockOrderRepo), new(mockCacheRepo), new(mockMessageBroker), WithAuditLog(mockAudit))
+
+			n, err := uc.VerifyAuditLog(ctx)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, audit.ErrChainBroken)
+				assert.Equal(t, maxAuditLimit, n)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, maxAuditLimit+1, n)
+		})
+	}
+}
+
+func TestActor(t *testing.T) {
+	assert.Equal(t, actorAnonymous, actor(context.Background()))
+	assert.True(t, strings.HasPrefix(actor(auth.WithIdentity(context.Background(), auth.Identity{Method: "jwt", Subject: "s"})), "jwt:"))
+}
+
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:        "audit-order",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Now().UTC(),
+		OofShard:        "1",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "audit-order",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				Rid:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+	}
+}
@@ -0,0 +1,258 @@
+package usecase
+
+import (
+	"WB/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Audit log actions of canceled and refunded orders.
+const (
+	ActionOrderCancel = "order.cancel"
+	ActionOrderRefund = "order.refund"
+)
+
+// RefundRepository stores canceled and refunded orders with optimistic concurrency control.
+type RefundRepository interface {
+	// SaveRefund stores the item statuses and the payment totals of the order
+	// together with the refund, if its stored version is still version, and
+	// increments the version. In the same transaction it stores the event in
+	// the outbox with the ID of the refund. It returns the ID of the refund.
+	SaveRefund(order models.Order, version int, refund models.Refund, event models.OrderEvent) (int64, error)
+}
+
+// OutboxRepository lists the order events stored by SaveRefund until they are published.
+type OutboxRepository interface {
+	PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	MarkEventPublished(ctx context.Context, id int64) error
+}
+
+// WithEventBroker publishes order events, such as cancellations, through b.
+// Without it the events are kept in the outbox, see PublishEvents.
+func WithEventBroker(b MessageBroker) Option {
+	return func(uc *OrderUseCase) {
+		uc.eventBroker = b
+	}
+}
+
+// CancelOrder cancels the selected items of the order, or every item not
+// canceled yet, if the order is still at the given version (0 matches any).
+// Delivered items can't be canceled, they are refunded with RefundOrder.
+// The canceled items are subtracted from the payment and recorded as a refund;
+// once every item is canceled, the delivery cost and the custom fee are refunded too.
+// A broken rule yields models.ErrOrderState, an unknown chrt_id models.ErrInvalidOrder.
+// If the changed order can't be evicted from the cache, it is returned with
+// the refund and models.ErrStaleCache.
+func (uc *OrderUseCase) CancelOrder(ctx context.Context, orderUID string, version int, req models.CancelRequest) (models.Order, models.Refund, error) {
+	const op = "usecase.CancelOrder"
+
+	order, refund, err := uc.closeItems(ctx, orderUID, version, req, models.RefundKindCancel)
+	if err != nil {
+		return order, refund, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return order, refund, nil
+}
+
+// RefundOrder refunds the selected delivered items of the order, or every
+// item not refunded yet, if the order is still at the given version (0 matches any).
+// Items that were not delivered are canceled with CancelOrder instead.
+// Refunds follow the rules of CancelOrder, except that the delivery cost is kept.
+func (uc *OrderUseCase) RefundOrder(ctx context.Context, orderUID string, version int, req models.CancelRequest) (models.Order, models.Refund, error) {
+	const op = "usecase.RefundOrder"
+
+	order, refund, err := uc.closeItems(ctx, orderUID, version, req, models.RefundKindReturn)
+	if err != nil {
+		return order, refund, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return order, refund, nil
+}
+
+// closeItems cancels or refunds the items, stores the refund together with
+// the event, records the change in the audit log and evicts the order from the
+// caches. If the eviction fails, the changed order and the refund are returned
+// with models.ErrStaleCache. The event is published from the outbox by PublishEvents.
+func (uc *OrderUseCase) closeItems(ctx context.Context, orderUID string, version int, req models.CancelRequest, kind string) (models.Order, models.Refund, error) {
+	repo, ok := uc.orderRepo.(RefundRepository)
+	if !ok {
+		return models.Order{}, models.Refund{}, fmt.Errorf("order repository does not support refunds")
+	}
+
+	before, masked, err := uc.loadForChange(ctx, orderUID, version)
+	if err != nil {
+		return models.Order{}, models.Refund{}, err
+	}
+	if masked {
+		return models.Order{}, models.Refund{}, fmt.Errorf("caller may not change personal data: %w", models.ErrForbidden)
+	}
+
+	after, refund, err := planRefund(before, req, kind)
+	if err != nil {
+		return models.Order{}, models.Refund{}, err
+	}
+	refund.CreatedAt = time.Now().UTC()
+	after.UpdatedAt = refund.CreatedAt
+	after.Version = before.Version + 1
+
+	// only statuses and payment totals are stored, personal data stays sealed
+	refund.ID, err = repo.SaveRefund(after, before.Version, refund, orderEvent(after, refund))
+	if err != nil {
+		return models.Order{}, models.Refund{}, err
+	}
+
+	action := ActionOrderCancel
+	if kind == models.RefundKindReturn {
+		action = ActionOrderRefund
+	}
+	rec, err := orderAudit(action, "order:"+orderUID, orderUID, before, after, map[string]any{
+		"refund_id": refund.ID,
+		"amount":    refund.Amount,
+		"reason":    refund.Reason,
+	})
+	if err == nil {
+		err = uc.audit(ctx, rec)
+	}
+	if err != nil {
+		return models.Order{}, models.Refund{}, errors.Join(err, uc.evict(ctx, orderUID))
+	}
+
+	// a stale entry would serve the old version and fail conditional requests
+	if err := uc.evict(ctx, orderUID); err != nil {
+		return after, refund, fmt.Errorf("%w: %w", models.ErrStaleCache, err)
+	}
+
+	return after, refund, nil
+}
+
+// PublishEvents sends the pending events of the outbox to the event broker in
+// the order they were stored and marks them published, up to batchSize events
+// at a time until none is left. It stops at the first failed send, so the
+// events of an order are not reordered. An event is sent again if marking it
+// fails; consumers drop duplicates by the refund ID. It returns the number of
+// published events.
+func (uc *OrderUseCase) PublishEvents(ctx context.Context, batchSize int) (int, error) {
+	const op = "usecase.PublishEvents"
+
+	repo, ok := uc.orderRepo.(OutboxRepository)
+	if !ok || uc.eventBroker == nil {
+		return 0, fmt.Errorf("%s: event outbox or broker is not configured", op)
+	}
+
+	published := 0
+	for {
+		events, err := repo.PendingEvents(ctx, batchSize)
+		if err != nil {
+			return published, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, e := range events {
+			if err := uc.eventBroker.Send(ctx, e.Key, e.Payload); err != nil {
+				return published, fmt.Errorf("%s: publish event %d: %w", op, e.ID, err)
+			}
+			if err := repo.MarkEventPublished(ctx, e.ID); err != nil {
+				return published, fmt.Errorf("%s: %w", op, err)
+			}
+			published++
+		}
+
+		if len(events) < batchSize {
+			return published, nil
+		}
+	}
+}
+
+// orderEvent returns the cancellation or refund event of the changed order.
+func orderEvent(order models.Order, refund models.Refund) models.OrderEvent {
+	event := models.OrderEvent{
+		Type:     models.EventOrderRefunded,
+		OrderUID: order.OrderUID,
+		Version:  order.Version,
+		Time:     refund.CreatedAt,
+		Refund:   refund,
+	}
+	if refund.Kind == models.RefundKindCancel {
+		event.Type = models.EventItemsCanceled
+		if !slices.ContainsFunc(order.Items, func(it models.Item) bool { return it.Status != models.ItemStatusCanceled }) {
+			event.Type = models.EventOrderCanceled
+		}
+	}
+
+	return event
+}
+
+// planRefund returns a copy of the order with the selected items canceled or
+// refunded and the payment totals reduced, together with the refund.
+func planRefund(order models.Order, req models.CancelRequest, kind string) (models.Order, models.Refund, error) {
+	status := models.ItemStatusCanceled
+	if kind == models.RefundKindReturn {
+		status = models.ItemStatusRefunded
+	}
+
+	for _, id := range req.ChrtIDs {
+		if !slices.ContainsFunc(order.Items, func(it models.Item) bool { return it.ChrtID == id }) {
+			return models.Order{}, models.Refund{}, fmt.Errorf("unknown item chrt_id %d: %w", id, models.ErrInvalidOrder)
+		}
+	}
+
+	whole := len(req.ChrtIDs) == 0
+	order.Items = slices.Clone(order.Items)
+
+	var chrtIDs []int
+	var amount int
+	for i := range order.Items {
+		it := &order.Items[i]
+		if !whole && !slices.Contains(req.ChrtIDs, it.ChrtID) {
+			continue
+		}
+		if whole && isClosed(it.Status) {
+			continue
+		}
+
+		switch {
+		case isClosed(it.Status):
+			return models.Order{}, models.Refund{}, fmt.Errorf("item %d is already canceled or refunded: %w", it.ChrtID, models.ErrOrderState)
+		case kind == models.RefundKindCancel && it.Status == models.ItemStatusDelivered:
+			return models.Order{}, models.Refund{}, fmt.Errorf("item %d is delivered, refund it instead: %w", it.ChrtID, models.ErrOrderState)
+		case kind == models.RefundKindReturn && it.Status != models.ItemStatusDelivered:
+			return models.Order{}, models.Refund{}, fmt.Errorf("item %d is not delivered, cancel it instead: %w", it.ChrtID, models.ErrOrderState)
+		}
+
+		it.Status = status
+		amount += it.TotalPrice
+		chrtIDs = append(chrtIDs, it.ChrtID)
+	}
+	if len(chrtIDs) == 0 {
+		return models.Order{}, models.Refund{}, fmt.Errorf("no items left to %s: %w", kind, models.ErrOrderState)
+	}
+
+	p := &order.Payment
+	p.GoodsTotal = max(p.GoodsTotal-amount, 0)
+	// nothing was delivered, so nothing is owed for the delivery either
+	if !slices.ContainsFunc(order.Items, func(it models.Item) bool { return it.Status != models.ItemStatusCanceled }) {
+		amount += p.DeliveryCost + p.CustomFee
+		p.DeliveryCost, p.CustomFee = 0, 0
+	}
+	amount = min(amount, p.Amount)
+	p.Amount -= amount
+
+	refund := models.Refund{
+		OrderUID:    order.OrderUID,
+		Transaction: p.Transaction,
+		Kind:        kind,
+		ChrtIDs:     chrtIDs,
+		Amount:      amount,
+		Currency:    p.Currency,
+		Reason:      req.Reason,
+	}
+
+	return order, refund, nil
+}
+
+// isClosed reports whether the item status is final.
+func isClosed(status int) bool {
+	return status == models.ItemStatusCanceled || status == models.ItemStatusRefunded
+}
@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"WB/internal/lib/auth"
+	"WB/internal/models"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRefundRepo struct {
+	mockOrderRepo
+}
+
+func (m *mockRefundRepo) SaveRefund(order models.Order, version int, refund models.Refund, event models.OrderEvent) (int64, error) {
+	args := m.Called(order, version, refund, event)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockRefundRepo) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	args := m.Called(ctx, limit)
+	events, _ := args.Get(0).([]models.OutboxEvent)
+	return events, args.Error(1)
+}
+
+func (m *mockRefundRepo) MarkEventPublished(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// twoItemOrder is an order of two items worth 300 and 200 with a delivery cost of 100.
+func twoItemOrder(status1, status2 int) models.Order {
+	order := validOrder()
+	order.Version = 1
+	order.Items = []models.Item{
+		{ChrtID: 1, TotalPrice: 300, Status: status1},
+		{ChrtID: 2, TotalPrice: 200, Status: status2},
+	}
+	order.Payment.GoodsTotal = 500
+	order.Payment.DeliveryCost = 100
+	order.Payment.CustomFee = 10
+	order.Payment.Amount = 610
+	return order
+}
+
+func TestPlanRefund(t *testing.T) {
+	const open = 202
+	delivered := models.ItemStatusDelivered
+	canceled := models.ItemStatusCanceled
+	partlyCanceled := twoItemOrder(canceled, open)
+	partlyCanceled.Payment.GoodsTotal = 200
+	partlyCanceled.Payment.Amount = 310
+
+	tests := []struct {
+		name        string
+		order       models.Order
+		req         models.CancelRequest
+		kind        string
+		wantErr     error
+		wantAmount  int
+		wantPayment models.Payment
+		wantStatus  []int
+	}{
+		{
+			name:        "cancel one item",
+			order:       twoItemOrder(open, open),
+			req:         models.CancelRequest{ChrtIDs: []int{2}},
+			kind:        models.RefundKindCancel,
+			wantAmount:  200,
+			wantPayment: models.Payment{GoodsTotal: 300, DeliveryCost: 100, CustomFee: 10, Amount: 410},
+			wantStatus:  []int{open, canceled},
+		},
+		{
+			name:        "cancel whole order refunds delivery",
+			order:       twoItemOrder(open, open),
+			kind:        models.RefundKindCancel,
+			wantAmount:  610,
+			wantPayment: models.Payment{},
+			wantStatus:  []int{canceled, canceled},
+		},
+		{
+			name:        "cancel last open item refunds delivery",
+			order:       partlyCanceled,
+			kind:        models.RefundKindCancel,
+			wantAmount:  310,
+			wantPayment: models.Payment{},
+			wantStatus:  []int{canceled, canceled},
+		},
+		{
+			name:    "cancel delivered item",
+			order:   twoItemOrder(delivered, open),
+			req:     models.CancelRequest{ChrtIDs: []int{1}},
+			kind:    models.RefundKindCancel,
+			wantErr: models.ErrOrderState,
+		},
+		{
+			name:    "cancel partly delivered order",
+			order:   twoItemOrder(delivered, open),
+			kind:    models.RefundKindCancel,
+			wantErr: models.ErrOrderState,
+		},
+		{
+			name:    "cancel canceled item",
+			order:   twoItemOrder(canceled, open),
+			req:     models.CancelRequest{ChrtIDs: []int{1}},
+			kind:    models.RefundKindCancel,
+			wantErr: models.ErrOrderState,
+		},
+		{
+			name:    "cancel canceled order",
+			order:   twoItemOrder(canceled, canceled),
+			kind:    models.RefundKindCancel,
+			wantErr: models.ErrOrderState,
+		},
+		{
+			name:    "cancel unknown item",
+			order:   twoItemOrder(open, open),
+			req:     models.CancelRequest{ChrtIDs: []int{3}},
+			kind:    models.RefundKindCancel,
+			wantErr: models.ErrInvalidOrder,
+		},
+		{
+			name:        "refund delivered items keeps delivery",
+			order:       twoItemOrder(delivered, delivered),
+			kind:        models.RefundKindReturn,
+			wantAmount:  500,
+			wantPayment: models.Payment{GoodsTotal: 0, DeliveryCost: 100, CustomFee: 10, Amount: 110},
+			wantStatus:  []int{models.ItemStatusRefunded, models.ItemStatusRefunded},
+		},
+		{
+			name:    "refund undelivered item",
+			order:   twoItemOrder(delivered, open),
+			req:     models.CancelRequest{ChrtIDs: []int{2}},
+			kind:    models.RefundKindReturn,
+			wantErr: models.ErrOrderState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.order.Items[0].Status
+
+			order, refund, err := planRefund(tt.order, tt.req, tt.kind)
+
+			assert.Equal(t, original, tt.order.Items[0].Status, "the input order must not change")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAmount, refund.Amount)
+			assert.Equal(t, tt.kind, refund.Kind)
+			assert.Equal(t, tt.order.Payment.Transaction, refund.Transaction)
+			assert.Equal(t, tt.wantPayment.Amount, order.Payment.Amount)
+			assert.Equal(t, tt.wantPayment.GoodsTotal, order.Payment.GoodsTotal)
+			assert.Equal(t, tt.wantPayment.DeliveryCost, order.Payment.DeliveryCost)
+			assert.Equal(t, tt.wantPayment.CustomFee, order.Payment.CustomFee)
+			assert.Equal(t, tt.wantStatus, []int{order.Items[0].Status, order.Items[1].Status})
+		})
+	}
+}
+
+func TestCancelOrder_Success(t *testing.T) {
+	ctx := adminContext()
+	mockRepo := new(mockRefundRepo)
+	mockCache := new(mockCacheRepo)
+	mockEvents := new(mockMessageBroker)
+	mockAudit := new(mockAuditLog)
+
+	mockRepo.On("GetOrder", "audit-order").Return(twoItemOrder(202, 202), nil).Once()
+	mockRepo.
+		On("SaveRefund", mock.Anything, 1, mock.MatchedBy(func(r models.Refund) bool {
+			return r.Amount == 610 && r.Transaction == "audit-order" && r.Reason == "changed mind" && !r.CreatedAt.IsZero()
+		}), mock.MatchedBy(func(event models.OrderEvent) bool {
+			return event.Type == models.EventOrderCanceled &&
+				event.OrderUID == "audit-order" &&
+				event.Version == 2 &&
+				event.Refund.ChrtIDs[0] == 1
+		})).
+		Return(int64(7), nil).
+		Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, oneRecord(func(rec models.AuditRecord) bool {
+			return rec.Action == ActionOrderCancel && rec.OrderUID == "audit-order" && rec.Details == `{"amount":610,"reason":"changed mind","refund_id":7}`
+		})).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithAuditLog(mockAudit), WithEventBroker(mockEvents))
+
+	order, refund, err := uc.CancelOrder(ctx, "audit-order", 1, models.CancelRequest{Reason: "changed mind"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), refund.ID)
+	assert.Equal(t, 2, order.Version)
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+	mockEvents.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancelOrder_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		req     models.CancelRequest
+		wantErr error
+	}{
+		{name: "stale version", version: 2, wantErr: models.ErrVersionConflict},
+		{name: "delivered item", version: 1, req: models.CancelRequest{ChrtIDs: []int{1}}, wantErr: models.ErrOrderState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockRefundRepo)
+			mockEvents := new(mockMessageBroker)
+			mockRepo.On("GetOrder", "audit-order").Return(twoItemOrder(models.ItemStatusDelivered, 202), nil).Once()
+
+			uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker), WithEventBroker(mockEvents))
+
+			_, _, err := uc.CancelOrder(context.Background(), "audit-order", tt.version, tt.req)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "SaveRefund", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mockEvents.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCancelOrder_MaskedCallerForbidden(t *testing.T) {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "s1", Roles: []string{"seller"}})
+	mockRepo := new(mockRefundRepo)
+
+	mockRepo.On("GetOrder", "audit-order").Return(twoItemOrder(202, 202), nil).Once()
+
+	uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker), WithPIIMasking([]string{"admin"}))
+
+	_, _, err := uc.CancelOrder(ctx, "audit-order", 1, models.CancelRequest{})
+
+	assert.ErrorIs(t, err, models.ErrForbidden)
+	mockRepo.AssertNotCalled(t, "SaveRefund", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRefundOrder_EvictionRetried(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockRefundRepo)
+	mockCache := new(mockCacheRepo)
+
+	mockRepo.On("GetOrder", "audit-order").Return(twoItemOrder(models.ItemStatusDelivered, 202), nil).Once()
+	mockRepo.On("SaveRefund", mock.Anything, 1, mock.Anything, mock.Anything).Return(int64(1), nil).Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(errors.New("redis down")).Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(nil).Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker))
+
+	_, _, err := uc.RefundOrder(ctx, "audit-order", 0, models.CancelRequest{ChrtIDs: []int{1}})
+
+	assert.NoError(t, err)
+	mockCache.AssertExpectations(t)
+}
+
+func TestRefundOrder_EvictionFailure(t *testing.T) {
+	ctx := adminContext()
+	mockRepo := new(mockRefundRepo)
+	mockCache := new(mockCacheRepo)
+	mockAudit := new(mockAuditLog)
+
+	mockRepo.On("GetOrder", "audit-order").Return(twoItemOrder(models.ItemStatusDelivered, 202), nil).Once()
+	mockRepo.On("SaveRefund", mock.Anything, 1, mock.Anything, mock.Anything).Return(int64(7), nil).Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(errors.New("redis down")).Times(evictAttempts)
+	mockAudit.
+		On("AppendAudit", ctx, oneRecord(func(rec models.AuditRecord) bool {
+			return rec.Action == ActionOrderRefund && rec.OrderUID == "audit-order"
+		})).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithAuditLog(mockAudit))
+
+	order, refund, err := uc.RefundOrder(ctx, "audit-order", 0, models.CancelRequest{ChrtIDs: []int{1}})
+
+	assert.ErrorIs(t, err, models.ErrStaleCache)
+	assert.ErrorContains(t, err, "redis down")
+	assert.Equal(t, int64(7), refund.ID)
+	assert.Equal(t, 2, order.Version)
+	mockCache.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestPublishEvents(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockRefundRepo)
+	mockEvents := new(mockMessageBroker)
+
+	mockRepo.On("PendingEvents", ctx, 2).Return([]models.OutboxEvent{
+		{ID: 1, Key: "a", Payload: []byte(`{"type":"order.canceled"}`)},
+		{ID: 2, Key: "b", Payload: []byte(`{"type":"order.refunded"}`)},
+	}, nil).Once()
+	mockRepo.On("PendingEvents", ctx, 2).Return([]models.OutboxEvent{
+		{ID: 3, Key: "a", Payload: []byte(`{"type":"order.refunded"}`)},
+	}, nil).Once()
+	mockEvents.On("Send", ctx, "a", []byte(`{"type":"order.canceled"}`)).Return(nil).Once()
+	mockEvents.On("Send", ctx, "b", []byte(`{"type":"order.refunded"}`)).Return(nil).Once()
+	mockEvents.On("Send", ctx, "a", []byte(`{"type":"order.refunded"}`)).Return(nil).Once()
+	mockRepo.On("MarkEventPublished", ctx, int64(1)).Return(nil).Once()
+	mockRepo.On("MarkEventPublished", ctx, int64(2)).Return(nil).Once()
+	mockRepo.On("MarkEventPublished", ctx, int64(3)).Return(nil).Once()
+
+	uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker), WithEventBroker(mockEvents))
+
+	n, err := uc.PublishEvents(ctx, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	mockRepo.AssertExpectations(t)
+	mockEvents.AssertExpectations(t)
+}
+
+func TestPublishEvents_StopsAtFailedSend(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockRefundRepo)
+	mockEvents := new(mockMessageBroker)
+
+	mockRepo.On("PendingEvents", ctx, 10).Return([]models.OutboxEvent{
+		{ID: 1, Key: "a", Payload: []byte(`{}`)},
+		{ID: 2, Key: "a", Payload: []byte(`{}`)},
+	}, nil).Once()
+	mockEvents.On("Send", ctx, "a", []byte(`{}`)).Return(errors.New("broker down")).Once()
+
+	uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker), WithEventBroker(mockEvents))
+
+	n, err := uc.PublishEvents(ctx, 10)
+
+	assert.ErrorContains(t, err, "broker down")
+	assert.Zero(t, n)
+	mockRepo.AssertNotCalled(t, "MarkEventPublished", mock.Anything, mock.Anything)
+	mockEvents.AssertNumberOfCalls(t, "Send", 1)
+}
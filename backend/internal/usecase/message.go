@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"WB/internal/lib/codec"
+	"WB/internal/lib/envelope"
+	"WB/internal/models"
+	"context"
+	"fmt"
+)
+
+// orderMessages knows the schema versions of the orders sent through Kafka.
+var orderMessages = envelope.NewRegistry[models.Order]().
+	Current(models.EventOrderCreated, models.OrderSchemaVersion).
+	Register(models.EventOrderCreated, 0, upcastBareOrder)
+
+// HeaderBroker is implemented by message brokers that send message headers.
+// Orders are sent in codecs other than JSON only through such a broker.
+type HeaderBroker interface {
+	SendHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error
+}
+
+// WithProducerID sets the producer ID written to the envelope of the orders sent to Kafka.
+func WithProducerID(id string) Option {
+	return func(uc *OrderUseCase) {
+		uc.producerID = id
+	}
+}
+
+// WithMessageCodec sends orders to Kafka in the codec c instead of JSON.
+// Orders in c are accepted by HandleMessage too.
+func WithMessageCodec(c codec.Codec) Option {
+	return func(uc *OrderUseCase) {
+		uc.codec = c
+		uc.acceptCodec(c)
+	}
+}
+
+// WithAcceptedCodecs makes HandleMessage accept orders in the codecs cs
+// besides JSON, so producers can switch codecs one at a time.
+func WithAcceptedCodecs(cs ...codec.Codec) Option {
+	return func(uc *OrderUseCase) {
+		for _, c := range cs {
+			uc.acceptCodec(c)
+		}
+	}
+}
+
+func (uc *OrderUseCase) acceptCodec(c codec.Codec) {
+	if uc.codecs == nil {
+		uc.codecs = make(map[string]codec.Codec)
+	}
+	uc.codecs[c.ContentType()] = c
+}
+
+// encodeOrder returns the Kafka message of the new order. JSON orders are
+// wrapped in an envelope and have no headers; orders in other codecs carry
+// the envelope in the headers.
+func (uc *OrderUseCase) encodeOrder(order models.Order) (value []byte, headers map[string]string, err error) {
+	c := uc.codec
+	if c == nil {
+		c = codec.JSON{}
+	}
+
+	value, err = c.Marshal(order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.ContentType() == codec.ContentTypeJSON {
+		value, err = orderMessages.Wrap(models.EventOrderCreated, uc.producerID, value)
+		return value, nil, err
+	}
+
+	headers, err = orderMessages.WrapHeaders(models.EventOrderCreated, uc.producerID, c.ContentType())
+	return value, headers, err
+}
+
+// decodeOrder returns the order of a Kafka message in any accepted codec,
+// upcast to the current schema version.
+func (uc *OrderUseCase) decodeOrder(value []byte, headers map[string]string) (models.Order, error) {
+	env, err := orderMessages.OpenMessage(value, headers, models.EventOrderCreated)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if env.Type != models.EventOrderCreated {
+		return models.Order{}, fmt.Errorf("unexpected message type %q: %w", env.Type, models.ErrUnknownSchema)
+	}
+
+	var c codec.Codec = codec.JSON{}
+	if env.ContentType != codec.ContentTypeJSON {
+		var ok bool
+		if c, ok = uc.codecs[env.ContentType]; !ok {
+			return models.Order{}, fmt.Errorf("content type %q: %w", env.ContentType, models.ErrUnknownSchema)
+		}
+	}
+
+	order, err := c.Unmarshal(env.Payload)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return orderMessages.Upcast(env, order)
+}
+
+// send writes the message to Kafka, with the headers if there are any.
+func (uc *OrderUseCase) send(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	if len(headers) == 0 {
+		return uc.messageBroker.Send(ctx, key, value)
+	}
+
+	hb, ok := uc.messageBroker.(HeaderBroker)
+	if !ok {
+		return fmt.Errorf("message broker does not support headers")
+	}
+	return hb.SendHeaders(ctx, key, value, headers)
+}
+
+// upcastBareOrder converts an order written before the envelope was introduced.
+// The bare order is the payload of version 1 as is.
+func upcastBareOrder(order models.Order) (models.Order, error) {
+	return order, nil
+}
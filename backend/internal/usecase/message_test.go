@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"WB/internal/lib/codec"
+	"WB/internal/lib/envelope"
+	"WB/internal/models"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockHeaderBroker struct {
+	mockMessageBroker
+}
+
+func (m *mockHeaderBroker) SendHeaders(ctx context.Context, key string, value []byte, headers map[string]string) error {
+	args := m.Called(ctx, key, value, headers)
+	return args.Error(0)
+}
+
+func TestCreateOrder_MessageCodec(t *testing.T) {
+	ctx := context.Background()
+	broker := new(mockHeaderBroker)
+
+	var value []byte
+	var headers map[string]string
+	broker.On("SendHeaders", ctx, "audit-order", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			value, headers = args.Get(2).([]byte), args.Get(3).(map[string]string)
+		}).
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(new(mockOrderRepo), new(mockCacheRepo), broker, WithMessageCodec(codec.Protobuf{}), WithProducerID("test"))
+
+	assert.NoError(t, uc.CreateOrder(ctx, validOrder()))
+	broker.AssertExpectations(t)
+	assert.Equal(t, codec.ContentTypeProtobuf, headers[envelope.HeaderContentType])
+	assert.Equal(t, "1", headers[envelope.HeaderSchemaVersion])
+	assert.Equal(t, models.EventOrderCreated, headers[envelope.HeaderType])
+	assert.Equal(t, "test", headers[envelope.HeaderProducerID])
+
+	order, err := codec.Protobuf{}.Unmarshal(value)
+	assert.NoError(t, err)
+	assert.Equal(t, "audit-order", order.OrderUID)
+
+	// without header support only JSON can be sent
+	uc = NewOrderUseCase(new(mockOrderRepo), new(mockCacheRepo), new(mockMessageBroker), WithMessageCodec(codec.Protobuf{}))
+	assert.Error(t, uc.CreateOrder(ctx, validOrder()))
+}
+
+func TestHandleMessage_Codecs(t *testing.T) {
+	protobuf, err := codec.Protobuf{}.Marshal(validOrder())
+	assert.NoError(t, err)
+	headers := func(contentType, version string) map[string]string {
+		return map[string]string{
+			envelope.HeaderContentType:   contentType,
+			envelope.HeaderSchemaVersion: version,
+			envelope.HeaderType:          models.EventOrderCreated,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		value   []byte
+		headers map[string]string
+		accept  []codec.Codec
+		wantErr error
+	}{
+		{name: "protobuf", value: protobuf, headers: headers(codec.ContentTypeProtobuf, "1"), accept: []codec.Codec{codec.Protobuf{}}},
+		{name: "protobuf version 0 is upcast", value: protobuf, headers: headers(codec.ContentTypeProtobuf, "0"), accept: []codec.Codec{codec.Protobuf{}}},
+		{name: "protobuf not accepted", value: protobuf, headers: headers(codec.ContentTypeProtobuf, "1"), wantErr: models.ErrUnknownSchema},
+		{name: "newer version", value: protobuf, headers: headers(codec.ContentTypeProtobuf, "2"), accept: []codec.Codec{codec.Protobuf{}}, wantErr: models.ErrUnknownSchema},
+		{name: "no version", value: protobuf, headers: headers(codec.ContentTypeProtobuf, ""), accept: []codec.Codec{codec.Protobuf{}}, wantErr: models.ErrUnknownSchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockRepo := new(mockOrderRepo)
+			mockCache := new(mockCacheRepo)
+			mockRepo.On("GetOrder", "audit-order").Return(models.Order{}, models.ErrOrderNotFound).Maybe()
+			mockRepo.On("NewOrder", mock.MatchedBy(func(o models.Order) bool { return o.Delivery.City == "Kiryat Mozkin" })).Return(nil).Maybe()
+			mockCache.On("SetOrder", ctx, "audit-order", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithAcceptedCodecs(tt.accept...))
+
+			err := uc.HandleMessage(ctx, tt.value, tt.headers)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				mockRepo.AssertNotCalled(t, "NewOrder", mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			mockRepo.AssertCalled(t, "NewOrder", mock.Anything)
+		})
+	}
+}
+
+func TestHandleMessage_Envelope(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+
+	mockRepo.On("GetOrder", "audit-order").Return(models.Order{}, models.ErrOrderNotFound).Once()
+	mockRepo.On("NewOrder", mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "audit-order" })).Return(nil).Once()
+	mockCache.On("SetOrder", ctx, "audit-order", mock.Anything, mock.Anything).Return(nil).Once()
+
+	orderJSON, _ := json.Marshal(validOrder())
+	msg, err := orderMessages.Wrap(models.EventOrderCreated, "test", orderJSON)
+	assert.NoError(t, err)
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker))
+
+	assert.NoError(t, uc.HandleMessage(ctx, msg, nil))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHandleMessage_UnknownSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{name: "newer version", msg: `{"schema_version": 99, "type": "order.created", "content_type": "application/json", "payload": {}}`},
+		{name: "unknown type", msg: `{"schema_version": 1, "type": "order.deleted", "content_type": "application/json", "payload": {}}`},
+		{name: "unknown content type", msg: `{"schema_version": 1, "type": "order.created", "content_type": "text/csv", "payload": "a,b"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockOrderRepo)
+			uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker))
+
+			err := uc.HandleMessage(context.Background(), []byte(tt.msg), nil)
+
+			assert.ErrorIs(t, err, models.ErrUnknownSchema)
+			mockRepo.AssertNotCalled(t, "NewOrder", mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"WB/internal/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PIICipher encrypts personal data fields of an order.
+// Orders are encrypted before they are persisted or cached and are
+// decrypted only when they leave the usecase.
+type PIICipher interface {
+	Encrypt(field, plaintext string) (string, error)
+	Decrypt(field, ciphertext string) (string, error)
+	NeedsRotation(ciphertext string) bool
+}
+
+// DeliveryPIIRepository gives batch access to stored delivery data for key rotation.
+type DeliveryPIIRepository interface {
+	ListDeliveries(afterUID string, limit int) ([]models.DeliveryRecord, error)
+	// UpdateDeliveryPII stores the personal data fields of the order delivery
+	// and its UpdatedAt if its stored version is still version, and increments
+	// the version. Otherwise it returns models.ErrVersionConflict.
+	UpdateDeliveryPII(order models.Order, version int) error
+}
+
+// WithPIICipher enables encryption of delivery personal data at rest.
+func WithPIICipher(c PIICipher) Option {
+	return func(uc *OrderUseCase) {
+		uc.piiCipher = c
+	}
+}
+
+// piiFields returns pointers to the encrypted delivery fields by their names.
+func piiFields(d *models.Delivery) map[string]*string {
+	return map[string]*string{
+		"name":    &d.Name,
+		"phone":   &d.Phone,
+		"address": &d.Address,
+		"email":   &d.Email,
+	}
+}
+
+// sealPII encrypts the personal data of the order.
+func (uc *OrderUseCase) sealPII(order models.Order) (models.Order, error) {
+	if uc.piiCipher == nil {
+		return order, nil
+	}
+
+	d, err := uc.sealDelivery(order.Delivery)
+	if err != nil {
+		return models.Order{}, err
+	}
+	order.Delivery = d
+
+	return order, nil
+}
+
+// openPII decrypts the personal data of the order.
+func (uc *OrderUseCase) openPII(order models.Order) (models.Order, error) {
+	if uc.piiCipher == nil {
+		return order, nil
+	}
+
+	for field, v := range piiFields(&order.Delivery) {
+		pt, err := uc.piiCipher.Decrypt(field, *v)
+		if err != nil {
+			return models.Order{}, fmt.Errorf("decrypt %s: %w", field, err)
+		}
+		*v = pt
+	}
+
+	return order, nil
+}
+
+// sealDelivery encrypts the personal data fields of the delivery.
+func (uc *OrderUseCase) sealDelivery(d models.Delivery) (models.Delivery, error) {
+	for field, v := range piiFields(&d) {
+		ct, err := uc.piiCipher.Encrypt(field, *v)
+		if err != nil {
+			return models.Delivery{}, fmt.Errorf("encrypt %s: %w", field, err)
+		}
+		*v = ct
+	}
+
+	return d, nil
+}
+
+// RotatePIIKeys re-encrypts with the active key every stored delivery that is
+// plaintext or encrypted with an older key, batch by batch. Orders changed
+// since their batch was read are skipped, so a concurrent update or erasure
+// is not reverted. Rotated orders are evicted from the cache. It returns the
+// number of re-encrypted deliveries.
+func (uc *OrderUseCase) RotatePIIKeys(ctx context.Context, batchSize int) (int, error) {
+	const op = "usecase.RotatePIIKeys"
+
+	if uc.piiCipher == nil {
+		return 0, nil
+	}
+
+	repo, ok := uc.orderRepo.(DeliveryPIIRepository)
+	if !ok {
+		return 0, fmt.Errorf("%s: order repository does not support key rotation", op)
+	}
+
+	var rotated int
+	var after string
+	for {
+		if err := ctx.Err(); err != nil {
+			return rotated, err
+		}
+
+		records, err := repo.ListDeliveries(after, batchSize)
+		if err != nil {
+			return rotated, fmt.Errorf("%s: list deliveries: %w", op, err)
+		}
+		if len(records) == 0 {
+			return rotated, nil
+		}
+
+		for _, rec := range records {
+			after = rec.OrderUID
+
+			if !uc.needsRotation(rec.Delivery) {
+				continue
+			}
+
+			order, err := uc.openPII(models.Order{Delivery: rec.Delivery})
+			if err != nil {
+				return rotated, fmt.Errorf("%s: order %s: %w", op, rec.OrderUID, err)
+			}
+			d, err := uc.sealDelivery(order.Delivery)
+			if err != nil {
+				return rotated, fmt.Errorf("%s: order %s: %w", op, rec.OrderUID, err)
+			}
+			err = repo.UpdateDeliveryPII(models.Order{OrderUID: rec.OrderUID, Delivery: d, UpdatedAt: rec.UpdatedAt}, rec.Version)
+			if errors.Is(err, models.ErrVersionConflict) {
+				// changed since it was read, the writer sealed it with the active key
+				continue
+			}
+			if err != nil {
+				return rotated, fmt.Errorf("%s: order %s: %w", op, rec.OrderUID, err)
+			}
+
+			rotated++
+
+			if err := uc.InvalidateOrder(ctx, rec.OrderUID); err != nil {
+				return rotated, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+}
+
+// needsRotation reports whether any personal data field of the delivery must be re-encrypted.
+func (uc *OrderUseCase) needsRotation(d models.Delivery) bool {
+	for _, v := range piiFields(&d) {
+		if uc.piiCipher.NeedsRotation(*v) {
+			return true
+		}
+	}
+	return false
+}
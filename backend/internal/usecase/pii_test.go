@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"WB/internal/lib/pii"
+	"WB/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDeliveryRepo struct {
+	mockOrderRepo
+}
+
+func (m *mockDeliveryRepo) ListDeliveries(afterUID string, limit int) ([]models.DeliveryRecord, error) {
+	args := m.Called(afterUID, limit)
+	return args.Get(0).([]models.DeliveryRecord), args.Error(1)
+}
+
+func (m *mockDeliveryRepo) UpdateDeliveryPII(order models.Order, version int) error {
+	args := m.Called(order, version)
+	return args.Error(0)
+}
+
+// deliveryOf matches an order with the UID whose delivery passes check.
+func deliveryOf(orderUID string, check func(d models.Delivery) bool) any {
+	return mock.MatchedBy(func(order models.Order) bool {
+		return order.OrderUID == orderUID && check(order.Delivery)
+	})
+}
+
+func testKeyring(t *testing.T, active string, ids ...string) *pii.Keyring {
+	t.Helper()
+
+	keys := make(map[string][]byte, len(ids))
+	for i, id := range ids {
+		keys[id] = []byte(strings.Repeat(string(rune('a'+i)), 32))
+	}
+
+	k, err := pii.NewKeyring(active, keys)
+	require.NoError(t, err)
+	return k
+}
+
+func isEncrypted(d models.Delivery) bool {
+	for _, v := range []string{d.Name, d.Phone, d.Address, d.Email} {
+		if !strings.HasPrefix(v, "enc:") {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHandleMessage_EncryptsPII(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	order := models.Order{
+		OrderUID: "pii-order",
+		Delivery: models.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: "test@gmail.com", City: "Kiryat Mozkin"},
+	}
+	data, _ := json.Marshal(order)
+
+	mockRepo.
+		On("GetOrder", "pii-order").
+		Return(models.Order{}, errors.New("not found")).
+		Once()
+
+	mockRepo.
+		On("NewOrder", mock.MatchedBy(func(o models.Order) bool {
+			return isEncrypted(o.Delivery) && o.Delivery.City == "Kiryat Mozkin"
+		})).
+		Return(nil).
+		Once()
+
+	mockCache.
+		On("SetOrder", ctx, "pii-order", mock.MatchedBy(func(b []byte) bool {
+			var cached models.Order
+			return json.Unmarshal(b, &cached) == nil && isEncrypted(cached.Delivery)
+		}), mock.Anything).
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithPIICipher(testKeyring(t, "k1", "k1")))
+
+	err := uc.HandleMessage(ctx, data, nil)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestGetOrder_DecryptsPII(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	keyring := testKeyring(t, "k1", "k1")
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithPIICipher(keyring))
+
+	want := models.Order{
+		OrderUID: "pii-order",
+		Delivery: models.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: "test@gmail.com"},
+	}
+	sealed, err := uc.sealPII(want)
+	require.NoError(t, err)
+	sealedJSON, _ := json.Marshal(sealed)
+
+	mockCache.
+		On("GetOrder", ctx, "pii-order").
+		Return(sealedJSON, nil).
+		Once()
+
+	result, err := uc.GetOrder(ctx, "pii-order")
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, result)
+}
+
+func TestRotatePIIKeys(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockDeliveryRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	plain := models.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: "test@gmail.com"}
+
+	old := NewOrderUseCase(mockRepo, mockCache, mockProd, WithPIICipher(testKeyring(t, "k1", "k1")))
+	oldSealed, err := old.sealDelivery(plain)
+	require.NoError(t, err)
+
+	keyring := testKeyring(t, "k2", "k1", "k2")
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithPIICipher(keyring))
+	current, err := uc.sealDelivery(plain)
+	require.NoError(t, err)
+
+	mockRepo.
+		On("ListDeliveries", "", 2).
+		Return([]models.DeliveryRecord{{OrderUID: "a-legacy", Delivery: plain, Version: 3}, {OrderUID: "b-old", Delivery: oldSealed, Version: 3}}, nil).
+		Once()
+	mockRepo.
+		On("ListDeliveries", "b-old", 2).
+		Return([]models.DeliveryRecord{{OrderUID: "c-current", Delivery: current}}, nil).
+		Once()
+	mockRepo.
+		On("ListDeliveries", "c-current", 2).
+		Return([]models.DeliveryRecord{}, nil).
+		Once()
+
+	for _, uid := range []string{"a-legacy", "b-old"} {
+		mockRepo.
+			On("UpdateDeliveryPII", deliveryOf(uid, func(d models.Delivery) bool {
+				opened, err := uc.openPII(models.Order{Delivery: d})
+				return err == nil && !uc.needsRotation(d) && opened.Delivery == plain
+			}), 3).
+			Return(nil).
+			Once()
+		mockCache.
+			On("DeleteOrder", ctx, uid).
+			Return(nil).
+			Once()
+	}
+
+	rotated, err := uc.RotatePIIKeys(ctx, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rotated)
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateDeliveryPII", deliveryOf("c-current", anyDelivery), mock.Anything)
+}
+
+func TestRotatePIIKeys_SkipsChangedOrder(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockDeliveryRepo)
+	mockCache := new(mockCacheRepo)
+
+	plain := models.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: "test@gmail.com"}
+	updatedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithPIICipher(testKeyring(t, "k1", "k1")))
+
+	mockRepo.
+		On("ListDeliveries", "", 10).
+		Return([]models.DeliveryRecord{
+			{OrderUID: "a-erased", Delivery: plain, Version: 1},
+			{OrderUID: "b-legacy", Delivery: plain, Version: 2, UpdatedAt: updatedAt},
+		}, nil).
+		Once()
+	mockRepo.On("ListDeliveries", "b-legacy", 10).Return([]models.DeliveryRecord{}, nil).Once()
+	mockRepo.On("UpdateDeliveryPII", deliveryOf("a-erased", isEncrypted), 1).Return(models.ErrVersionConflict).Once()
+	mockRepo.
+		On("UpdateDeliveryPII", mock.MatchedBy(func(order models.Order) bool {
+			return order.OrderUID == "b-legacy" && isEncrypted(order.Delivery) && order.UpdatedAt.Equal(updatedAt)
+		}), 2).
+		Return(nil).
+		Once()
+	mockCache.On("DeleteOrder", ctx, "b-legacy").Return(nil).Once()
+
+	rotated, err := uc.RotatePIIKeys(ctx, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rotated)
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
@@ -0,0 +1,274 @@
+package usecase
+
+import (
+	"WB/internal/models"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Erasure modes of customer personal data.
+const (
+	// ErasureModePseudonymize replaces personal data with stable pseudonyms,
+	// so orders of the same customer can still be linked together.
+	ErasureModePseudonymize = "pseudonymize"
+	// ErasureModeErase replaces personal data with erasedValue.
+	ErasureModeErase = "erase"
+)
+
+// erasedValue replaces erased personal data; it is not empty, so the
+// erased orders still pass validation.
+const erasedValue = "erased"
+
+// ErrUnknownErasureMode is returned for an erasure mode other than ErasureMode*.
+var ErrUnknownErasureMode = errors.New("unknown erasure mode")
+
+// errNotErased marks the failures of an erasure before the order is changed.
+var errNotErased = errors.New("order not erased")
+
+// eraseAttempts bounds the attempts to erase an order that is changed concurrently.
+const eraseAttempts = 3
+
+// CustomerRepository finds the orders of a customer.
+type CustomerRepository interface {
+	ListCustomerOrders(customerID string) ([]string, error)
+}
+
+// CustomerRefundRepository finds the cancellations and refunds of the orders of a customer.
+type CustomerRefundRepository interface {
+	ListCustomerRefunds(customerID string) ([]models.Refund, error)
+}
+
+// WithPseudonymSecret sets the key pseudonyms of erased personal data are derived with.
+func WithPseudonymSecret(secret []byte) Option {
+	return func(uc *OrderUseCase) {
+		uc.pseudonymSecret = secret
+	}
+}
+
+// ExportCustomerData returns every order of the customer with decrypted personal
+// data, and the cancellations and refunds of the orders with their reasons.
+// Every exported order is recorded in the audit log.
+func (uc *OrderUseCase) ExportCustomerData(ctx context.Context, customerID string) (models.CustomerExport, error) {
+	const op = "usecase.ExportCustomerData"
+
+	repo, err := uc.customerRepo()
+	if err != nil {
+		return models.CustomerExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	uids, err := repo.ListCustomerOrders(customerID)
+	if err != nil {
+		return models.CustomerExport{}, fmt.Errorf("%s: list orders: %w", op, err)
+	}
+
+	refundRepo, ok := repo.(CustomerRefundRepository)
+	if !ok {
+		return models.CustomerExport{}, fmt.Errorf("%s: order repository does not support refund lookups", op)
+	}
+	refunds, err := refundRepo.ListCustomerRefunds(customerID)
+	if err != nil {
+		return models.CustomerExport{}, fmt.Errorf("%s: list refunds: %w", op, err)
+	}
+
+	export := models.CustomerExport{
+		CustomerID: customerID,
+		ExportedAt: time.Now().UTC(),
+		Orders:     make([]models.Order, 0, len(uids)),
+		Refunds:    refunds,
+	}
+	recs := make([]models.AuditRecord, 0, len(uids))
+	for _, uid := range uids {
+		order, err := uc.orderRepo.GetOrder(uid)
+		if err != nil {
+			return models.CustomerExport{}, fmt.Errorf("%s: get order %s: %w", op, uid, err)
+		}
+		order, err = uc.openPII(order)
+		if err != nil {
+			return models.CustomerExport{}, fmt.Errorf("%s: order %s: %w", op, uid, err)
+		}
+		export.Orders = append(export.Orders, order)
+
+		rec, err := orderAudit(ActionPrivacyExport, customerResource(customerID), uid, nil, nil, nil)
+		if err != nil {
+			return models.CustomerExport{}, fmt.Errorf("%s: %w", op, err)
+		}
+		recs = append(recs, rec)
+	}
+
+	recs, err = requestRecords(recs, ActionPrivacyExport, customerID, nil)
+	if err != nil {
+		return models.CustomerExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := uc.audit(ctx, recs...); err != nil {
+		return models.CustomerExport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return export, nil
+}
+
+// EraseCustomerData pseudonymizes or erases the delivery personal data of every
+// order of the customer. Payments and items are kept. Changed orders are evicted
+// from the cache, and every change is recorded in the audit log, even if the
+// request fails midway.
+func (uc *OrderUseCase) EraseCustomerData(ctx context.Context, customerID, mode string) (models.ErasureResult, error) {
+	const op = "usecase.EraseCustomerData"
+
+	if mode != ErasureModePseudonymize && mode != ErasureModeErase {
+		return models.ErasureResult{}, fmt.Errorf("%s: %q: %w", op, mode, ErrUnknownErasureMode)
+	}
+	if mode == ErasureModePseudonymize && len(uc.pseudonymSecret) == 0 {
+		return models.ErasureResult{}, fmt.Errorf("%s: pseudonym secret is not configured", op)
+	}
+
+	repo, err := uc.customerRepo()
+	if err != nil {
+		return models.ErasureResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	deliveries, ok := uc.orderRepo.(DeliveryPIIRepository)
+	if !ok {
+		return models.ErasureResult{}, fmt.Errorf("%s: order repository does not support erasure", op)
+	}
+
+	uids, err := repo.ListCustomerOrders(customerID)
+	if err != nil {
+		return models.ErasureResult{}, fmt.Errorf("%s: list orders: %w", op, err)
+	}
+
+	result := models.ErasureResult{CustomerID: customerID, Mode: mode, OrderUIDs: []string{}}
+	recs, eraseErr := uc.eraseDeliveries(ctx, deliveries, customerID, mode, uids, &result)
+
+	recs, err = requestRecords(recs, ActionPrivacyErase, customerID, map[string]any{"mode": mode})
+	if err == nil {
+		err = uc.audit(ctx, recs...)
+	}
+	if err != nil {
+		return models.ErasureResult{}, fmt.Errorf("%s: %w", op, errors.Join(eraseErr, err))
+	}
+	if eraseErr != nil {
+		return models.ErasureResult{}, fmt.Errorf("%s: %w", op, eraseErr)
+	}
+
+	return result, nil
+}
+
+// eraseDeliveries replaces the personal data of the orders, records every changed
+// order in result and returns the audit records of the attempted changes.
+func (uc *OrderUseCase) eraseDeliveries(ctx context.Context, repo DeliveryPIIRepository, customerID, mode string, uids []string, result *models.ErasureResult) ([]models.AuditRecord, error) {
+	var replacement models.Delivery
+	for field, v := range piiFields(&replacement) {
+		*v = erasedValue
+		if mode == ErasureModePseudonymize {
+			*v = uc.pseudonym(customerID, field)
+		}
+	}
+	replacement.Email += "@example.invalid"
+
+	var recs []models.AuditRecord
+	for _, uid := range uids {
+		before, after, updateErr := uc.eraseDelivery(repo, uid, replacement)
+		if errors.Is(updateErr, errNotErased) {
+			return recs, updateErr
+		}
+
+		details := map[string]any{"mode": mode}
+		if updateErr != nil {
+			details["error"] = updateErr.Error()
+		}
+		rec, err := orderAudit(ActionPrivacyErase, customerResource(customerID), uid,
+			models.Order{Delivery: before}, models.Order{Delivery: after}, details)
+		if err != nil {
+			return recs, err
+		}
+		recs = append(recs, rec)
+
+		if updateErr != nil {
+			return recs, fmt.Errorf("update order %s: %w", uid, updateErr)
+		}
+		result.OrderUIDs = append(result.OrderUIDs, uid)
+
+		if err := uc.InvalidateOrder(ctx, uid); err != nil {
+			return recs, err
+		}
+	}
+
+	return recs, nil
+}
+
+// eraseDelivery replaces the personal data of the stored order delivery and
+// returns the delivery before and after. An order changed concurrently is read
+// again, so the erasure neither reverts the change nor is reverted by it.
+// Errors before the update is attempted wrap errNotErased.
+func (uc *OrderUseCase) eraseDelivery(repo DeliveryPIIRepository, uid string, replacement models.Delivery) (before, after models.Delivery, err error) {
+	for attempt := 1; ; attempt++ {
+		order, err := uc.orderRepo.GetOrder(uid)
+		if err != nil {
+			return before, after, fmt.Errorf("%w: get order %s: %w", errNotErased, uid, err)
+		}
+		order, err = uc.openPII(order)
+		if err != nil {
+			return before, after, fmt.Errorf("%w: order %s: %w", errNotErased, uid, err)
+		}
+
+		before, after = order.Delivery, order.Delivery
+		after.Name, after.Phone, after.Address, after.Email = replacement.Name, replacement.Phone, replacement.Address, replacement.Email
+
+		stored := order
+		stored.Delivery = after
+		stored.UpdatedAt = time.Now().UTC()
+		if stored, err = uc.sealPII(stored); err != nil {
+			return before, after, fmt.Errorf("%w: order %s: %w", errNotErased, uid, err)
+		}
+
+		err = repo.UpdateDeliveryPII(stored, order.Version)
+		if errors.Is(err, models.ErrVersionConflict) && attempt < eraseAttempts {
+			continue
+		}
+		return before, after, err
+	}
+}
+
+// requestRecords returns the per-order audit records of a data subject request,
+// or a single record of the request itself if it touched no orders.
+func requestRecords(recs []models.AuditRecord, action, customerID string, details map[string]any) ([]models.AuditRecord, error) {
+	if len(recs) > 0 {
+		return recs, nil
+	}
+
+	rec, err := orderAudit(action, customerResource(customerID), "", nil, nil, details)
+	if err != nil {
+		return nil, err
+	}
+	return []models.AuditRecord{rec}, nil
+}
+
+func customerResource(customerID string) string {
+	return "customer:" + customerID
+}
+
+// pseudonym derives a stable replacement for a personal data field of the customer.
+// Without the secret it can't be linked back to the customer ID.
+func (uc *OrderUseCase) pseudonym(customerID, field string) string {
+	mac := hmac.New(sha256.New, uc.pseudonymSecret)
+	mac.Write([]byte(customerID + ":" + field))
+	return "anon-" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// customerRepo returns the order repository as a CustomerRepository
+// if data subject requests can be served.
+func (uc *OrderUseCase) customerRepo() (CustomerRepository, error) {
+	if uc.auditLog == nil {
+		return nil, errors.New("audit log is not configured")
+	}
+
+	repo, ok := uc.orderRepo.(CustomerRepository)
+	if !ok {
+		return nil, errors.New("order repository does not support customer lookups")
+	}
+
+	return repo, nil
+}
@@ -0,0 +1,266 @@
+package usecase
+
+import (
+	"WB/internal/lib/audit"
+	"WB/internal/lib/auth"
+	"WB/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCustomerRepo struct {
+	mockDeliveryRepo
+}
+
+func (m *mockCustomerRepo) ListCustomerOrders(customerID string) ([]string, error) {
+	args := m.Called(customerID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockCustomerRepo) ListCustomerRefunds(customerID string) ([]models.Refund, error) {
+	args := m.Called(customerID)
+	refunds, _ := args.Get(0).([]models.Refund)
+	return refunds, args.Error(1)
+}
+
+func adminContext() context.Context {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "local-dev", Method: auth.MethodAPIKey, Roles: []string{"admin"}})
+	return audit.WithRequestID(ctx, "req-1")
+}
+
+// auditRecords matches a batch of audit records of the admin caller for customer "test".
+func auditRecords(action string, check func(recs []models.AuditRecord) bool) any {
+	return mock.MatchedBy(func(recs []models.AuditRecord) bool {
+		for _, rec := range recs {
+			if rec.Action != action || rec.Actor != "api_key:local-dev" || rec.RequestID != "req-1" || rec.Resource != "customer:test" {
+				return false
+			}
+		}
+		return check(recs)
+	})
+}
+
+func details(rec models.AuditRecord) map[string]any {
+	var d map[string]any
+	_ = json.Unmarshal([]byte(rec.Details), &d)
+	return d
+}
+
+func TestExportCustomerData(t *testing.T) {
+	ctx := adminContext()
+	mockRepo := new(mockCustomerRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+	mockAudit := new(mockAuditLog)
+
+	keyring := testKeyring(t, "k1", "k1")
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithAuditLog(mockAudit), WithPIICipher(keyring))
+
+	plain := models.Order{OrderUID: "order-1", CustomerID: "test", Delivery: models.Delivery{Name: "Test Testov", Email: "test@gmail.com"}}
+	sealed, err := uc.sealPII(plain)
+	require.NoError(t, err)
+
+	refunds := []models.Refund{{ID: 7, OrderUID: "order-1", Kind: models.RefundKindCancel, ChrtIDs: []int{1}, Amount: 300, Reason: "changed mind"}}
+	mockRepo.On("ListCustomerOrders", "test").Return([]string{"order-1"}, nil).Once()
+	mockRepo.On("ListCustomerRefunds", "test").Return(refunds, nil).Once()
+	mockRepo.On("GetOrder", "order-1").Return(sealed, nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, auditRecords(ActionPrivacyExport, func(recs []models.AuditRecord) bool {
+			return len(recs) == 1 && recs[0].OrderUID == "order-1"
+		})).
+		Return(nil, nil).
+		Once()
+
+	export, err := uc.ExportCustomerData(ctx, "test")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "test", export.CustomerID)
+	assert.Equal(t, []models.Order{plain}, export.Orders)
+	assert.Equal(t, refunds, export.Refunds)
+	mockRepo.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestExportCustomerData_NoOrders(t *testing.T) {
+	ctx := adminContext()
+	mockRepo := new(mockCustomerRepo)
+	mockAudit := new(mockAuditLog)
+
+	mockRepo.On("ListCustomerOrders", "test").Return([]string{}, nil).Once()
+	mockRepo.On("ListCustomerRefunds", "test").Return([]models.Refund{}, nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, auditRecords(ActionPrivacyExport, func(recs []models.AuditRecord) bool {
+			return len(recs) == 1 && recs[0].OrderUID == ""
+		})).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker), WithAuditLog(mockAudit))
+
+	export, err := uc.ExportCustomerData(ctx, "test")
+
+	assert.NoError(t, err)
+	assert.Empty(t, export.Orders)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestExportCustomerData_NoAuditLog(t *testing.T) {
+	mockRepo := new(mockCustomerRepo)
+	uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker))
+
+	_, err := uc.ExportCustomerData(adminContext(), "test")
+
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "ListCustomerOrders", mock.Anything)
+}
+
+func TestEraseCustomerData(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		wantName  func(string) bool
+		wantEmail func(string) bool
+	}{
+		{
+			name:      "pseudonymize",
+			mode:      ErasureModePseudonymize,
+			wantName:  func(s string) bool { return strings.HasPrefix(s, "anon-") },
+			wantEmail: func(s string) bool { return strings.HasPrefix(s, "anon-") && strings.HasSuffix(s, "@example.invalid") },
+		},
+		{
+			name:      "erase",
+			mode:      ErasureModeErase,
+			wantName:  func(s string) bool { return s == "erased" },
+			wantEmail: func(s string) bool { return s == "erased@example.invalid" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := adminContext()
+			mockRepo := new(mockCustomerRepo)
+			mockCache := new(mockCacheRepo)
+			mockProd := new(mockMessageBroker)
+			mockAudit := new(mockAuditLog)
+
+			mockRepo.On("ListCustomerOrders", "test").Return([]string{"order-1", "order-2"}, nil).Once()
+			for _, uid := range []string{"order-1", "order-2"} {
+				mockRepo.
+					On("GetOrder", uid).
+					Return(models.Order{OrderUID: uid, Version: 2, Delivery: models.Delivery{Name: "Test Testov", Email: "test@gmail.com", City: "Kiryat Mozkin"}}, nil).
+					Once()
+				mockRepo.
+					On("UpdateDeliveryPII", deliveryOf(uid, func(d models.Delivery) bool {
+						return tt.wantName(d.Name) && tt.wantEmail(d.Email) && d.City == "Kiryat Mozkin"
+					}), 2).
+					Return(nil).
+					Once()
+				mockCache.On("DeleteOrder", ctx, uid).Return(nil).Once()
+			}
+			mockAudit.
+				On("AppendAudit", ctx, auditRecords(ActionPrivacyErase, func(recs []models.AuditRecord) bool {
+					return len(recs) == 2 &&
+						recs[0].OrderUID == "order-1" &&
+						details(recs[0])["mode"] == tt.mode &&
+						strings.Contains(recs[0].Diff, `"delivery.name":{"before":"T***"`) &&
+						!strings.Contains(recs[0].Diff, "Testov")
+				})).
+				Return(nil, nil).
+				Once()
+
+			uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithAuditLog(mockAudit), WithPseudonymSecret([]byte("secret")))
+
+			result, err := uc.EraseCustomerData(ctx, "test", tt.mode)
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"order-1", "order-2"}, result.OrderUIDs)
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+			mockAudit.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEraseCustomerData_PartialFailureIsAudited(t *testing.T) {
+	ctx := adminContext()
+	mockRepo := new(mockCustomerRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+	mockAudit := new(mockAuditLog)
+
+	mockRepo.On("ListCustomerOrders", "test").Return([]string{"order-1", "order-2"}, nil).Once()
+	mockRepo.On("GetOrder", "order-1").Return(models.Order{OrderUID: "order-1"}, nil).Once()
+	mockRepo.On("GetOrder", "order-2").Return(models.Order{OrderUID: "order-2"}, nil).Once()
+	mockRepo.On("UpdateDeliveryPII", deliveryOf("order-1", anyDelivery), 0).Return(nil).Once()
+	mockRepo.On("UpdateDeliveryPII", deliveryOf("order-2", anyDelivery), 0).Return(errors.New("db down")).Once()
+	mockCache.On("DeleteOrder", ctx, "order-1").Return(nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, auditRecords(ActionPrivacyErase, func(recs []models.AuditRecord) bool {
+			return len(recs) == 2 && details(recs[0])["error"] == nil && details(recs[1])["error"] == "db down"
+		})).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithAuditLog(mockAudit))
+
+	_, err := uc.EraseCustomerData(ctx, "test", ErasureModeErase)
+
+	assert.Error(t, err)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestEraseCustomerData_ConcurrentUpdate(t *testing.T) {
+	ctx := adminContext()
+	mockRepo := new(mockCustomerRepo)
+	mockCache := new(mockCacheRepo)
+	mockAudit := new(mockAuditLog)
+
+	mockRepo.On("ListCustomerOrders", "test").Return([]string{"order-1"}, nil).Once()
+	mockRepo.
+		On("GetOrder", "order-1").
+		Return(models.Order{OrderUID: "order-1", Version: 2, Delivery: models.Delivery{City: "Kiryat Mozkin"}}, nil).
+		Once()
+	mockRepo.
+		On("GetOrder", "order-1").
+		Return(models.Order{OrderUID: "order-1", Version: 3, Delivery: models.Delivery{City: "Moscow"}}, nil).
+		Once()
+	mockRepo.On("UpdateDeliveryPII", deliveryOf("order-1", anyDelivery), 2).Return(models.ErrVersionConflict).Once()
+	mockRepo.
+		On("UpdateDeliveryPII", deliveryOf("order-1", func(d models.Delivery) bool {
+			return d.Name == "erased" && d.City == "Moscow"
+		}), 3).
+		Return(nil).
+		Once()
+	mockCache.On("DeleteOrder", ctx, "order-1").Return(nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, auditRecords(ActionPrivacyErase, func(recs []models.AuditRecord) bool {
+			return len(recs) == 1 && details(recs[0])["error"] == nil
+		})).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithAuditLog(mockAudit))
+
+	result, err := uc.EraseCustomerData(ctx, "test", ErasureModeErase)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"order-1"}, result.OrderUIDs)
+	mockRepo.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func anyDelivery(models.Delivery) bool { return true }
+
+func TestEraseCustomerData_UnknownMode(t *testing.T) {
+	uc := NewOrderUseCase(new(mockCustomerRepo), new(mockCacheRepo), new(mockMessageBroker), WithAuditLog(new(mockAuditLog)))
+
+	_, err := uc.EraseCustomerData(adminContext(), "test", "shred")
+
+	assert.ErrorIs(t, err, ErrUnknownErasureMode)
+}
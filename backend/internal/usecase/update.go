@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"WB/internal/lib/validator"
+	"WB/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ActionOrderUpdate is the audit log action of a changed order.
+const ActionOrderUpdate = "order.update"
+
+// OrderUpdater stores changed orders with optimistic concurrency control.
+type OrderUpdater interface {
+	// UpdateOrder stores the delivery details and the item statuses of the order
+	// if its stored version is still version, and increments the version.
+	UpdateOrder(order models.Order, version int) error
+}
+
+// UpdateOrder applies the patch to the order if it is still at the given version,
+// or at any version if version is 0. The patched fields must pass validation;
+// the rest of the order, such as erased personal data, is not checked again.
+// A stale version yields models.ErrVersionConflict. The caller must be allowed
+// to read the order with its personal data, otherwise models.ErrForbidden is returned.
+// The change is recorded in the audit log and the order is evicted from the caches.
+// If the eviction fails, the changed order is returned with models.ErrStaleCache.
+func (uc *OrderUseCase) UpdateOrder(ctx context.Context, orderUID string, version int, patch models.OrderPatch) (models.Order, error) {
+	const op = "usecase.UpdateOrder"
+
+	repo, ok := uc.orderRepo.(OrderUpdater)
+	if !ok {
+		return models.Order{}, fmt.Errorf("%s: order repository does not support updates", op)
+	}
+
+	if patch.Delivery == nil && len(patch.Items) == 0 {
+		return models.Order{}, fmt.Errorf("%s: empty patch: %w", op, models.ErrInvalidOrder)
+	}
+
+	before, masked, err := uc.loadForChange(ctx, orderUID, version)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if masked {
+		return models.Order{}, fmt.Errorf("%s: caller may not change personal data: %w", op, models.ErrForbidden)
+	}
+	version = before.Version
+
+	after, err := applyPatch(before, patch)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w: %w", op, models.ErrInvalidOrder, err)
+	}
+	if err := validator.ValidateOrderFields(&after, patchedFields(after, patch)...); err != nil {
+		return models.Order{}, fmt.Errorf("%s: validator: %w: %w", op, models.ErrInvalidOrder, err)
+	}
+	after.UpdatedAt = time.Now().UTC()
+
+	sealed, err := uc.sealPII(after)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: failed to encrypt personal data: %w", op, err)
+	}
+
+	if err := repo.UpdateOrder(sealed, version); err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+	after.Version = version + 1
+
+	if err := uc.auditOrder(ctx, ActionOrderUpdate, "", orderUID, before, after); err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, errors.Join(err, uc.evict(ctx, orderUID)))
+	}
+
+	// a stale entry would serve the old version and fail conditional requests
+	if err := uc.evict(ctx, orderUID); err != nil {
+		return after, fmt.Errorf("%s: %w: %w", op, models.ErrStaleCache, err)
+	}
+
+	return after, nil
+}
+
+// loadForChange reads the stored order the caller from ctx is about to change
+// and checks that it is still at version; version 0 matches any version.
+// masked reports whether the caller may not see the personal data of the order.
+func (uc *OrderUseCase) loadForChange(ctx context.Context, orderUID string, version int) (_ models.Order, masked bool, _ error) {
+	if err := uc.authenticate(ctx); err != nil {
+		return models.Order{}, false, err
+	}
+
+	// read past the cache, the version check must see the stored order
+	stored, err := uc.orderRepo.GetOrder(orderUID)
+	if err != nil {
+		return models.Order{}, false, err
+	}
+
+	order, err := uc.openPII(stored)
+	if err != nil {
+		return models.Order{}, false, err
+	}
+
+	_, masked, err = uc.authorize(ctx, order)
+	if err != nil {
+		return models.Order{}, false, err
+	}
+
+	if version != 0 && version != order.Version {
+		return models.Order{}, false, fmt.Errorf("version %d, stored %d: %w", version, order.Version, models.ErrVersionConflict)
+	}
+
+	return order, masked, nil
+}
+
+// patchedFields returns the validator names of the order fields the patch sets.
+func patchedFields(order models.Order, patch models.OrderPatch) []string {
+	var fields []string
+	if d := patch.Delivery; d != nil {
+		for name, v := range map[string]*string{
+			"Name": d.Name, "Phone": d.Phone, "Zip": d.Zip, "City": d.City,
+			"Address": d.Address, "Region": d.Region, "Email": d.Email,
+		} {
+			if v != nil {
+				fields = append(fields, "Delivery."+name)
+			}
+		}
+	}
+	for i, it := range order.Items {
+		if slices.ContainsFunc(patch.Items, func(s models.ItemStatus) bool { return s.ChrtID == it.ChrtID }) {
+			fields = append(fields, fmt.Sprintf("Items[%d].Status", i))
+		}
+	}
+	return fields
+}
+
+// applyPatch returns a copy of the order with the patch applied.
+func applyPatch(order models.Order, patch models.OrderPatch) (models.Order, error) {
+	if d := patch.Delivery; d != nil {
+		for dst, src := range map[*string]*string{
+			&order.Delivery.Name:    d.Name,
+			&order.Delivery.Phone:   d.Phone,
+			&order.Delivery.Zip:     d.Zip,
+			&order.Delivery.City:    d.City,
+			&order.Delivery.Address: d.Address,
+			&order.Delivery.Region:  d.Region,
+			&order.Delivery.Email:   d.Email,
+		} {
+			if src != nil {
+				*dst = *src
+			}
+		}
+	}
+
+	if len(patch.Items) == 0 {
+		return order, nil
+	}
+
+	order.Items = append([]models.Item(nil), order.Items...)
+	for _, s := range patch.Items {
+		if isClosed(s.Status) {
+			return models.Order{}, fmt.Errorf("item %d: status %d is set by cancellation or refund", s.ChrtID, s.Status)
+		}
+		found := false
+		for i := range order.Items {
+			if order.Items[i].ChrtID != s.ChrtID {
+				continue
+			}
+			if isClosed(order.Items[i].Status) {
+				return models.Order{}, fmt.Errorf("item %d is canceled or refunded", s.ChrtID)
+			}
+			order.Items[i].Status = s.Status
+			found = true
+		}
+		if !found {
+			return models.Order{}, fmt.Errorf("unknown item chrt_id %d", s.ChrtID)
+		}
+	}
+
+	return order, nil
+}
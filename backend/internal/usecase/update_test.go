@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"WB/internal/lib/audit"
+	"WB/internal/lib/auth"
+	"WB/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockOrderUpdater struct {
+	mockOrderRepo
+}
+
+func (m *mockOrderUpdater) UpdateOrder(order models.Order, version int) error {
+	args := m.Called(order, version)
+	return args.Error(0)
+}
+
+func storedOrder() models.Order {
+	order := validOrder()
+	order.Version = 3
+	return order
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestUpdateOrder_Success(t *testing.T) {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "support-1", Method: auth.MethodJWT})
+	mockRepo := new(mockOrderUpdater)
+	mockCache := new(mockCacheRepo)
+	mockAudit := new(mockAuditLog)
+
+	mockRepo.On("GetOrder", "audit-order").Return(storedOrder(), nil).Once()
+	mockRepo.
+		On("UpdateOrder", mock.MatchedBy(func(o models.Order) bool {
+			return o.Delivery.City == "Moscow" && o.Delivery.Name == "Test Testov" && o.Items[0].Status == 300
+		}), 3).
+		Return(nil).
+		Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(nil).Once()
+	mockAudit.
+		On("AppendAudit", ctx, oneRecord(func(rec models.AuditRecord) bool {
+			var diff map[string]audit.Change
+			return rec.Action == ActionOrderUpdate &&
+				rec.Actor == "jwt:support-1" &&
+				json.Unmarshal([]byte(rec.Diff), &diff) == nil &&
+				diff["delivery.city"].After == "Moscow" &&
+				diff["items.0.status"].Before == float64(202)
+		})).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithAuditLog(mockAudit))
+
+	order, err := uc.UpdateOrder(ctx, "audit-order", 3, models.OrderPatch{
+		Delivery: &models.DeliveryPatch{City: ptr("Moscow")},
+		Items:    []models.ItemStatus{{ChrtID: 9934930, Status: 300}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, order.Version)
+	assert.Equal(t, "Moscow", order.Delivery.City)
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestUpdateOrder_Rejected(t *testing.T) {
+	city := &models.DeliveryPatch{City: ptr("Moscow")}
+
+	tests := []struct {
+		name    string
+		version int
+		patch   models.OrderPatch
+		wantErr error
+	}{
+		{name: "stale version", version: 2, patch: models.OrderPatch{Delivery: city}, wantErr: models.ErrVersionConflict},
+		{name: "empty patch", version: 3, wantErr: models.ErrInvalidOrder},
+		{name: "invalid email", version: 3, patch: models.OrderPatch{Delivery: &models.DeliveryPatch{Email: ptr("nope")}}, wantErr: models.ErrInvalidOrder},
+		{name: "empty city", version: 3, patch: models.OrderPatch{Delivery: &models.DeliveryPatch{City: ptr("")}}, wantErr: models.ErrInvalidOrder},
+		{name: "unknown item", version: 0, patch: models.OrderPatch{Items: []models.ItemStatus{{ChrtID: 1}}}, wantErr: models.ErrInvalidOrder},
+		{name: "negative status", version: 0, patch: models.OrderPatch{Items: []models.ItemStatus{{ChrtID: 9934930, Status: -1}}}, wantErr: models.ErrInvalidOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockOrderUpdater)
+			mockRepo.On("GetOrder", "audit-order").Return(storedOrder(), nil).Maybe()
+
+			uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker))
+
+			_, err := uc.UpdateOrder(context.Background(), "audit-order", tt.version, tt.patch)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			mockRepo.AssertNotCalled(t, "UpdateOrder", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateOrder_ConcurrentChange(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderUpdater)
+	mockCache := new(mockCacheRepo)
+
+	mockRepo.On("GetOrder", "audit-order").Return(storedOrder(), nil).Once()
+	mockRepo.On("UpdateOrder", mock.Anything, 3).Return(models.ErrVersionConflict).Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker))
+
+	_, err := uc.UpdateOrder(ctx, "audit-order", 0, models.OrderPatch{Delivery: &models.DeliveryPatch{City: ptr("Moscow")}})
+
+	assert.ErrorIs(t, err, models.ErrVersionConflict)
+	mockCache.AssertNotCalled(t, "DeleteOrder", mock.Anything, mock.Anything)
+}
+
+func TestUpdateOrder_EvictionFailure(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderUpdater)
+	mockCache := new(mockCacheRepo)
+
+	mockRepo.On("GetOrder", "audit-order").Return(storedOrder(), nil).Once()
+	mockRepo.On("UpdateOrder", mock.Anything, 3).Return(nil).Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(errors.New("redis down")).Times(evictAttempts)
+	mockAudit := new(mockAuditLog)
+	mockAudit.
+		On("AppendAudit", ctx, oneRecord(func(rec models.AuditRecord) bool { return rec.Action == ActionOrderUpdate })).
+		Return(nil, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithAuditLog(mockAudit))
+
+	order, err := uc.UpdateOrder(ctx, "audit-order", 3, models.OrderPatch{Delivery: &models.DeliveryPatch{City: ptr("Moscow")}})
+
+	assert.ErrorIs(t, err, models.ErrStaleCache)
+	assert.ErrorContains(t, err, "redis down")
+	assert.Equal(t, 4, order.Version)
+	assert.Equal(t, "Moscow", order.Delivery.City)
+	mockCache.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestUpdateOrder_EvictionRetried(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderUpdater)
+	mockCache := new(mockCacheRepo)
+
+	mockRepo.On("GetOrder", "audit-order").Return(storedOrder(), nil).Once()
+	mockRepo.On("UpdateOrder", mock.Anything, 3).Return(nil).Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(errors.New("redis down")).Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(nil).Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker))
+
+	_, err := uc.UpdateOrder(ctx, "audit-order", 3, models.OrderPatch{Delivery: &models.DeliveryPatch{City: ptr("Moscow")}})
+
+	assert.NoError(t, err)
+	mockCache.AssertExpectations(t)
+}
+
+func TestUpdateOrder_ErasedDelivery(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderUpdater)
+	mockCache := new(mockCacheRepo)
+	erased := storedOrder()
+	erased.Delivery.Name, erased.Delivery.Phone, erased.Delivery.Address, erased.Delivery.Email = "", "", "", ""
+
+	mockRepo.On("GetOrder", "audit-order").Return(erased, nil).Once()
+	mockRepo.On("UpdateOrder", mock.Anything, 3).Return(nil).Once()
+	mockCache.On("DeleteOrder", ctx, "audit-order").Return(nil).Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker))
+
+	_, err := uc.UpdateOrder(ctx, "audit-order", 3, models.OrderPatch{Items: []models.ItemStatus{{ChrtID: 9934930, Status: 300}}})
+
+	assert.NoError(t, err, "fields outside the patch are not validated")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateOrder_MaskedCallerForbidden(t *testing.T) {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "s1", Roles: []string{"seller"}})
+	mockRepo := new(mockOrderUpdater)
+
+	mockRepo.On("GetOrder", "audit-order").Return(storedOrder(), nil).Once()
+
+	uc := NewOrderUseCase(mockRepo, new(mockCacheRepo), new(mockMessageBroker), WithPIIMasking([]string{"admin"}))
+
+	_, err := uc.UpdateOrder(ctx, "audit-order", 3, models.OrderPatch{Delivery: &models.DeliveryPatch{City: ptr("Moscow")}})
+
+	assert.ErrorIs(t, err, models.ErrForbidden)
+	mockRepo.AssertNotCalled(t, "UpdateOrder", mock.Anything, mock.Anything)
+}
+
+func TestUpdateOrder_NotSupported(t *testing.T) {
+	uc := NewOrderUseCase(new(mockOrderRepo), new(mockCacheRepo), new(mockMessageBroker))
+
+	_, err := uc.UpdateOrder(context.Background(), "audit-order", 1, models.OrderPatch{Delivery: &models.DeliveryPatch{}})
+
+	assert.Error(t, err)
+}
+
+func TestApplyPatch_ClosedItems(t *testing.T) {
+	_, err := applyPatch(twoItemOrder(202, models.ItemStatusCanceled), models.OrderPatch{Items: []models.ItemStatus{{ChrtID: 1, Status: models.ItemStatusCanceled}}})
+	assert.Error(t, err)
+
+	_, err = applyPatch(twoItemOrder(202, models.ItemStatusCanceled), models.OrderPatch{Items: []models.ItemStatus{{ChrtID: 2, Status: 202}}})
+	assert.Error(t, err)
+
+	_, err = applyPatch(twoItemOrder(202, 202), models.OrderPatch{Items: []models.ItemStatus{{ChrtID: 1, Status: models.ItemStatusDelivered}}})
+	assert.NoError(t, err)
+}
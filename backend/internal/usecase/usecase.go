@@ -5,11 +5,38 @@ package usecase
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"slices"
 	"time"
 
+	"WB/internal/lib/auth"
+	"WB/internal/lib/codec"
+	"WB/internal/lib/masking"
+	"WB/internal/lib/policy"
 	"WB/internal/lib/validator"
 	"WB/internal/models"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultCacheTTL is how long an order stays in the cache.
+	defaultCacheTTL = 24 * time.Hour
+	// defaultMissingTTL is how long an unknown order UID is remembered as not found.
+	defaultMissingTTL = time.Minute
+	// refreshTimeout bounds a background refresh of a stale cache entry.
+	refreshTimeout = 5 * time.Second
+	// loadCacheTimeout bounds the cache writes of a shared load, which outlive
+	// the request that started it.
+	loadCacheTimeout = 5 * time.Second
+	// evictAttempts and evictBackoff bound the retries of a failed eviction of a changed order.
+	evictAttempts = 3
+	evictBackoff  = 50 * time.Millisecond
+	// storeAuditAttempts and storeAuditBackoff bound the retries of a failed audit write of an order stored from Kafka.
+	storeAuditAttempts = 3
+	storeAuditBackoff  = 50 * time.Millisecond
 )
 
 // OrderRepository defines methods for persistent order storage.
@@ -25,6 +52,29 @@ type CacheRepository interface {
 	DeleteOrder(ctx context.Context, orderUID string) error
 }
 
+// StaleCacheRepository is implemented by caches with stale-while-revalidate support.
+// stale reports that the entry outlived its soft TTL and should be refreshed.
+type StaleCacheRepository interface {
+	GetOrderState(ctx context.Context, orderUID string) (data []byte, stale bool, err error)
+}
+
+// NegativeCacheRepository is implemented by caches that remember unknown order UIDs,
+// so repeated lookups of nonexistent orders do not reach the database.
+type NegativeCacheRepository interface {
+	IsOrderMissing(ctx context.Context, orderUID string) (bool, error)
+	SetOrderMissing(ctx context.Context, orderUID string, ttl time.Duration) error
+}
+
+// AccessPolicy decides whether a caller may read an order.
+type AccessPolicy interface {
+	Authorize(id auth.Identity, order models.Order) policy.Decision
+}
+
+// SignatureVerifier checks internal_signature of incoming orders.
+type SignatureVerifier interface {
+	Verify(order models.Order) error
+}
+
 // MessageBroker defines methods for sending messages to Kafka.
 type MessageBroker interface {
 	Send(ctx context.Context, key string, value []byte) error
@@ -35,33 +85,124 @@ type MessageBroker interface {
 type OrderUseCase struct {
 	orderRepo     OrderRepository
 	cacheRepo     CacheRepository
+	localCache    CacheRepository
 	messageBroker MessageBroker
+	eventBroker   MessageBroker
+	policy        AccessPolicy
+	verifier      SignatureVerifier
+	piiCipher     PIICipher
+	auditLog      AuditLog
+
+	// producerID identifies this service in the envelope of the sent orders.
+	producerID string
+	// codec encodes the sent orders, JSON if nil; codecs decode the received
+	// ones by content type, besides JSON.
+	codec  codec.Codec
+	codecs map[string]codec.Codec
+
+	// auditReads records reads of unmasked personal data.
+	auditReads      bool
+	pseudonymSecret []byte
+
+	// maskPII hides personal data from callers without one of revealRoles.
+	maskPII     bool
+	revealRoles []string
+
+	cacheTTL    time.Duration
+	cacheJitter time.Duration
+	missingTTL  time.Duration
+
+	// loads coalesces concurrent database fetches of the same order.
+	loads singleflight.Group
+}
+
+// Option configures optional OrderUseCase settings.
+type Option func(*OrderUseCase)
+
+// WithCachePolicy sets the cache TTL, the maximum random jitter added to it
+// and the TTL of negative cache entries.
+func WithCachePolicy(ttl, jitter, missingTTL time.Duration) Option {
+	return func(uc *OrderUseCase) {
+		uc.cacheTTL = ttl
+		uc.cacheJitter = jitter
+		uc.missingTTL = missingTTL
+	}
+}
+
+// WithLocalCache puts an in-process cache layer in front of the shared cache.
+// The layer is kept consistent across replicas through EvictLocal.
+func WithLocalCache(localCache CacheRepository) Option {
+	return func(uc *OrderUseCase) {
+		uc.localCache = localCache
+	}
+}
+
+// WithAccessPolicy enforces the policy on every order read. Reads without
+// an authenticated caller in the context are denied.
+func WithAccessPolicy(p AccessPolicy) Option {
+	return func(uc *OrderUseCase) {
+		uc.policy = p
+	}
+}
+
+// WithSignatureVerifier verifies orders received over HTTP and from Kafka.
+func WithSignatureVerifier(v SignatureVerifier) Option {
+	return func(uc *OrderUseCase) {
+		uc.verifier = v
+	}
+}
+
+// WithPIIMasking masks personal data in orders returned to callers
+// that have none of revealRoles, including unauthenticated ones.
+func WithPIIMasking(revealRoles []string) Option {
+	return func(uc *OrderUseCase) {
+		uc.maskPII = true
+		uc.revealRoles = revealRoles
+	}
 }
 
 // NewOrderUseCase creates a new instance of OrderUseCase with required dependencies.
-func NewOrderUseCase(orderRepo OrderRepository, cacheRepo CacheRepository, messageBroker MessageBroker) *OrderUseCase {
-	return &OrderUseCase{
+func NewOrderUseCase(orderRepo OrderRepository, cacheRepo CacheRepository, messageBroker MessageBroker, opts ...Option) *OrderUseCase {
+	uc := &OrderUseCase{
 		orderRepo:     orderRepo,
 		cacheRepo:     cacheRepo,
 		messageBroker: messageBroker,
+		cacheTTL:      defaultCacheTTL,
+		missingTTL:    defaultMissingTTL,
 	}
+
+	for _, opt := range opts {
+		opt(uc)
+	}
+
+	return uc
 }
 
-// CreateOrder validates the order and sends it to Kafka for asynchronous processing.
+// CreateOrder validates the order and sends it to Kafka for asynchronous processing,
+// in the message codec with an envelope of the current order schema version.
 // It does not wait for persistence — that's handled by the consumer.
 func (uc *OrderUseCase) CreateOrder(ctx context.Context, order models.Order) error {
 	const op = "usecase.CreateOrder"
 
 	if err := validator.ValidateOrder(&order); err != nil {
-		return fmt.Errorf("%s: validator: %w", op, err)
+		return fmt.Errorf("%s: validator: %w: %w", op, models.ErrInvalidOrder, err)
 	}
 
-	orderJSON, err := json.Marshal(order)
+	if err := uc.verify(order); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	value, headers, err := uc.encodeOrder(order)
 	if err != nil {
-		return fmt.Errorf("%s: json marshal err: %w", op, err)
+		return fmt.Errorf("%s: encode order: %w", op, err)
+	}
+
+	// the order is audited before it is sent, so no sent order goes unrecorded
+	if err := uc.auditOrder(ctx, ActionOrderCreate, "", order.OrderUID, nil, order); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := uc.messageBroker.Send(ctx, order.OrderUID, orderJSON); err != nil {
+	if err := uc.send(ctx, order.OrderUID, value, headers); err != nil {
 		return fmt.Errorf("%s: kafka producer send err: %w", op, err)
 	}
 
@@ -69,57 +210,347 @@ func (uc *OrderUseCase) CreateOrder(ctx context.Context, order models.Order) err
 }
 
 // GetOrder retrieves an order by UID, first checking cache, then database.
-// On successful DB fetch, it updates the cache.
+// On successful DB fetch, it updates the cache. Concurrent misses for the same
+// order share a single database fetch; stale cache entries are served as is
+// and refreshed in the background. Unknown UIDs are answered from the negative
+// cache with models.ErrOrderNotFound. If an access policy is configured, the
+// caller must be allowed to read the order, otherwise models.ErrForbidden is returned.
 func (uc *OrderUseCase) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
 	const op = "usecase.GetOrder"
 
-	cached, err := uc.cacheRepo.GetOrder(ctx, orderUID)
+	if err := uc.authenticate(ctx); err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	order, err := uc.getOrder(ctx, orderUID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	order, err = uc.openPII(order)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	order, masked, err := uc.authorize(ctx, order)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if uc.auditReads && !masked {
+		if err := uc.auditOrder(ctx, ActionOrderReadPII, "", orderUID, nil, nil); err != nil {
+			return models.Order{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return order, nil
+}
+
+// ListOrders calls fn with every order of the customer the caller may read,
+// read the same way as by GetOrder. Orders the caller may not read are skipped.
+// Iteration stops at the first error returned by fn.
+func (uc *OrderUseCase) ListOrders(ctx context.Context, customerID string, fn func(models.Order) error) error {
+	const op = "usecase.ListOrders"
+
+	repo, ok := uc.orderRepo.(CustomerRepository)
+	if !ok {
+		return fmt.Errorf("%s: order repository does not support customer lookups", op)
+	}
+
+	uids, err := repo.ListCustomerOrders(customerID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, uid := range uids {
+		order, err := uc.GetOrder(ctx, uid)
+		if errors.Is(err, models.ErrForbidden) || errors.Is(err, models.ErrOrderNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := fn(order); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// authenticate rejects a caller without an identity in ctx when the access
+// policy is enforced, before the order is looked up.
+func (uc *OrderUseCase) authenticate(ctx context.Context) error {
+	if _, ok := auth.FromContext(ctx); uc.policy != nil && !ok {
+		return fmt.Errorf("unauthenticated caller: %w", models.ErrForbidden)
+	}
+	return nil
+}
+
+// authorize applies the access policy and the masking rules to the order
+// read by the caller from ctx. masked reports whether personal data was hidden.
+func (uc *OrderUseCase) authorize(ctx context.Context, order models.Order) (_ models.Order, masked bool, _ error) {
+	id, authenticated := auth.FromContext(ctx)
+
+	if uc.policy != nil {
+		if !authenticated {
+			return models.Order{}, false, fmt.Errorf("unauthenticated caller: %w", models.ErrForbidden)
+		}
+
+		// an order the caller may not read is reported as missing, so its existence is not leaked
+		decision := uc.policy.Authorize(id, order)
+		if !decision.Allow {
+			return models.Order{}, false, fmt.Errorf("caller %q: %w", id.Subject, models.ErrOrderNotFound)
+		}
+		if decision.MaskPII {
+			return masking.Apply(order), true, nil
+		}
+	}
+
+	if uc.maskPII && !slices.ContainsFunc(uc.revealRoles, id.HasRole) {
+		return masking.Apply(order), true, nil
+	}
+
+	return order, false, nil
+}
+
+// verify checks the order signature if a verifier is configured.
+func (uc *OrderUseCase) verify(order models.Order) error {
+	if uc.verifier == nil {
+		return nil
+	}
+	return uc.verifier.Verify(order)
+}
+
+// getOrder reads the order through the cache layers without access checks.
+func (uc *OrderUseCase) getOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	if order, ok := uc.getLocal(ctx, orderUID); ok {
+		return order, nil
+	}
+
+	cached, stale, err := uc.getCached(ctx, orderUID)
 	if err == nil && len(cached) > 0 {
 		var order models.Order
 		if jsonErr := json.Unmarshal(cached, &order); jsonErr == nil {
+			if stale {
+				go uc.refreshOrder(orderUID)
+			} else if uc.localCache != nil {
+				uc.localCache.SetOrder(ctx, orderUID, cached, uc.ttl())
+			}
 			return order, nil
 		}
-		uc.cacheRepo.DeleteOrder(ctx, orderUID)
+		uc.InvalidateOrder(ctx, orderUID)
 	}
 
-	order, err := uc.orderRepo.GetOrder(orderUID)
-	if err != nil {
-		return models.Order{}, fmt.Errorf("%s: orderRepo get order: %w", op, err)
+	if uc.isKnownMissing(ctx, orderUID) {
+		return models.Order{}, fmt.Errorf("negative cache: %w", models.ErrOrderNotFound)
 	}
 
-	orderJSON, marshalErr := json.Marshal(order)
-	if marshalErr == nil {
-		uc.cacheRepo.SetOrder(ctx, orderUID, orderJSON, 24*time.Hour)
+	order, err := uc.loadOrder(ctx, orderUID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("orderRepo get order: %w", err)
 	}
 
 	return order, nil
 }
 
+// getLocal reads the order from the in-process cache layer, if configured.
+func (uc *OrderUseCase) getLocal(ctx context.Context, orderUID string) (models.Order, bool) {
+	if uc.localCache == nil {
+		return models.Order{}, false
+	}
+
+	data, err := uc.localCache.GetOrder(ctx, orderUID)
+	if err != nil || len(data) == 0 {
+		return models.Order{}, false
+	}
+
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		uc.localCache.DeleteOrder(ctx, orderUID)
+		return models.Order{}, false
+	}
+
+	return order, true
+}
+
+// getCached reads the order from cache, reporting staleness when the cache supports it.
+func (uc *OrderUseCase) getCached(ctx context.Context, orderUID string) ([]byte, bool, error) {
+	if sc, ok := uc.cacheRepo.(StaleCacheRepository); ok {
+		return sc.GetOrderState(ctx, orderUID)
+	}
+
+	data, err := uc.cacheRepo.GetOrder(ctx, orderUID)
+	return data, false, err
+}
+
+// isKnownMissing reports whether the negative cache remembers orderUID as not found.
+func (uc *OrderUseCase) isKnownMissing(ctx context.Context, orderUID string) bool {
+	nc, ok := uc.cacheRepo.(NegativeCacheRepository)
+	if !ok {
+		return false
+	}
+
+	missing, err := nc.IsOrderMissing(ctx, orderUID)
+	return err == nil && missing
+}
+
+// loadOrder fetches the order from the repository and stores it in the cache.
+// Concurrent calls for the same orderUID are coalesced into one fetch.
+// A not found result is stored in the negative cache.
+func (uc *OrderUseCase) loadOrder(ctx context.Context, orderUID string) (models.Order, error) {
+	v, err, _ := uc.loads.Do(orderUID, func() (any, error) {
+		// the result is shared by every waiting caller, so canceling the
+		// first one must not cancel the cache writes
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadCacheTimeout)
+		defer cancel()
+
+		order, err := uc.orderRepo.GetOrder(orderUID)
+		if err != nil {
+			if nc, ok := uc.cacheRepo.(NegativeCacheRepository); ok && errors.Is(err, models.ErrOrderNotFound) {
+				nc.SetOrderMissing(ctx, orderUID, uc.missingTTL)
+			}
+			return models.Order{}, err
+		}
+
+		if orderJSON, marshalErr := json.Marshal(order); marshalErr == nil {
+			uc.storeInCache(ctx, orderUID, orderJSON)
+		}
+
+		return order, nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return v.(models.Order), nil
+}
+
+// refreshOrder reloads a stale cache entry detached from the request context.
+func (uc *OrderUseCase) refreshOrder(orderUID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	_, _ = uc.loadOrder(ctx, orderUID)
+}
+
 // HandleMessage processes incoming Kafka message with order data.
 // It saves the order to DB if not exists and updates cache.
+// The content-type header selects the codec of the message, JSON without it.
+// Orders of older schema versions, including bare orders sent without an
+// envelope, are upcast to the current one; messages of unknown schema
+// versions, types or content types yield models.ErrUnknownSchema.
+// If the audit record of the stored order can't be written, it returns
+// models.ErrAuditUnavailable; the consumer must stop without committing the
+// message, so the order is audited once it is consumed again.
 // Used by Kafka consumer.
-func (uc *OrderUseCase) HandleMessage(ctx context.Context, value []byte) error {
+func (uc *OrderUseCase) HandleMessage(ctx context.Context, value []byte, headers map[string]string) error {
 	const op = "usecase.HandleMessage"
 
-	var order models.Order
-	if err := json.Unmarshal(value, &order); err != nil {
+	order, err := uc.decodeOrder(value, headers)
+	if err != nil {
 		return fmt.Errorf("%s: failed to unmarshal message: %w", op, err)
 	}
 
-	// Avoid duplicates
+	if err := uc.verify(order); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the version is owned by the repository, not by the producer
+	order.Version = models.InitialVersion
+
+	// Avoid duplicates. The consumer stops when the audit write of a stored
+	// order fails, so the order is audited when it is consumed again.
 	if _, err := uc.orderRepo.GetOrder(order.OrderUID); err == nil {
+		if err := uc.retryStoreAudit(ctx, func() error { return uc.auditStoredOnce(ctx, order) }); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 		return nil // order already exists
 	}
 
+	plain := order
+	order, err = uc.sealPII(order)
+	if err != nil {
+		return fmt.Errorf("%s: failed to encrypt personal data: %w", op, err)
+	}
+
 	if err := uc.orderRepo.NewOrder(order); err != nil {
 		return fmt.Errorf("%s: failed to save order to repository: %w", op, err)
 	}
 
+	err = uc.retryStoreAudit(ctx, func() error {
+		return uc.auditOrder(ctx, ActionOrderStore, actorConsumer, order.OrderUID, nil, plain)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	// Update cache
 	if orderJSON, marshalErr := json.Marshal(order); marshalErr == nil {
-		uc.cacheRepo.SetOrder(ctx, order.OrderUID, orderJSON, 24*time.Hour)
+		uc.storeInCache(ctx, order.OrderUID, orderJSON)
 	}
 
 	return nil
 }
 
+// InvalidateOrder evicts the order from the shared cache and, through the
+// invalidation bus, from the in-process cache of every replica.
+func (uc *OrderUseCase) InvalidateOrder(ctx context.Context, orderUID string) error {
+	const op = "usecase.InvalidateOrder"
+
+	uc.EvictLocal(orderUID)
+
+	if err := uc.cacheRepo.DeleteOrder(ctx, orderUID); err != nil {
+		return fmt.Errorf("%s: cacheRepo delete order: %w", op, err)
+	}
+
+	return nil
+}
+
+// evict invalidates the order after a stored change. A stale entry would
+// serve the old version until its TTL, so a failed eviction is retried
+// before it is reported.
+func (uc *OrderUseCase) evict(ctx context.Context, orderUID string) error {
+	var err error
+	for attempt := range evictAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(evictBackoff):
+			}
+		}
+		if err = uc.InvalidateOrder(ctx, orderUID); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// EvictLocal drops the order from the in-process cache layer.
+// It is the subscriber of the cross-instance invalidation bus.
+func (uc *OrderUseCase) EvictLocal(orderUID string) {
+	if uc.localCache != nil {
+		uc.localCache.DeleteOrder(context.Background(), orderUID)
+	}
+}
+
+// storeInCache writes the serialized order to the shared and in-process caches.
+func (uc *OrderUseCase) storeInCache(ctx context.Context, orderUID string, data []byte) {
+	ttl := uc.ttl()
+
+	uc.cacheRepo.SetOrder(ctx, orderUID, data, ttl)
+	if uc.localCache != nil {
+		uc.localCache.SetOrder(ctx, orderUID, data, ttl)
+	}
+}
+
+// ttl returns the cache TTL with random jitter, so entries written together
+// do not expire together.
+func (uc *OrderUseCase) ttl() time.Duration {
+	if uc.cacheJitter <= 0 {
+		return uc.cacheTTL
+	}
+	return uc.cacheTTL + rand.N(uc.cacheJitter)
+}
@@ -1,10 +1,16 @@
 package usecase
 
 import (
+	"WB/internal/config"
+	"WB/internal/lib/auth"
+	"WB/internal/lib/envelope"
+	"WB/internal/lib/policy"
 	"WB/internal/models"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -35,6 +41,10 @@ func (m *mockCacheRepo) GetOrder(ctx context.Context, orderUID string) ([]byte,
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+// liveCtx matches a context that is not canceled, such as the detached
+// context of the cache writes of a shared load.
+var liveCtx = mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })
+
 func (m *mockCacheRepo) SetOrder(ctx context.Context, orderUID string, data []byte, ttl time.Duration) error {
 	args := m.Called(ctx, orderUID, data, ttl)
 	return args.Error(0)
@@ -121,7 +131,7 @@ func TestCreateOrder_Success(t *testing.T) {
 	orderJSON, _ := json.Marshal(order)
 
 	mockProd.
-		On("Send", ctx, order.OrderUID, mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) })).
+		On("Send", ctx, order.OrderUID, mock.MatchedBy(func(b []byte) bool { return isOrderEnvelope(t, orderJSON, b) })).
 		Return(nil).
 		Once()
 
@@ -133,6 +143,16 @@ func TestCreateOrder_Success(t *testing.T) {
 	mockProd.AssertExpectations(t)
 }
 
+// isOrderEnvelope reports whether msg is the envelope of the current order schema with orderJSON as the payload.
+func isOrderEnvelope(t *testing.T, orderJSON, msg []byte) bool {
+	var env envelope.Envelope
+	return assert.NoError(t, json.Unmarshal(msg, &env)) &&
+		assert.Equal(t, models.EventOrderCreated, env.Type) &&
+		assert.Equal(t, models.OrderSchemaVersion, env.SchemaVersion) &&
+		assert.Equal(t, envelope.ContentTypeJSON, env.ContentType) &&
+		assert.JSONEq(t, string(orderJSON), string(env.Payload))
+}
+
 func TestCreateOrder_ValidationError(t *testing.T) {
 	ctx := context.Background()
 	mockRepo := new(mockOrderRepo)
@@ -145,7 +165,7 @@ func TestCreateOrder_ValidationError(t *testing.T) {
 
 	err := uc.CreateOrder(ctx, order)
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, models.ErrInvalidOrder)
 	assert.Contains(t, err.Error(), "validator")
 	mockProd.AssertNotCalled(t, "Send")
 }
@@ -226,7 +246,7 @@ func TestCreateOrder_KafkaError(t *testing.T) {
 	orderJSON, _ := json.Marshal(order)
 
 	mockProd.
-		On("Send", ctx, order.OrderUID, mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) })).
+		On("Send", ctx, order.OrderUID, mock.MatchedBy(func(b []byte) bool { return isOrderEnvelope(t, orderJSON, b) })).
 		Return(errors.New("kafka timeout")).
 		Once()
 
@@ -289,7 +309,7 @@ func TestGetOrder_FromCache_InvalidJSON(t *testing.T) {
 
 	orderJSON, _ := json.Marshal(order)
 	mockCache.
-		On("SetOrder", ctx, "cache-invalid-888", mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) }), 24*time.Hour).
+		On("SetOrder", liveCtx, "cache-invalid-888", mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) }), 24*time.Hour).
 		Return(nil).
 		Once()
 
@@ -323,7 +343,7 @@ func TestGetOrder_CacheMiss_DB_Success(t *testing.T) {
 
 	orderJSON, _ := json.Marshal(order)
 	mockCache.
-		On("SetOrder", ctx, "cache-miss-999", mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) }), 24*time.Hour).
+		On("SetOrder", liveCtx, "cache-miss-999", mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) }), 24*time.Hour).
 		Return(nil).
 		Once()
 
@@ -337,6 +357,36 @@ func TestGetOrder_CacheMiss_DB_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetOrder_CacheMiss_CanceledCallerStillCaches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	order := models.Order{OrderUID: "canceled-caller"}
+
+	mockCache.
+		On("GetOrder", ctx, "canceled-caller").
+		Return([]byte{}, errors.New("not found")).
+		Once()
+	mockRepo.
+		On("GetOrder", "canceled-caller").
+		Return(order, nil).
+		Once()
+	mockCache.
+		On("SetOrder", liveCtx, "canceled-caller", mock.Anything, 24*time.Hour).
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
+
+	_, err := uc.GetOrder(ctx, "canceled-caller")
+
+	assert.NoError(t, err)
+	mockCache.AssertExpectations(t)
+}
+
 func TestGetOrder_CacheMiss_DB_Error(t *testing.T) {
 	ctx := context.Background()
 	mockRepo := new(mockOrderRepo)
@@ -383,7 +433,9 @@ func TestHandleMessage_NewOrder_Success(t *testing.T) {
 		Return(nil).
 		Once()
 
-	orderJSON, _ := json.Marshal(order)
+	stored := order
+	stored.Version = models.InitialVersion
+	orderJSON, _ := json.Marshal(stored)
 	mockCache.
 		On("SetOrder", ctx, "new-order-abc", mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) }), 24*time.Hour).
 		Return(nil).
@@ -391,7 +443,7 @@ func TestHandleMessage_NewOrder_Success(t *testing.T) {
 
 	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
 
-	err := uc.HandleMessage(ctx, data)
+	err := uc.HandleMessage(ctx, data, nil)
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
@@ -406,7 +458,7 @@ func TestHandleMessage_UnmarshalError(t *testing.T) {
 
 	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
 
-	err := uc.HandleMessage(ctx, []byte("invalid json"))
+	err := uc.HandleMessage(ctx, []byte("invalid json"), nil)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to unmarshal message")
@@ -436,7 +488,7 @@ func TestHandleMessage_NewOrder_RepoError(t *testing.T) {
 
 	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
 
-	err := uc.HandleMessage(ctx, data)
+	err := uc.HandleMessage(ctx, data, nil)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to save order to repository")
@@ -464,7 +516,9 @@ func TestHandleMessage_GetOrderError_NotNotFound(t *testing.T) {
 		Return(nil).
 		Once()
 
-	orderJSON, _ := json.Marshal(order)
+	stored := order
+	stored.Version = models.InitialVersion
+	orderJSON, _ := json.Marshal(stored)
 	mockCache.
 		On("SetOrder", ctx, "get-err-proceed", mock.MatchedBy(func(b []byte) bool { return assert.JSONEq(t, string(orderJSON), string(b)) }), 24*time.Hour).
 		Return(nil).
@@ -472,7 +526,7 @@ func TestHandleMessage_GetOrderError_NotNotFound(t *testing.T) {
 
 	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
 
-	err := uc.HandleMessage(ctx, data)
+	err := uc.HandleMessage(ctx, data, nil)
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
@@ -495,10 +549,480 @@ func TestHandleMessage_DuplicateOrder(t *testing.T) {
 
 	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
 
-	err := uc.HandleMessage(ctx, data)
+	err := uc.HandleMessage(ctx, data, nil)
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 	mockRepo.AssertNotCalled(t, "NewOrder")
 	mockCache.AssertNotCalled(t, "SetOrder")
 }
+
+type mockStaleCacheRepo struct {
+	mockCacheRepo
+}
+
+func (m *mockStaleCacheRepo) GetOrderState(ctx context.Context, orderUID string) ([]byte, bool, error) {
+	args := m.Called(ctx, orderUID)
+	return args.Get(0).([]byte), args.Bool(1), args.Error(2)
+}
+
+func TestGetOrder_CacheMiss_Coalesced(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	const callers = 10
+	order := models.Order{OrderUID: "hot-order"}
+
+	mockCache.
+		On("GetOrder", ctx, "hot-order").
+		Return([]byte{}, errors.New("not found")).
+		Times(callers)
+
+	mockRepo.
+		On("GetOrder", "hot-order").
+		WaitUntil(time.After(100*time.Millisecond)).
+		Return(order, nil).
+		Once()
+
+	mockCache.
+		On("SetOrder", liveCtx, "hot-order", mock.Anything, 24*time.Hour).
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
+
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := uc.GetOrder(ctx, "hot-order")
+			assert.NoError(t, err)
+			assert.Equal(t, order.OrderUID, result.OrderUID)
+		}()
+	}
+	wg.Wait()
+
+	mockRepo.AssertNumberOfCalls(t, "GetOrder", 1)
+	mockCache.AssertExpectations(t)
+}
+
+func TestGetOrder_StaleCache_RefreshedInBackground(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockStaleCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	stale := models.Order{OrderUID: "stale-order", TrackNumber: "OLD"}
+	fresh := models.Order{OrderUID: "stale-order", TrackNumber: "NEW"}
+	staleJSON, _ := json.Marshal(stale)
+	freshJSON, _ := json.Marshal(fresh)
+
+	mockCache.
+		On("GetOrderState", ctx, "stale-order").
+		Return(staleJSON, true, nil).
+		Once()
+
+	mockRepo.
+		On("GetOrder", "stale-order").
+		Return(fresh, nil).
+		Once()
+
+	refreshed := make(chan struct{})
+	mockCache.
+		On("SetOrder", mock.Anything, "stale-order", freshJSON, 24*time.Hour).
+		Run(func(mock.Arguments) { close(refreshed) }).
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
+
+	result, err := uc.GetOrder(ctx, "stale-order")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "OLD", result.TrackNumber)
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale cache entry was not refreshed")
+	}
+
+	mockCache.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+type mockNegativeCacheRepo struct {
+	mockCacheRepo
+}
+
+func (m *mockNegativeCacheRepo) IsOrderMissing(ctx context.Context, orderUID string) (bool, error) {
+	args := m.Called(ctx, orderUID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockNegativeCacheRepo) SetOrderMissing(ctx context.Context, orderUID string, ttl time.Duration) error {
+	args := m.Called(ctx, orderUID, ttl)
+	return args.Error(0)
+}
+
+func TestGetOrder_NegativeCache_Hit(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockNegativeCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	mockCache.
+		On("GetOrder", ctx, "unknown-order").
+		Return([]byte(nil), nil).
+		Once()
+
+	mockCache.
+		On("IsOrderMissing", ctx, "unknown-order").
+		Return(true, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
+
+	_, err := uc.GetOrder(ctx, "unknown-order")
+
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	mockCache.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetOrder")
+}
+
+func TestGetOrder_NotFound_RememberedInNegativeCache(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockNegativeCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	mockCache.
+		On("GetOrder", ctx, "unknown-order").
+		Return([]byte(nil), nil).
+		Once()
+
+	mockCache.
+		On("IsOrderMissing", ctx, "unknown-order").
+		Return(false, nil).
+		Once()
+
+	mockRepo.
+		On("GetOrder", "unknown-order").
+		Return(models.Order{}, fmt.Errorf("storage: %w", models.ErrOrderNotFound)).
+		Once()
+
+	mockCache.
+		On("SetOrderMissing", liveCtx, "unknown-order", time.Minute).
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd)
+
+	_, err := uc.GetOrder(ctx, "unknown-order")
+
+	assert.ErrorIs(t, err, models.ErrOrderNotFound)
+	mockCache.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertNotCalled(t, "SetOrder")
+}
+
+func TestGetOrder_CachePolicy_Jitter(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	order := models.Order{OrderUID: "jitter-order"}
+
+	mockCache.
+		On("GetOrder", ctx, "jitter-order").
+		Return([]byte(nil), nil).
+		Once()
+
+	mockRepo.
+		On("GetOrder", "jitter-order").
+		Return(order, nil).
+		Once()
+
+	mockCache.
+		On("SetOrder", liveCtx, "jitter-order", mock.Anything, mock.MatchedBy(func(ttl time.Duration) bool {
+			return ttl >= time.Hour && ttl < time.Hour+10*time.Minute
+		})).
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithCachePolicy(time.Hour, 10*time.Minute, time.Minute))
+
+	_, err := uc.GetOrder(ctx, "jitter-order")
+
+	assert.NoError(t, err)
+	mockCache.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetOrder_LocalCache(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockLocal := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	order := models.Order{OrderUID: "local-hit"}
+	orderJSON, _ := json.Marshal(order)
+
+	mockLocal.
+		On("GetOrder", ctx, "local-hit").
+		Return(orderJSON, nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithLocalCache(mockLocal))
+
+	result, err := uc.GetOrder(ctx, "local-hit")
+
+	assert.NoError(t, err)
+	assert.Equal(t, order.OrderUID, result.OrderUID)
+	mockLocal.AssertExpectations(t)
+	mockCache.AssertNotCalled(t, "GetOrder")
+	mockRepo.AssertNotCalled(t, "GetOrder")
+}
+
+func TestInvalidateOrder(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockLocal := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+
+	mockLocal.
+		On("DeleteOrder", mock.Anything, "changed-order").
+		Return(nil).
+		Once()
+
+	mockCache.
+		On("DeleteOrder", ctx, "changed-order").
+		Return(nil).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithLocalCache(mockLocal))
+
+	err := uc.InvalidateOrder(ctx, "changed-order")
+
+	assert.NoError(t, err)
+	mockLocal.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestGetOrder_AccessPolicy(t *testing.T) {
+	accessPolicy, err := policy.New(config.Authz{Rules: []config.AuthzRule{
+		{Role: "support", Scope: policy.ScopeAll, MaskPII: true},
+		{Role: "seller", Scope: policy.ScopeTenant, TenantFields: []string{policy.FieldEntry}},
+	}})
+	assert.NoError(t, err)
+
+	order := models.Order{
+		OrderUID: "policy-order",
+		Entry:    "WBIL",
+		Delivery: models.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: "test@gmail.com", City: "Kiryat Mozkin"},
+	}
+	orderJSON, _ := json.Marshal(order)
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantErr   error
+		noLookup  bool
+		wantName  string
+		wantEmail string
+	}{
+		{
+			name:      "owner seller",
+			ctx:       auth.WithIdentity(context.Background(), auth.Identity{Subject: "s1", Roles: []string{"seller"}, Tenant: "WBIL"}),
+			wantName:  "Test Testov",
+			wantEmail: "test@gmail.com",
+		},
+		{
+			name:    "foreign seller",
+			ctx:     auth.WithIdentity(context.Background(), auth.Identity{Subject: "s2", Roles: []string{"seller"}, Tenant: "OTHER"}),
+			wantErr: models.ErrOrderNotFound,
+		},
+		{
+			name:      "support masked",
+			ctx:       auth.WithIdentity(context.Background(), auth.Identity{Subject: "sup", Roles: []string{"support"}}),
+			wantName:  "T***",
+			wantEmail: "t***@gmail.com",
+		},
+		{
+			name:     "unauthenticated",
+			ctx:      context.Background(),
+			wantErr:  models.ErrForbidden,
+			noLookup: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockOrderRepo)
+			mockCache := new(mockCacheRepo)
+			mockProd := new(mockMessageBroker)
+
+			if !tt.noLookup {
+				mockCache.
+					On("GetOrder", tt.ctx, "policy-order").
+					Return(orderJSON, nil).
+					Once()
+			}
+
+			uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithAccessPolicy(accessPolicy))
+
+			result, err := uc.GetOrder(tt.ctx, "policy-order")
+
+			mockCache.AssertExpectations(t)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantName, result.Delivery.Name)
+			assert.Equal(t, tt.wantEmail, result.Delivery.Email)
+			assert.Equal(t, "Kiryat Mozkin", result.Delivery.City)
+		})
+	}
+}
+
+func TestGetOrder_PIIMasking(t *testing.T) {
+	order := models.Order{
+		OrderUID: "masked-order",
+		Delivery: models.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: "test@gmail.com", City: "Kiryat Mozkin"},
+		Payment:  models.Payment{Transaction: "b563feb7b2b84b6test"},
+	}
+	orderJSON, _ := json.Marshal(order)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantName string
+		wantTx   string
+	}{
+		{
+			name:     "privileged role",
+			ctx:      auth.WithIdentity(context.Background(), auth.Identity{Subject: "a1", Roles: []string{"seller", "admin"}}),
+			wantName: "Test Testov",
+			wantTx:   "b563feb7b2b84b6test",
+		},
+		{
+			name:     "other role",
+			ctx:      auth.WithIdentity(context.Background(), auth.Identity{Subject: "s1", Roles: []string{"seller"}}),
+			wantName: "T***",
+			wantTx:   "b***",
+		},
+		{
+			name:     "unauthenticated",
+			ctx:      context.Background(),
+			wantName: "T***",
+			wantTx:   "b***",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockOrderRepo)
+			mockCache := new(mockCacheRepo)
+			mockProd := new(mockMessageBroker)
+
+			mockCache.
+				On("GetOrder", tt.ctx, "masked-order").
+				Return(orderJSON, nil).
+				Once()
+
+			uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithPIIMasking([]string{"admin"}))
+
+			result, err := uc.GetOrder(tt.ctx, "masked-order")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantName, result.Delivery.Name)
+			assert.Equal(t, tt.wantTx, result.Payment.Transaction)
+			assert.Equal(t, "Kiryat Mozkin", result.Delivery.City)
+		})
+	}
+}
+
+type mockVerifier struct {
+	mock.Mock
+}
+
+func (m *mockVerifier) Verify(order models.Order) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func TestHandleMessage_InvalidSignature(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockOrderRepo)
+	mockCache := new(mockCacheRepo)
+	mockProd := new(mockMessageBroker)
+	verifier := new(mockVerifier)
+
+	order := models.Order{OrderUID: "forged-order", Entry: "WBIL", InternalSignature: "c2ln"}
+	data, _ := json.Marshal(order)
+
+	verifier.
+		On("Verify", order).
+		Return(fmt.Errorf("order forged-order: %w", models.ErrInvalidSignature)).
+		Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, mockProd, WithSignatureVerifier(verifier))
+
+	err := uc.HandleMessage(ctx, data, nil)
+
+	assert.ErrorIs(t, err, models.ErrInvalidSignature)
+	verifier.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetOrder", mock.Anything)
+	mockRepo.AssertNotCalled(t, "NewOrder", mock.Anything)
+}
+
+func TestListOrders_SkipsForbidden(t *testing.T) {
+	accessPolicy, err := policy.New(config.Authz{Rules: []config.AuthzRule{
+		{Role: "seller", Scope: policy.ScopeTenant, TenantFields: []string{policy.FieldEntry}},
+	}})
+	assert.NoError(t, err)
+
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Subject: "seller-1", Roles: []string{"seller"}, Tenant: "WBIL"})
+	mockRepo := new(mockCustomerRepo)
+	mockCache := new(mockCacheRepo)
+
+	mockRepo.On("ListCustomerOrders", "test").Return([]string{"own", "foreign"}, nil).Once()
+	for uid, entry := range map[string]string{"own": "WBIL", "foreign": "OTHER"} {
+		data, _ := json.Marshal(models.Order{OrderUID: uid, Entry: entry})
+		mockCache.On("GetOrder", ctx, uid).Return(data, nil).Once()
+	}
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker), WithAccessPolicy(accessPolicy))
+
+	var got []string
+	err = uc.ListOrders(ctx, "test", func(order models.Order) error {
+		got = append(got, order.OrderUID)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"own"}, got)
+}
+
+func TestListOrders_StopsOnCallbackError(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(mockCustomerRepo)
+	mockCache := new(mockCacheRepo)
+
+	mockRepo.On("ListCustomerOrders", "test").Return([]string{"a", "b"}, nil).Once()
+	data, _ := json.Marshal(models.Order{OrderUID: "a"})
+	mockCache.On("GetOrder", ctx, "a").Return(data, nil).Once()
+
+	uc := NewOrderUseCase(mockRepo, mockCache, new(mockMessageBroker))
+
+	errStop := errors.New("stream closed")
+	err := uc.ListOrders(ctx, "test", func(models.Order) error { return errStop })
+
+	assert.ErrorIs(t, err, errStop)
+	mockCache.AssertNotCalled(t, "GetOrder", ctx, "b")
+}
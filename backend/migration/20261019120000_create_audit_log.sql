@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+
+-- Append-only journal. Every record stores the hash of the previous one,
+-- so any modified or removed record breaks the chain.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    request_id TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    resource TEXT NOT NULL,
+    details TEXT NOT NULL,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE audit_log;
+DROP INDEX orders_customer_id_idx;
+-- +goose StatementEnd
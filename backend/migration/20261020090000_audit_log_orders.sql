@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE audit_log
+    ADD COLUMN order_uid TEXT NOT NULL DEFAULT '',
+    ADD COLUMN diff TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX audit_log_order_uid_idx ON audit_log (order_uid, id) WHERE order_uid <> '';
+CREATE INDEX audit_log_actor_idx ON audit_log (actor, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX audit_log_actor_idx;
+DROP INDEX audit_log_order_uid_idx;
+ALTER TABLE audit_log
+    DROP COLUMN diff,
+    DROP COLUMN order_uid;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1 CHECK (version > 0);
+
+CREATE INDEX items_order_uid_chrt_id_idx ON items (order_uid, chrt_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX items_order_uid_chrt_id_idx;
+ALTER TABLE orders
+    DROP COLUMN version;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE refunds (
+    id BIGSERIAL PRIMARY KEY,
+    transaction VARCHAR(255) NOT NULL,
+    order_uid VARCHAR(255) NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('cancel', 'return')),
+    chrt_ids INTEGER[] NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    currency TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY (transaction) REFERENCES payment(transaction) ON DELETE CASCADE,
+    FOREIGN KEY (order_uid) REFERENCES orders(order_uid) ON DELETE CASCADE
+);
+
+CREATE INDEX refunds_transaction_idx ON refunds (transaction);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE refunds;
+-- +goose StatementEnd
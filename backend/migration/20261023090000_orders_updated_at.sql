@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- NULL until the order is changed for the first time
+ALTER TABLE orders
+    ADD COLUMN updated_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders
+    DROP COLUMN updated_at;
+-- +goose StatementEnd